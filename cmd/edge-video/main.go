@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
 	"time"
 
@@ -77,6 +79,14 @@ func main() {
 		}
 	}
 
+	var probeTimeout time.Duration
+	if cfg.Optimization.ProbeEnabled {
+		probeTimeout = time.Duration(cfg.Optimization.ProbeTimeoutMs) * time.Millisecond
+		if probeTimeout <= 0 {
+			probeTimeout = 2 * time.Second
+		}
+	}
+
 	logger.Log.Infow("Configuração carregada",
 		"config_file", *configFile,
 		"target_fps", cfg.TargetFPS,
@@ -86,6 +96,9 @@ func main() {
 		"worker_queue_size", workerQueueSize,
 		"camera_buffer_size", cameraBufferSize,
 		"persistent_buffer_size", persistentBufferSize,
+		"camera_buffer_budget_mb", cfg.Optimization.CameraBufferBudgetMB,
+		"global_buffer_budget_mb", cfg.Optimization.GlobalBufferBudgetMB,
+		"probe_timeout", probeTimeout,
 		"vhost", vhost)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -147,35 +160,152 @@ func main() {
 			"emergency_percent", cfg.Memory.EmergencyPercent)
 	}
 
+	// Orçamento global de bytes dos frames retidos; mesmo sem limite ele
+	// contabiliza o uso do pipeline para o Memory Controller
+	globalBudget := buffer.NewByteBudget("global", int64(cfg.Optimization.GlobalBufferBudgetMB)<<20, nil)
+	if memController != nil {
+		memController.SetPipelineUsage(globalBudget)
+	}
+
 	// Registra o serviço na API (se habilitado)
 	// Tenta registrar com retry automático a cada 1 minuto em caso de falha
 	registrationClient := registration.NewClient(cfg.Registration.APIURL, cfg.Registration.Enabled)
 	registrationClient.RegisterWithRetry(ctx, cfg, vhost)
 
-	workerPool := worker.NewPool(ctx, maxWorkers, workerQueueSize)
-	defer workerPool.Close()
+	initialWorkers := maxWorkers
+	minWorkers := cfg.Optimization.MinWorkers
+	if minWorkers <= 0 {
+		minWorkers = runtime.NumCPU()
+	}
+	if minWorkers > maxWorkers {
+		minWorkers = maxWorkers
+	}
+	if cfg.Optimization.AutoscaleWorkers {
+		initialWorkers = minWorkers
+	}
+
+	workerPool := worker.NewPool(ctx, initialWorkers, workerQueueSize)
+	workerPool.SetOrdered(cfg.Optimization.OrderedDelivery)
+
+	if cfg.Optimization.AutoscaleWorkers {
+		workerPool.EnableAutoscale(worker.AutoscaleConfig{
+			Name:          "main",
+			MinWorkers:    minWorkers,
+			MaxWorkers:    maxWorkers,
+			ScaleUpWait:   time.Duration(cfg.Optimization.ScaleUpWaitMs) * time.Millisecond,
+			ScaleDownIdle: time.Duration(cfg.Optimization.ScaleDownIdleSec) * time.Second,
+			// Não cresce com memória em nível crítico ou pior
+			CanScaleUp: func() bool {
+				return memController == nil || memController.GetLevel() < memcontrol.MemoryCritical
+			},
+		})
+	}
 
 	var publisher mq.Publisher
 	var amqpPublisher *mq.AMQPPublisher
-	if cfg.Protocol == "mqtt" {
-		p, err := mq.NewMQTTPublisher(cfg.MQTT.Broker, cfg.MQTT.TopicPrefix)
-		if err != nil {
-			logger.Log.Fatalw("Erro ao criar mqtt publisher", "error", err)
+	// Publishers que também recebem os eventos de status (MQTT)
+	var statusSinks []metadata.StatusSink
+	// Publishers AMQP, para os avisos de frames sem rota, e os que seguram
+	// a captura quando o broker bloqueia as publicações
+	var amqpSources, blockingSources []amqpSource
+	if len(cfg.Destinations) > 0 {
+		sinks := make([]mq.Sink, 0, len(cfg.Destinations))
+		for _, dest := range cfg.Destinations {
+			policy, err := mq.ParseSinkPolicy(dest.Policy)
+			if err != nil {
+				logger.Log.Fatalw("Configuração de destino inválida", "destination", dest.Name, "error", err)
+			}
+			p, amqpP, err := newProtocolPublisher(dest, cfg.Cameras, vhost)
+			if err != nil {
+				logger.Log.Fatalw("Erro ao criar publisher do destino", "destination", dest.Name, "error", err)
+			}
+			// Os metadados usam o canal do primeiro destino AMQP
+			if amqpPublisher == nil {
+				amqpPublisher = amqpP
+			}
+			if sink, ok := p.(metadata.StatusSink); ok {
+				statusSinks = append(statusSinks, sink)
+			}
+			if amqpP != nil {
+				src := amqpSource{name: dest.Name, publisher: amqpP}
+				amqpSources = append(amqpSources, src)
+				// Um destino best_effort bloqueado só enche a própria fila
+				if policy == mq.SinkRequired {
+					blockingSources = append(blockingSources, src)
+				}
+			}
+			sinks = append(sinks, mq.Sink{Name: dest.Name, Publisher: p, Policy: policy, QueueSize: dest.QueueSize})
+
+			logger.Log.Infow("Destino de publicação configurado",
+				"destination", dest.Name,
+				"protocol", dest.Protocol,
+				"policy", policy)
 		}
-		publisher = p
+		publisher = mq.NewCompositePublisher(sinks...)
 	} else {
-		p, err := mq.NewAMQPPublisher(cfg.AMQP.AmqpURL, cfg.AMQP.Exchange, cfg.AMQP.RoutingKeyPrefix)
+		p, amqpP, err := newProtocolPublisher(config.DestinationConfig{
+			Protocol: cfg.Protocol,
+			AMQP:     cfg.AMQP,
+			MQTT:     cfg.MQTT,
+			Kafka:    cfg.Kafka,
+			NATS:     cfg.NATS,
+		}, cfg.Cameras, vhost)
 		if err != nil {
-			logger.Log.Fatalw("Erro ao criar amqp publisher", "error", err)
+			logger.Log.Fatalw("Erro ao criar publisher", "protocol", cfg.Protocol, "error", err)
 		}
 		publisher = p
-		amqpPublisher = p
+		amqpPublisher = amqpP
+		if sink, ok := p.(metadata.StatusSink); ok {
+			statusSinks = append(statusSinks, sink)
+		}
+		if amqpP != nil {
+			amqpSources = append(amqpSources, amqpSource{name: "amqp", publisher: amqpP})
+			blockingSources = amqpSources
+		}
+	}
+
+	var spoolPublisher *mq.SpoolPublisher
+	if cfg.Spool.Enabled {
+		spoolDir := cfg.Spool.Dir
+		if spoolDir == "" {
+			spoolDir = "/var/lib/edge-video/spool"
+		}
+		maxBytesMB := cfg.Spool.MaxBytesMB
+		if maxBytesMB <= 0 {
+			maxBytesMB = 1024
+		}
+		replayRate := cfg.Spool.ReplayRate
+		if replayRate <= 0 {
+			replayRate = 50
+		}
+
+		spool, err := mq.OpenSpool(mq.SpoolConfig{
+			Dir:          spoolDir,
+			SegmentBytes: int64(cfg.Spool.SegmentSizeMB) << 20,
+			MaxBytes:     int64(maxBytesMB) << 20,
+			MaxAge:       time.Duration(cfg.Spool.MaxAgeSeconds) * time.Second,
+			Mode:         mq.SpoolMode(cfg.Spool.Mode),
+		})
+		if err != nil {
+			logger.Log.Fatalw("Erro ao abrir spool em disco", "dir", spoolDir, "error", err)
+		}
+		spoolPublisher = mq.NewSpoolPublisher(publisher, spool, replayRate)
+		publisher = spoolPublisher
+
+		logger.Log.Infow("Spool em disco habilitado",
+			"dir", spoolDir,
+			"mode", cfg.Spool.Mode,
+			"max_bytes_mb", maxBytesMB,
+			"replay_rate", replayRate)
 	}
-	defer publisher.Close()
 
 	// Cria RedisStore usando o vhost como identificador do cliente
 	// Isso garante isolamento entre múltiplas instâncias usando diferentes vhosts
-	redisStore := storage.NewRedisStore(
+	redisTLS, err := loadTLSConfig("redis", cfg.Redis.TLS)
+	if err != nil {
+		logger.Log.Fatalw("Erro ao carregar TLS do Redis", "error", err)
+	}
+	redisStore := storage.NewRedisStoreWithTLS(
 		cfg.Redis.Address,
 		cfg.Redis.TTLSeconds,
 		cfg.Redis.Prefix,
@@ -183,6 +313,7 @@ func main() {
 		cfg.Redis.Enabled,
 		cfg.Redis.Username,
 		cfg.Redis.Password,
+		redisTLS,
 	)
 	if redisStore.Enabled() {
 		logger.Log.Infow("Redis Store configurado",
@@ -193,16 +324,41 @@ func main() {
 
 	var metaPublisher *metadata.Publisher
 	if amqpPublisher != nil {
-		metaPublisher = metadata.NewPublisher(amqpPublisher.GetChannel(), cfg.Metadata.Exchange, cfg.Metadata.RoutingKey, cfg.Metadata.Enabled)
+		if cfg.Metadata.Enabled {
+			metaExchange := mq.AMQPExchange{Name: cfg.Metadata.Exchange, Type: "topic", Durable: true}
+			if err := amqpPublisher.DeclareExchange(metaExchange); err != nil {
+				logger.Log.Fatalw("Erro ao declarar o exchange de metadados", "exchange", cfg.Metadata.Exchange, "error", err)
+			}
+		}
+		metaPublisher = metadata.NewPublisher(amqpPublisher, cfg.Metadata.Exchange, cfg.Metadata.RoutingKey, cfg.Metadata.Enabled)
 	} else {
 		metaPublisher = metadata.NewPublisher(nil, "", "", false)
 	}
+	for _, sink := range statusSinks {
+		metaPublisher.AddStatusSink(sink)
+	}
+
+	if spoolPublisher != nil && metaPublisher.Enabled() {
+		// No modo metadata o spool reenvia o evento do frame com a chave em que
+		// ele foi gravado no Redis. Sem Redis o frame não tem metadados, como
+		// na publicação ao vivo
+		spoolPublisher.SetMetadataReplay(func(ctx context.Context, entry mq.SpoolEntry) error {
+			if entry.RedisKey == "" {
+				return nil
+			}
+			encoding := "jpeg"
+			if entry.ContentEncoding == mq.ContentEncodingZstd {
+				encoding = "jpeg+zstd"
+			}
+			return metaPublisher.PublishMetadata(entry.CameraID, entry.Timestamp, entry.RedisKey, entry.Width, entry.Height, entry.Size, encoding)
+		})
+	}
 
 	// Cria o monitor de câmeras
 	cameraMonitor := camera.NewMonitor(ctx, 30*time.Second)
 	
 	// Configura callbacks para publicar eventos de status
-	if metaPublisher.Enabled() {
+	if metaPublisher.StatusEnabled() {
 		cameraMonitor.SetCallbacks(
 			// onAllInactive
 			func(cameraID string) {
@@ -276,24 +432,102 @@ func main() {
 	
 	cameraMonitor.Start()
 
+	// Com o broker bloqueado (alarme de memória ou disco) a captura é pausada
+	// ou reduzida em vez de continuar empurrando frames que vão expirar
+	brokerGate := camera.NewBrokerGate(time.Duration(cfg.Optimization.BrokerBlockedIntervalMs) * time.Millisecond)
+	watchBrokerBlocked(ctx, blockingSources, brokerGate, metaPublisher, cameraMonitor)
+	watchUnroutable(amqpSources, metaPublisher)
+
+	// O compressor é compartilhado pelas câmeras que usam compressão
+	compressionWanted := cfg.Compression.Enabled
+	for _, camCfg := range cfg.Cameras {
+		if camCfg.Compression != nil && *camCfg.Compression {
+			compressionWanted = true
+		}
+	}
+
 	var compressor *util.Compressor
-	if cfg.Compression.Enabled {
+	if compressionWanted {
 		comp, err := util.NewCompressor(cfg.Compression.Level)
 		if err != nil {
 			logger.Log.Fatalw("Erro ao criar compressor", "error", err)
 		}
 		compressor = comp
 	}
+	if dests := cfg.CompressedMQTTDestinations(); len(dests) > 0 {
+		logger.Log.Warnw("Destinos MQTT recebem frames comprimidos sem o content-encoding (MQTT 3.1.1 não tem user properties)",
+			"destinations", dests)
+	}
+
+	// Ausente usa 250ms; 0 desliga o escalonamento
+	startupStagger := 250 * time.Millisecond
+	if cfg.Optimization.StartupStaggerMs != nil {
+		startupStagger = time.Duration(max(*cfg.Optimization.StartupStaggerMs, 0)) * time.Millisecond
+	}
+	startCoordinator := camera.NewStartCoordinator(
+		startupStagger,
+		time.Duration(cfg.Optimization.StartupJitterMs)*time.Millisecond,
+		cfg.Optimization.MaxConcurrentStarts,
+		0,
+	)
+
+	maxSpawns := cfg.Optimization.MaxConcurrentSpawns
+	if maxSpawns <= 0 {
+		maxSpawns = runtime.NumCPU() * 2
+	}
+	spawnMaxWait := time.Duration(cfg.Optimization.SpawnMaxWaitMs) * time.Millisecond
+	if spawnMaxWait <= 0 {
+		spawnMaxWait = 2 * interval
+		if spawnMaxWait < time.Second {
+			spawnMaxWait = time.Second
+		}
+	}
+	spawnBudget := camera.NewSpawnBudget(maxSpawns, spawnMaxWait)
+
+	// Ausente usa 20%; 0 desliga o jitter
+	circuitJitter := 0.2
+	if cfg.Optimization.CircuitJitterPercent != nil {
+		circuitJitter = max(*cfg.Optimization.CircuitJitterPercent, 0) / 100
+	}
 
 	go startMetricsServer(":9090")
 
 	go monitorSystem(workerPool)
 
+	captures := make([]*camera.Capture, 0, len(cfg.Cameras))
 	for _, camCfg := range cfg.Cameras {
 		// Registra a câmera no monitor
 		cameraMonitor.RegisterCamera(camCfg.ID)
+		workerPool.SetWeight(camCfg.ID, camCfg.Priority)
 		
-		frameBuffer := buffer.NewFrameBuffer(cameraBufferSize)
+		dropPolicyName := cfg.Optimization.DropPolicy
+		if camCfg.DropPolicy != "" {
+			dropPolicyName = camCfg.DropPolicy
+		}
+		if dropPolicyName == "" && cfg.Optimization.UsePersistent {
+			// Comportamento histórico da captura persistente: só o frame mais recente
+			dropPolicyName = string(buffer.LatestOnly)
+		}
+		dropPolicy, err := buffer.ParseDropPolicy(dropPolicyName)
+		if err != nil {
+			logger.Log.Fatalw("Configuração de câmera inválida", "camera_id", camCfg.ID, "error", err)
+		}
+		decimateEvery := cfg.Optimization.DecimateEvery
+		if camCfg.DecimateEvery > 0 {
+			decimateEvery = camCfg.DecimateEvery
+		}
+		maxFrameAge := time.Duration(cfg.Optimization.MaxFrameAgeMs) * time.Millisecond
+		if camCfg.MaxFrameAgeMs > 0 {
+			maxFrameAge = time.Duration(camCfg.MaxFrameAgeMs) * time.Millisecond
+		}
+
+		budgetMB := cfg.Optimization.CameraBufferBudgetMB
+		if camCfg.BufferBudgetMB > 0 {
+			budgetMB = camCfg.BufferBudgetMB
+		}
+
+		frameBuffer := buffer.NewFrameBufferWithPolicy(cameraBufferSize, dropPolicy, decimateEvery)
+		frameBuffer.SetByteBudget(buffer.NewByteBudget(camCfg.ID, int64(budgetMB)<<20, globalBudget))
 
 		resetTimeout := time.Duration(cfg.Optimization.CircuitResetSec) * time.Second
 		if resetTimeout == 0 {
@@ -306,12 +540,22 @@ func main() {
 		}
 
 		circuitBreaker := circuit.NewBreaker(camCfg.ID, maxFailures, resetTimeout)
+		circuitBreaker.SetJitter(circuitJitter)
+
+		compressionEnabled := cfg.Compression.Enabled
+		if camCfg.Compression != nil {
+			compressionEnabled = *camCfg.Compression
+		}
+		var cameraCompressor *util.Compressor
+		if compressionEnabled {
+			cameraCompressor = compressor
+		}
 
 		capture := camera.NewCapture(
 			ctx,
-			camera.Config{ID: camCfg.ID, URL: camCfg.URL},
+			camera.Config{ID: camCfg.ID, URL: camCfg.URL, ProbeTimeout: probeTimeout, MaxFrameAge: maxFrameAge},
 			interval,
-			compressor,
+			cameraCompressor,
 			publisher,
 			redisStore,
 			metaPublisher,
@@ -322,9 +566,13 @@ func main() {
 			persistentBufferSize,
 			cameraMonitor,
 			memController,
+			startCoordinator,
+			spawnBudget,
+			brokerGate,
 		)
 
 		capture.Start()
+		captures = append(captures, capture)
 
 		logger.Log.Infow("Câmera iniciada",
 			"camera_id", camCfg.ID,
@@ -337,11 +585,419 @@ func main() {
 	<-sig
 
 	logger.Log.Info("Recebido sinal de finalização, encerrando...")
+
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeoutSec) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 15 * time.Second
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	// 1. Para as capturas e entrega ao worker pool os frames já bufferizados
+	var (
+		shutdownMu      sync.Mutex
+		shutdownWG      sync.WaitGroup
+		framesFlushed   int
+		framesAbandoned int
+	)
+	for _, capture := range captures {
+		shutdownWG.Add(1)
+		go func(capture *camera.Capture) {
+			defer shutdownWG.Done()
+			flushed, abandoned := capture.Shutdown(shutdownCtx)
+			shutdownMu.Lock()
+			framesFlushed += flushed
+			framesAbandoned += abandoned
+			shutdownMu.Unlock()
+		}(capture)
+	}
+	shutdownWG.Wait()
+
+	// 2. Drena o worker pool
+	jobsAbandoned := workerPool.Shutdown(shutdownCtx)
+
+	// 3. Aguarda as confirmações pendentes do broker
+	if err := mq.Flush(shutdownCtx, publisher); err != nil {
+		logger.Log.Warnw("Mensagens pendentes não confirmadas no encerramento", "error", err)
+	}
+
+	// 4. Publica o estado final das câmeras e do sistema
+	if metaPublisher.StatusEnabled() {
+		for _, camCfg := range cfg.Cameras {
+			if err := metaPublisher.PublishCameraStatus(camCfg.ID, metadata.CameraStateOffline, 0, nil, "Serviço encerrado"); err != nil {
+				logger.Log.Errorw("Erro ao publicar evento de câmera offline",
+					"camera_id", camCfg.ID,
+					"error", err)
+			}
+		}
+		if err := metaPublisher.PublishSystemStatus(len(cfg.Cameras), 0, len(cfg.Cameras), "Serviço encerrado"); err != nil {
+			logger.Log.Errorw("Erro ao publicar evento de sistema encerrado", "error", err)
+		}
+	}
+
+	// 5. Fecha as conexões
+	if err := publisher.Close(); err != nil {
+		logger.Log.Warnw("Erro ao fechar publisher", "error", err)
+	}
+	if err := redisStore.Close(); err != nil {
+		logger.Log.Warnw("Erro ao fechar Redis", "error", err)
+	}
 	cancel()
 
-	time.Sleep(2 * time.Second)
+	logger.Log.Infow("Aplicação finalizada",
+		"frames_flushed", framesFlushed,
+		"frames_abandoned", framesAbandoned+jobsAbandoned,
+		"shutdown_timeout", shutdownTimeout)
+}
+
+// amqpSource é o publisher AMQP de um destino.
+type amqpSource struct {
+	name      string
+	publisher *mq.AMQPPublisher
+}
+
+type brokerBlockEvent struct {
+	source  string
+	blocked bool
+	reason  string
+}
+
+// watchBrokerBlocked repassa o bloqueio de cada publisher AMQP para o gate da
+// captura e publica um evento de status do sistema a cada mudança.
+func watchBrokerBlocked(ctx context.Context, sources []amqpSource, gate *camera.BrokerGate, metaPublisher *metadata.Publisher, monitor *camera.Monitor) {
+	if len(sources) == 0 {
+		return
+	}
+
+	// Os callbacks rodam na leitura da conexão AMQP e não podem bloquear: os
+	// eventos seguem por uma fila, em ordem, para uma goroutine própria
+	events := make(chan brokerBlockEvent, 16)
+	for _, src := range sources {
+		name := src.name
+		src.publisher.OnBlocked(func(blocked bool, reason string) {
+			gate.SetBlocked(name, blocked, reason)
+			select {
+			case events <- brokerBlockEvent{source: name, blocked: blocked, reason: reason}:
+			default:
+				logger.Log.Warnw("Fila de eventos de bloqueio do broker cheia", "destination", name)
+			}
+		})
+	}
+
+	go func() {
+		for {
+			var ev brokerBlockEvent
+			select {
+			case <-ctx.Done():
+				return
+			case ev = <-events:
+			}
+
+			var message string
+			if ev.blocked {
+				logger.Log.Warnw("Broker AMQP bloqueou as publicações, captura restringida",
+					"destination", ev.source,
+					"reason", ev.reason)
+				message = fmt.Sprintf("ALERTA: broker AMQP %s bloqueou as publicações (%s). Captura pausada ou reduzida.", ev.source, ev.reason)
+			} else {
+				logger.Log.Infow("Broker AMQP liberou as publicações",
+					"destination", ev.source)
+				message = fmt.Sprintf("Broker AMQP %s liberou as publicações. Captura retomada.", ev.source)
+			}
+
+			if !metaPublisher.StatusEnabled() {
+				continue
+			}
+			allStatus := monitor.GetAllStatus()
+			active := 0
+			for _, status := range allStatus {
+				if status.IsActive {
+					active++
+				}
+			}
+			if err := metaPublisher.PublishSystemStatus(len(allStatus), active, len(allStatus)-active, message); err != nil {
+				logger.Log.Errorw("Erro ao publicar evento de bloqueio do broker", "error", err)
+			}
+		}
+	}()
+}
+
+// watchUnroutable alerta quando os frames de uma câmera voltam do broker sem
+// fila de destino (amqp.mandatory) e quando voltam a ser roteados.
+func watchUnroutable(sources []amqpSource, metaPublisher *metadata.Publisher) {
+	for _, src := range sources {
+		name := src.name
+		src.publisher.OnUnroutable(func(ev mq.UnroutableEvent) {
+			state := metadata.CameraStateActive
+			message := "Frames da câmera voltaram a ser roteados pelo broker"
+			if ev.Unroutable {
+				logger.Log.Errorw("ALERTA: frames da câmera sem fila de destino no broker",
+					"destination", name,
+					"camera_id", ev.CameraID,
+					"exchange", ev.Exchange,
+					"routing_key", ev.RoutingKey,
+					"since", ev.Since,
+					"returned", ev.Returns)
+				state = metadata.CameraStateUnroutable
+				message = fmt.Sprintf("ALERTA: nenhuma fila recebe os frames (exchange %q, routing key %q) desde %s; %d frames devolvidos pelo broker",
+					ev.Exchange, ev.RoutingKey, ev.Since.Format(time.RFC3339), ev.Returns)
+			} else {
+				logger.Log.Infow("Frames da câmera voltaram a ser roteados",
+					"destination", name,
+					"camera_id", ev.CameraID,
+					"returned", ev.Returns)
+			}
+
+			if !metaPublisher.StatusEnabled() {
+				return
+			}
+			if err := metaPublisher.PublishCameraStatus(ev.CameraID, state, 0, nil, message); err != nil {
+				logger.Log.Errorw("Erro ao publicar evento de câmera sem rota",
+					"camera_id", ev.CameraID,
+					"error", err)
+			}
+		})
+	}
+}
+
+// newProtocolPublisher cria o publisher de um destino. Para AMQP também
+// retorna o *mq.AMQPPublisher, cujo canal é usado pelos metadados. vhost é o
+// identificador do cliente usado em {vhost} nos tópicos do Kafka e subjects
+// do NATS; no AMQP vale o vhost da URL do destino.
+func newProtocolPublisher(dest config.DestinationConfig, cameras []config.CameraConfig, vhost string) (mq.Publisher, *mq.AMQPPublisher, error) {
+	amqpCfg, mqttCfg := dest.AMQP, dest.MQTT
+
+	// Identifica a conexão nas métricas de certificados TLS
+	connName := dest.Name
+	if connName == "" {
+		connName = dest.Protocol
+	}
+
+	switch dest.Protocol {
+	case "kafka":
+		return newKafkaPublisher(connName, dest.Kafka, cameras, vhost)
+
+	case "nats":
+		return newNATSPublisher(connName, dest.NATS, cameras, vhost)
+
+	case "mqtt":
+		tlsConfig, err := loadTLSConfig(connName, mqttCfg.TLS)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mqtt tls: %w", err)
+		}
+		opts := mq.DefaultMQTTOptions()
+		opts.ClientID = mqttCfg.ClientID
+		opts.Username = mqttCfg.Username
+		opts.Password = mqttCfg.Password
+		opts.TLS = tlsConfig
+		if mqttCfg.QoS != nil {
+			if *mqttCfg.QoS < 0 || *mqttCfg.QoS > 2 {
+				return nil, nil, fmt.Errorf("mqtt: qos inválido: %d", *mqttCfg.QoS)
+			}
+			opts.QoS = byte(*mqttCfg.QoS)
+		}
+		opts.PublishTimeout = time.Duration(mqttCfg.PublishTimeoutMs) * time.Millisecond
+		opts.RetainLatest = mqttCfg.RetainLatest
+		opts.StatusTopic = mqttCfg.StatusTopic
+
+		p, err := mq.NewMQTTPublisherWithOptions(mqttCfg.Broker, mqttCfg.TopicPrefix, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, nil, nil
+
+	case "", "amqp":
+		if connName == "" {
+			connName = "amqp"
+		}
+		tlsConfig, err := loadTLSConfig(connName, amqpCfg.TLS)
+		if err != nil {
+			return nil, nil, fmt.Errorf("amqp tls: %w", err)
+		}
+		confirmTimeout := time.Duration(amqpCfg.ConfirmTimeoutMs) * time.Millisecond
+		if confirmTimeout <= 0 {
+			confirmTimeout = 5 * time.Second
+		}
+		channels := amqpCfg.ChannelPoolSize
+		if channels <= 0 {
+			channels = 4
+		}
+		unroutableAlert := time.Duration(amqpCfg.UnroutableAlertSeconds) * time.Second
+		if unroutableAlert <= 0 {
+			unroutableAlert = time.Minute
+		}
 
-	logger.Log.Info("Aplicação finalizada")
+		// Exchange e routing key por câmera (templates já validados no
+		// carregamento da configuração)
+		vhost, err := mq.ExtractVhostFromURL(amqpCfg.AmqpURL)
+		if err != nil {
+			vhost = "/"
+		}
+		routes := make(map[string]mq.AMQPRoute)
+		for _, cam := range cameras {
+			if cam.Exchange == "" && cam.RoutingKey == "" {
+				continue
+			}
+			exchange, routingKey, err := cam.AMQPRoute(amqpCfg, vhost)
+			if err != nil {
+				return nil, nil, err
+			}
+			routes[cam.ID] = mq.AMQPRoute{Exchange: exchange, RoutingKey: routingKey}
+		}
+		p, err := mq.NewAMQPPublisherWithOptions(amqpCfg.AmqpURL, amqpCfg.Exchange, amqpCfg.RoutingKeyPrefix, mq.AMQPOptions{
+			Confirms:        amqpCfg.PublisherConfirms,
+			ConfirmTimeout:  confirmTimeout,
+			WaitForConfirm:  amqpCfg.WaitForConfirms,
+			TLS:             tlsConfig,
+			Routes:          routes,
+			Channels:        channels,
+			Name:            connName,
+			Mandatory:       amqpCfg.Mandatory,
+			UnroutableAlert: unroutableAlert,
+			Topology:        amqpTopology(amqpCfg.Topology),
+			FrameExpiration: time.Duration(amqpCfg.FrameExpirationMs) * time.Millisecond,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, p, nil
+
+	default:
+		return nil, nil, fmt.Errorf("protocolo desconhecido: %q", dest.Protocol)
+	}
+}
+
+// amqpTopology converte a topologia da configuração. Exchanges sem tipo são
+// topic e, como filas, duráveis salvo durable = false.
+func amqpTopology(cfg config.AMQPTopologyConfig) mq.AMQPTopology {
+	durable := func(v *bool) bool { return v == nil || *v }
+
+	var topology mq.AMQPTopology
+	for _, ex := range cfg.Exchanges {
+		kind := ex.Type
+		if kind == "" {
+			kind = "topic"
+		}
+		topology.Exchanges = append(topology.Exchanges, mq.AMQPExchange{
+			Name:       ex.Name,
+			Type:       kind,
+			Durable:    durable(ex.Durable),
+			AutoDelete: ex.AutoDelete,
+			Internal:   ex.Internal,
+		})
+	}
+	for _, q := range cfg.Queues {
+		topology.Queues = append(topology.Queues, mq.AMQPQueue{
+			Name:                 q.Name,
+			Durable:              durable(q.Durable),
+			AutoDelete:           q.AutoDelete,
+			MessageTTL:           time.Duration(q.MessageTTLMs) * time.Millisecond,
+			MaxLength:            q.MaxLength,
+			MaxLengthBytes:       q.MaxLengthBytes,
+			Overflow:             q.Overflow,
+			DeadLetterExchange:   q.DeadLetterExchange,
+			DeadLetterRoutingKey: q.DeadLetterRoutingKey,
+		})
+	}
+	for _, b := range cfg.Bindings {
+		topology.Bindings = append(topology.Bindings, mq.AMQPBinding{
+			Exchange:   b.Exchange,
+			Queue:      b.Queue,
+			RoutingKey: b.RoutingKey,
+			Headers:    b.Headers,
+			Match:      b.Match,
+		})
+	}
+	return topology
+}
+
+func newNATSPublisher(connName string, natsCfg config.NATSConfig, cameras []config.CameraConfig, vhost string) (mq.Publisher, *mq.AMQPPublisher, error) {
+	tlsConfig, err := loadTLSConfig(connName, natsCfg.TLS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("nats tls: %w", err)
+	}
+
+	// Subject por câmera (template já validado no carregamento da configuração)
+	subjects := make(map[string]string, len(cameras))
+	for _, cam := range cameras {
+		subject, err := cam.NATSSubject(natsCfg, vhost)
+		if err != nil {
+			return nil, nil, err
+		}
+		subjects[cam.ID] = subject
+	}
+
+	p, err := mq.NewNATSPublisher(mq.NATSConfig{
+		URL:           natsCfg.URL,
+		Subjects:      subjects,
+		StreamSubject: natsCfg.StreamSubject(),
+		Name:          natsCfg.Name,
+		Username:      natsCfg.Username,
+		Password:      natsCfg.Password,
+		Token:         natsCfg.Token,
+		TLS:           tlsConfig,
+		JetStream:     natsCfg.JetStream,
+		Stream:        natsCfg.Stream,
+		MaxAge:        time.Duration(natsCfg.MaxAgeSeconds) * time.Second,
+		MaxBytes:      natsCfg.MaxBytes,
+		ObjectBucket:  natsCfg.ObjectBucket,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, nil, nil
+}
+
+// loadTLSConfig retorna nil quando o TLS não está habilitado.
+func loadTLSConfig(connName string, tlsCfg config.TLSConfig) (*tls.Config, error) {
+	if !tlsCfg.Enabled {
+		return nil, nil
+	}
+	return util.LoadTLSConfig(util.TLSOptions{
+		Name:               connName,
+		CAFile:             tlsCfg.CAFile,
+		CertFile:           tlsCfg.CertFile,
+		KeyFile:            tlsCfg.KeyFile,
+		ServerName:         tlsCfg.ServerName,
+		InsecureSkipVerify: tlsCfg.InsecureSkipVerify,
+	})
+}
+
+func newKafkaPublisher(connName string, kafkaCfg config.KafkaConfig, cameras []config.CameraConfig, vhost string) (mq.Publisher, *mq.AMQPPublisher, error) {
+	tlsConfig, err := loadTLSConfig(connName, kafkaCfg.TLS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kafka tls: %w", err)
+	}
+
+	// Tópico por câmera (template já validado no carregamento da configuração)
+	topics := make(map[string]string, len(cameras))
+	for _, cam := range cameras {
+		topic, err := cam.KafkaTopic(kafkaCfg, vhost)
+		if err != nil {
+			return nil, nil, err
+		}
+		topics[cam.ID] = topic
+	}
+
+	p, err := mq.NewKafkaPublisher(mq.KafkaConfig{
+		Brokers:            kafkaCfg.Brokers,
+		Topics:             topics,
+		ClientID:           kafkaCfg.ClientID,
+		Linger:             time.Duration(kafkaCfg.LingerMs) * time.Millisecond,
+		BatchMaxBytes:      int32(kafkaCfg.BatchMaxBytes),
+		Compression:        kafkaCfg.Compression,
+		Acks:               kafkaCfg.Acks,
+		DisableIdempotence: kafkaCfg.DisableIdempotence,
+		AutoCreateTopics:   kafkaCfg.AutoCreateTopics,
+		TLS:                tlsConfig,
+		SASLMechanism:      kafkaCfg.SASL.Mechanism,
+		SASLUsername:       kafkaCfg.SASL.Username,
+		SASLPassword:       kafkaCfg.SASL.Password,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, nil, nil
 }
 
 func startMetricsServer(addr string) {
@@ -363,6 +1019,7 @@ func monitorSystem(pool *worker.Pool) {
 
 		metrics.WorkerPoolQueueSize.WithLabelValues("main").Set(float64(stats.QueueSize))
 		metrics.WorkerPoolProcessing.WithLabelValues("main").Set(float64(stats.Processing))
+		metrics.WorkerPoolWorkers.WithLabelValues("main").Set(float64(stats.Workers))
 
 		var memStats runtime.MemStats
 		runtime.ReadMemStats(&memStats)
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/streadway/amqp"
@@ -21,38 +22,44 @@ const (
 	CameraStateActive   CameraState = "active"
 	CameraStateInactive CameraState = "inactive"
 	CameraStateOffline  CameraState = "offline"
+	// CameraStateUnroutable: the camera captures, but the broker has no
+	// queue bound to its frames (mandatory publishing).
+	CameraStateUnroutable CameraState = "unroutable"
 )
 
+// StatusSink receives camera and system status events in addition to the
+// AMQP exchange. mq.MQTTPublisher implements it, so MQTT-only sites also get
+// the events.
+type StatusSink interface {
+	PublishCameraStatus(cameraID string, body []byte) error
+	PublishSystemStatus(body []byte) error
+}
+
+// Channel publishes messages on the current AMQP connection.
+// mq.AMQPPublisher implements it.
+type Channel interface {
+	PublishMessage(exchange, routingKey string, msg amqp.Publishing) error
+}
+
 // Publisher handles publishing frame metadata to RabbitMQ.
 type Publisher struct {
-	channel    *amqp.Channel
+	channel    Channel
 	exchange   string
 	routingKey string
 	enabled    bool
+	sinks      []StatusSink
 }
 
-// NewPublisher creates a new metadata Publisher.
-func NewPublisher(ch *amqp.Channel, exchange, routingKey string, enabled bool) *Publisher {
-	// Se estiver habilitado e o canal existir, declara o exchange
-	if enabled && ch != nil {
-		err := ch.ExchangeDeclare(
-			exchange,
-			"topic",
-			true,  // durable
-			false, // auto-deleted
-			false, // internal
-			false, // no-wait
-			nil,   // arguments
-		)
-		if err != nil {
-			// Log o erro mas não falha a criação do publisher
-			// Isso permite que a aplicação continue mesmo se o exchange já existir
-			// ou houver problemas temporários
-		}
-	}
-
+// NewPublisher creates a new metadata Publisher. Messages are published
+// through channel (e.g. mq.AMQPPublisher), which follows the frame publisher
+// across reconnects instead of keeping a closed channel.
+// It does not declare the exchange: a refused declaration would close the
+// channel, which is shared with the frame publisher. The caller declares it
+// instead (see mq.AMQPPublisher.DeclareExchange), so it is redeclared on
+// every reconnect and a mismatch with the broker is reported.
+func NewPublisher(channel Channel, exchange, routingKey string, enabled bool) *Publisher {
 	return &Publisher{
-		channel:    ch,
+		channel:    channel,
 		exchange:   exchange,
 		routingKey: routingKey,
 		enabled:    enabled,
@@ -64,6 +71,18 @@ func (p *Publisher) Enabled() bool {
 	return p.enabled
 }
 
+// AddStatusSink registers a sink for status events. Must be called before
+// the publisher is used.
+func (p *Publisher) AddStatusSink(sink StatusSink) {
+	p.sinks = append(p.sinks, sink)
+}
+
+// StatusEnabled returns true if status events go anywhere: the AMQP
+// exchange or a StatusSink.
+func (p *Publisher) StatusEnabled() bool {
+	return p.enabled || len(p.sinks) > 0
+}
+
 // Metadata represents the structure of the metadata message.
 type Metadata struct {
 	EventType EventType `json:"event_type"`
@@ -117,21 +136,13 @@ func (p *Publisher) PublishMetadata(cameraID string, timestamp time.Time, redisK
 		return err
 	}
 
-	return p.channel.Publish(
-		p.exchange,
-		p.routingKey,
-		false, // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
+	return p.publish(p.routingKey, body)
 }
 
-// PublishCameraStatus sends camera status change events to RabbitMQ.
+// PublishCameraStatus sends camera status change events to RabbitMQ and to
+// the status sinks.
 func (p *Publisher) PublishCameraStatus(cameraID string, state CameraState, consecutiveFailures int, lastError error, message string) error {
-	if !p.enabled {
+	if !p.StatusEnabled() {
 		return nil
 	}
 
@@ -153,21 +164,20 @@ func (p *Publisher) PublishCameraStatus(cameraID string, state CameraState, cons
 		return err
 	}
 
-	return p.channel.Publish(
-		p.exchange,
-		p.routingKey+".status",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
+	var errs []error
+	for _, sink := range p.sinks {
+		errs = append(errs, sink.PublishCameraStatus(cameraID, body))
+	}
+	if p.enabled {
+		errs = append(errs, p.publish(p.routingKey+".status", body))
+	}
+	return errors.Join(errs...)
 }
 
-// PublishSystemStatus sends system-wide status events to RabbitMQ.
+// PublishSystemStatus sends system-wide status events to RabbitMQ and to
+// the status sinks.
 func (p *Publisher) PublishSystemStatus(totalCameras, activeCameras, inactiveCameras int, message string) error {
-	if !p.enabled {
+	if !p.StatusEnabled() {
 		return nil
 	}
 
@@ -185,11 +195,25 @@ func (p *Publisher) PublishSystemStatus(totalCameras, activeCameras, inactiveCam
 		return err
 	}
 
-	return p.channel.Publish(
+	var errs []error
+	for _, sink := range p.sinks {
+		errs = append(errs, sink.PublishSystemStatus(body))
+	}
+	if p.enabled {
+		errs = append(errs, p.publish(p.routingKey+".system", body))
+	}
+	return errors.Join(errs...)
+}
+
+// publish sends body to the metadata exchange on the current channel.
+func (p *Publisher) publish(routingKey string, body []byte) error {
+	if p.channel == nil {
+		return errors.New("amqp channel unavailable")
+	}
+
+	return p.channel.PublishMessage(
 		p.exchange,
-		p.routingKey+".system",
-		false,
-		false,
+		routingKey,
 		amqp.Publishing{
 			ContentType: "application/json",
 			Body:        body,
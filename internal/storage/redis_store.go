@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -25,6 +26,12 @@ type RedisStore struct {
 // NewRedisStore creates a new RedisStore.
 // vhost é o identificador único do cliente (extraído do AMQP vhost)
 func NewRedisStore(addr string, ttlSeconds int, prefix string, vhost string, enabled bool, username string, password string) *RedisStore {
+	return NewRedisStoreWithTLS(addr, ttlSeconds, prefix, vhost, enabled, username, password, nil)
+}
+
+// NewRedisStoreWithTLS creates a RedisStore that connects over TLS when
+// tlsConfig is not nil.
+func NewRedisStoreWithTLS(addr string, ttlSeconds int, prefix string, vhost string, enabled bool, username string, password string, tlsConfig *tls.Config) *RedisStore {
 	if !enabled {
 		return &RedisStore{enabled: false}
 	}
@@ -38,6 +45,7 @@ func NewRedisStore(addr string, ttlSeconds int, prefix string, vhost string, ena
 		PoolTimeout:  4 * time.Second,
 		PoolSize:     64,
 		MinIdleConns: 8,
+		TLSConfig:    tlsConfig,
 	}
 
 	if username != "" {
@@ -66,6 +74,18 @@ func NewRedisStore(addr string, ttlSeconds int, prefix string, vhost string, ena
 	}
 }
 
+// Close closes the Redis client and its connection pool.
+func (r *RedisStore) Close() error {
+	if !r.enabled {
+		return nil
+	}
+	client := r.getClient()
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
 // Enabled returns true if the Redis store is enabled.
 func (r *RedisStore) Enabled() bool {
 	return r.enabled
@@ -79,18 +99,41 @@ func (r *RedisStore) SaveFrame(ctx context.Context, cameraID string, timestamp t
 		return "", nil
 	}
 
-	key := r.keyGenerator.GenerateKey(cameraID, timestamp)
+	key := r.FrameKey(cameraID, timestamp)
+	if err := r.SaveFrameWithKey(ctx, key, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// FrameKey generates the key for a new frame. The caller saves it later with
+// SaveFrameWithKey, which lets the key travel with the frame before it is
+// stored (e.g. in the message spool). Returns "" when the store is disabled.
+func (r *RedisStore) FrameKey(cameraID string, timestamp time.Time) string {
+	if !r.enabled {
+		return ""
+	}
+	return r.keyGenerator.GenerateKey(cameraID, timestamp)
+}
+
+// SaveFrameWithKey stores a frame under a key from FrameKey with the
+// configured TTL.
+func (r *RedisStore) SaveFrameWithKey(ctx context.Context, key string, data []byte) error {
+	if !r.enabled {
+		return nil
+	}
+
 	var lastErr error
 
 	for attempt := 0; attempt < 2; attempt++ {
 		client := r.getClient()
 		if client == nil {
-			return "", fmt.Errorf("failed to save frame to redis: client not initialized")
+			return fmt.Errorf("failed to save frame to redis: client not initialized")
 		}
 
 		err := client.Set(ctx, key, data, r.ttl).Err()
 		if err == nil {
-			return key, nil
+			return nil
 		}
 
 		lastErr = err
@@ -105,7 +148,7 @@ func (r *RedisStore) SaveFrame(ctx context.Context, cameraID string, timestamp t
 		}
 	}
 
-	return "", fmt.Errorf("failed to save frame to redis: %w", lastErr)
+	return fmt.Errorf("failed to save frame to redis: %w", lastErr)
 }
 
 // GetFrame retrieves a frame by its exact key
@@ -0,0 +1,92 @@
+package buffer
+
+import (
+	"sync"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// ByteBudget limita quantos bytes de frames podem estar retidos no pipeline.
+// Orçamentos podem ser encadeados: uma reserva no orçamento da câmera também
+// consome o orçamento global (parent). Um ByteBudget nil não aplica limite.
+type ByteBudget struct {
+	name   string
+	limit  int64 // Zero = sem limite, apenas contabiliza
+	parent *ByteBudget
+
+	mu   sync.Mutex
+	used int64
+}
+
+// NewByteBudget cria um orçamento de limit bytes. parent pode ser nil.
+func NewByteBudget(name string, limit int64, parent *ByteBudget) *ByteBudget {
+	if limit < 0 {
+		limit = 0
+	}
+	return &ByteBudget{name: name, limit: limit, parent: parent}
+}
+
+// TryReserve reserva n bytes neste orçamento e em todos os ancestrais. Se
+// algum deles não comportar a reserva, nada é reservado e retorna false.
+func (b *ByteBudget) TryReserve(n int64) bool {
+	if b == nil || n <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	if b.limit > 0 && b.used+n > b.limit {
+		b.mu.Unlock()
+		return false
+	}
+	b.used += n
+	used := b.used
+	b.mu.Unlock()
+
+	if !b.parent.TryReserve(n) {
+		b.mu.Lock()
+		b.used -= n
+		used = b.used
+		b.mu.Unlock()
+		metrics.FrameBudgetBytes.WithLabelValues(b.name).Set(float64(used))
+		return false
+	}
+
+	metrics.FrameBudgetBytes.WithLabelValues(b.name).Set(float64(used))
+	return true
+}
+
+// Release devolve n bytes reservados com TryReserve.
+func (b *ByteBudget) Release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.used -= n
+	if b.used < 0 {
+		b.used = 0
+	}
+	used := b.used
+	b.mu.Unlock()
+
+	metrics.FrameBudgetBytes.WithLabelValues(b.name).Set(float64(used))
+	b.parent.Release(n)
+}
+
+// Used retorna os bytes reservados.
+func (b *ByteBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Limit retorna o limite em bytes (zero = sem limite).
+func (b *ByteBudget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
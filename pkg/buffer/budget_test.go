@@ -0,0 +1,87 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteBudgetHierarchy(t *testing.T) {
+	global := NewByteBudget("global", 100, nil)
+	cam1 := NewByteBudget("cam1", 60, global)
+	cam2 := NewByteBudget("cam2", 60, global)
+
+	assert.True(t, cam1.TryReserve(60))
+	assert.False(t, cam1.TryReserve(1), "limite da câmera")
+
+	assert.True(t, cam2.TryReserve(40))
+	assert.False(t, cam2.TryReserve(10), "limite global")
+	assert.Equal(t, int64(40), cam2.Used(), "reserva recusada não deve ficar pendurada")
+
+	cam1.Release(30)
+	assert.True(t, cam2.TryReserve(10))
+	assert.Equal(t, int64(80), global.Used())
+}
+
+func TestNilByteBudget(t *testing.T) {
+	var budget *ByteBudget
+
+	assert.True(t, budget.TryReserve(1<<30))
+	budget.Release(1 << 30)
+	assert.Equal(t, int64(0), budget.Used())
+	assert.Equal(t, int64(0), budget.Limit())
+}
+
+func TestFrameBufferByteBudget(t *testing.T) {
+	budget := NewByteBudget("cam1", 10, nil)
+	buffer := NewFrameBuffer(100)
+	buffer.SetByteBudget(budget)
+
+	var released []int
+	frame := func(i, size int) Frame {
+		f := policyTestFrame(i, &released)
+		f.Data = make([]byte, size)
+		f.Data[0] = byte(i)
+		return f
+	}
+
+	assert.NoError(t, buffer.Push(frame(1, 4)))
+	assert.NoError(t, buffer.Push(frame(2, 4)))
+	assert.Equal(t, int64(8), budget.Used())
+
+	// Não cabe: o mais antigo é descartado mesmo com capacidade sobrando
+	err := buffer.Push(frame(3, 4))
+	var dropErr *DropError
+	assert.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, 1, dropErr.OverBudget)
+	assert.Equal(t, []int{1}, released)
+
+	// Maior que o orçamento inteiro: só o próprio frame é descartado
+	err = buffer.Push(frame(4, 20))
+	assert.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, []int{1, 4}, released)
+
+	// A reserva só é devolvida quando o frame é liberado pelo consumidor
+	popped, ok := buffer.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), budget.Used())
+	popped.Release()
+	popped.Release()
+	assert.Equal(t, int64(4), budget.Used())
+}
+
+func TestFrameBufferByteBudgetDropNewest(t *testing.T) {
+	budget := NewByteBudget("cam1", 8, nil)
+	buffer := NewFrameBufferWithPolicy(100, DropNewest, 0)
+	buffer.SetByteBudget(budget)
+
+	var released []int
+	for i := 1; i <= 3; i++ {
+		f := policyTestFrame(i, &released)
+		f.Data = []byte{byte(i), 0, 0, 0}
+		_ = buffer.Push(f)
+	}
+
+	assert.Equal(t, []int{3}, released)
+	assert.Equal(t, []int{1, 2}, popAll(buffer))
+}
@@ -3,50 +3,356 @@ package buffer
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// Frame representa um frame aguardando processamento.
+// Frame representa um frame aguardando processamento. Release deve ser
+// chamado por quem detém o frame quando terminar de usá-lo.
+//
+// Frames criados com NewFrame têm contagem de referências: cada consumidor
+// adicional obtém sua própria referência com Acquire e a libera com Release,
+// e os dados só voltam ao pool após a última liberação.
 type Frame struct {
 	CameraID  string
 	Data      []byte
 	Timestamp time.Time
+	Sequence  uint64 // Ordem de captura na câmera
 	Release   func()
+
+	ref *frameRef
+}
+
+// frameRef é o estado compartilhado por todas as referências de um frame.
+type frameRef struct {
+	refs atomic.Int32
+
+	mu         sync.Mutex
+	finalizers []func() // Executados, em ordem inversa, após a última liberação
+}
+
+// NewFrame cria um frame com uma referência. release é chamado uma única vez,
+// quando a última referência for liberada.
+func NewFrame(cameraID string, data []byte, timestamp time.Time, release func()) Frame {
+	ref := &frameRef{}
+	ref.refs.Store(1)
+	if release != nil {
+		ref.finalizers = append(ref.finalizers, release)
+	}
+
+	frame := Frame{
+		CameraID:  cameraID,
+		Data:      data,
+		Timestamp: timestamp,
+		ref:       ref,
+	}
+	frame.Release = ref.holder()
+	return frame
+}
+
+// Acquire obtém uma nova referência para o frame, com seu próprio Release.
+// Deve ser chamado enquanto a referência atual ainda é válida. Só é suportado
+// em frames criados com NewFrame.
+func (f Frame) Acquire() Frame {
+	if f.ref == nil {
+		panic("buffer: Acquire em frame sem contagem de referências (use NewFrame)")
+	}
+	if f.ref.refs.Add(1) <= 1 {
+		panic("buffer: Acquire em frame já liberado")
+	}
+
+	f.Release = f.ref.holder()
+	return f
+}
+
+// Refcounted indica se o frame tem contagem de referências (criado com
+// NewFrame) e portanto aceita Acquire.
+func (f Frame) Refcounted() bool {
+	return f.ref != nil
+}
+
+// Refs retorna o número de referências ativas (1 para frames sem contagem).
+func (f Frame) Refs() int {
+	if f.ref == nil {
+		return 1
+	}
+	return int(f.ref.refs.Load())
+}
+
+// onFinalRelease registra fn para ser executada após a última liberação. Em
+// frames sem contagem de referências, fn é encadeada ao Release.
+func (f *Frame) onFinalRelease(fn func()) {
+	if f.ref != nil {
+		f.ref.mu.Lock()
+		f.ref.finalizers = append(f.ref.finalizers, fn)
+		f.ref.mu.Unlock()
+		return
+	}
+
+	release := f.Release
+	var once sync.Once
+	f.Release = func() {
+		once.Do(func() {
+			fn()
+			if release != nil {
+				release()
+			}
+		})
+	}
+}
+
+// holder cria o Release de uma referência. Chamadas repetidas do mesmo
+// Release liberam a referência apenas uma vez.
+func (r *frameRef) holder() func() {
+	var once sync.Once
+	return func() {
+		once.Do(r.release)
+	}
+}
+
+func (r *frameRef) release() {
+	refs := r.refs.Add(-1)
+	if refs > 0 {
+		return
+	}
+	if refs < 0 {
+		panic("buffer: frame liberado mais vezes do que foi adquirido")
+	}
+
+	r.mu.Lock()
+	finalizers := r.finalizers
+	r.finalizers = nil
+	r.mu.Unlock()
+
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		finalizers[i]()
+	}
+}
+
+// DropPolicy define qual frame é descartado quando a câmera produz mais
+// frames do que o pipeline consegue publicar.
+type DropPolicy string
+
+const (
+	// DropOldest descarta o frame mais antigo do buffer para dar lugar ao novo.
+	DropOldest DropPolicy = "drop_oldest"
+	// DropNewest mantém os frames do buffer e descarta o que está chegando.
+	DropNewest DropPolicy = "drop_newest"
+	// LatestOnly mantém apenas o frame mais recente, descartando os pendentes.
+	LatestOnly DropPolicy = "latest_only"
+	// Decimate mantém um a cada N frames recebidos; quando o buffer enche, os
+	// mais antigos são descartados como em DropOldest.
+	Decimate DropPolicy = "decimate"
+)
+
+// ParseDropPolicy converte o valor da configuração. Vazio equivale a DropOldest.
+func ParseDropPolicy(value string) (DropPolicy, error) {
+	switch policy := DropPolicy(value); policy {
+	case "":
+		return DropOldest, nil
+	case DropOldest, DropNewest, LatestOnly, Decimate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("política de descarte inválida: %q", value)
+	}
+}
+
+// DropError é retornado por Push quando algum frame foi descartado.
+type DropError struct {
+	Policy     DropPolicy // Política responsável pelo descarte
+	Dropped    int        // Frames descartados pela política
+	OverBudget int        // Frames descartados para respeitar o orçamento de bytes
+}
+
+func (e *DropError) Error() string {
+	if e.Dropped == 0 {
+		return fmt.Sprintf("orçamento de bytes excedido: %d frames descartados", e.OverBudget)
+	}
+
+	switch e.Policy {
+	case DropNewest:
+		return "buffer cheio: frame novo descartado"
+	case LatestOnly:
+		return fmt.Sprintf("%d frames pendentes substituídos pelo mais recente", e.Dropped)
+	case Decimate:
+		return "frame descartado pela decimação"
+	default:
+		return "buffer cheio: frame substituído"
+	}
+}
+
+// Reason é o motivo usado na métrica de frames descartados.
+func (e *DropError) Reason() string {
+	return "policy_" + string(e.Policy)
 }
 
 type FrameBuffer struct {
 	buffer        chan Frame
 	capacity      int
+	budget        *ByteBudget
+	policy        DropPolicy
+	decimateEvery int64
+	received      int64
 	droppedFrames int64
 	totalFrames   int64
 }
 
 func NewFrameBuffer(capacity int) *FrameBuffer {
+	return NewFrameBufferWithPolicy(capacity, DropOldest, 0)
+}
+
+// NewFrameBufferWithPolicy cria um buffer com a política de descarte indicada.
+// decimateEvery só é usado pela política Decimate (padrão 2).
+func NewFrameBufferWithPolicy(capacity int, policy DropPolicy, decimateEvery int) *FrameBuffer {
+	if policy == "" {
+		policy = DropOldest
+	}
+	if decimateEvery <= 0 {
+		decimateEvery = 2
+	}
+
 	return &FrameBuffer{
-		buffer:   make(chan Frame, capacity),
-		capacity: capacity,
+		buffer:        make(chan Frame, capacity),
+		capacity:      capacity,
+		policy:        policy,
+		decimateEvery: int64(decimateEvery),
 	}
 }
 
+// Policy retorna a política de descarte do buffer.
+func (fb *FrameBuffer) Policy() DropPolicy {
+	return fb.policy
+}
+
+// SetByteBudget limita os bytes retidos pelos frames deste buffer. A reserva
+// é feita no Push e devolvida quando o frame é liberado (Release), cobrindo
+// também o tempo em que o frame está na fila do worker pool. Deve ser chamado
+// antes do primeiro Push.
+func (fb *FrameBuffer) SetByteBudget(budget *ByteBudget) {
+	fb.budget = budget
+}
+
+// ByteBudget retorna o orçamento de bytes do buffer (nil se não houver).
+func (fb *FrameBuffer) ByteBudget() *ByteBudget {
+	return fb.budget
+}
+
+// Push enfileira o frame aplicando a política de descarte. O buffer assume a
+// posse do frame: frames descartados, inclusive o próprio frame recebido, são
+// liberados com Release. Retorna *DropError quando algum frame foi descartado.
 func (fb *FrameBuffer) Push(frame Frame) error {
 	atomic.AddInt64(&fb.totalFrames, 1)
 
+	if fb.policy == Decimate && atomic.AddInt64(&fb.received, 1)%fb.decimateEvery != 1%fb.decimateEvery {
+		releaseFrame(frame)
+		return fb.dropped(Decimate, 1, 0)
+	}
+
+	frame, evicted, ok := fb.reserve(frame)
+	if !ok {
+		return fb.dropped(fb.policy, 0, evicted+1)
+	}
+
+	switch fb.policy {
+	case DropNewest:
+		select {
+		case fb.buffer <- frame:
+		default:
+			releaseFrame(frame)
+			return fb.dropped(DropNewest, 1, evicted)
+		}
+
+	case LatestOnly:
+		flushed := 0
+		for {
+			old, ok := fb.Pop()
+			if !ok {
+				break
+			}
+			releaseFrame(old)
+			flushed++
+		}
+		fb.pushDroppingOldest(frame)
+		if flushed > 0 {
+			return fb.dropped(LatestOnly, flushed, evicted)
+		}
+
+	default:
+		if fb.pushDroppingOldest(frame) {
+			return fb.dropped(DropOldest, 1, evicted)
+		}
+	}
+
+	if evicted > 0 {
+		return fb.dropped(fb.policy, 0, evicted)
+	}
+	return nil
+}
+
+// reserve reserva no orçamento os bytes do frame, descartando os frames mais
+// antigos do buffer se necessário (exceto em DropNewest). Retorna o frame com
+// a reserva devolvida na liberação final, quantos frames foram descartados e false se o
+// próprio frame não coube e foi liberado.
+func (fb *FrameBuffer) reserve(frame Frame) (Frame, int, bool) {
+	if fb.budget == nil {
+		return frame, 0, true
+	}
+
+	size := int64(len(frame.Data))
+	if limit := fb.budget.Limit(); limit > 0 && size > limit {
+		// Nunca caberia: não adianta esvaziar o buffer
+		releaseFrame(frame)
+		return frame, 0, false
+	}
+
+	evicted := 0
+	for !fb.budget.TryReserve(size) {
+		var old Frame
+		ok := false
+		if fb.policy != DropNewest {
+			old, ok = fb.Pop()
+		}
+		if !ok {
+			releaseFrame(frame)
+			return frame, evicted, false
+		}
+		releaseFrame(old)
+		evicted++
+	}
+
+	frame.onFinalRelease(func() {
+		fb.budget.Release(size)
+	})
+	return frame, evicted, true
+}
+
+// pushDroppingOldest enfileira o frame, descartando o mais antigo se o buffer
+// estiver cheio. Retorna true se houve descarte.
+func (fb *FrameBuffer) pushDroppingOldest(frame Frame) bool {
 	select {
 	case fb.buffer <- frame:
-		return nil
+		return false
 	default:
 		// Buffer cheio: descarta o frame mais antigo para dar lugar ao novo
 		select {
 		case dropped := <-fb.buffer:
-			if dropped.Release != nil {
-				dropped.Release()
-			}
+			releaseFrame(dropped)
 		default:
 		}
 		fb.buffer <- frame
-		atomic.AddInt64(&fb.droppedFrames, 1)
-		return fmt.Errorf("buffer cheio: frame substituído")
+		return true
+	}
+}
+
+func (fb *FrameBuffer) dropped(policy DropPolicy, n, overBudget int) error {
+	atomic.AddInt64(&fb.droppedFrames, int64(n+overBudget))
+	return &DropError{Policy: policy, Dropped: n, OverBudget: overBudget}
+}
+
+func releaseFrame(frame Frame) {
+	if frame.Release != nil {
+		frame.Release()
 	}
 }
 
@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -148,6 +149,101 @@ func TestFrameBufferConcurrent(t *testing.T) {
 	assert.Equal(t, int64(50), stats.TotalFrames)
 }
 
+func policyTestFrame(i int, released *[]int) Frame {
+	return Frame{
+		CameraID:  "cam1",
+		Data:      []byte{byte(i)},
+		Timestamp: time.Unix(int64(i), 0),
+		Release:   func() { *released = append(*released, i) },
+	}
+}
+
+func popAll(buffer *FrameBuffer) []int {
+	var got []int
+	for {
+		frame, ok := buffer.Pop()
+		if !ok {
+			return got
+		}
+		got = append(got, int(frame.Data[0]))
+	}
+}
+
+func TestFrameBufferDropNewest(t *testing.T) {
+	buffer := NewFrameBufferWithPolicy(2, DropNewest, 0)
+	var released []int
+
+	assert.NoError(t, buffer.Push(policyTestFrame(1, &released)))
+	assert.NoError(t, buffer.Push(policyTestFrame(2, &released)))
+
+	err := buffer.Push(policyTestFrame(3, &released))
+	var dropErr *DropError
+	assert.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, "policy_drop_newest", dropErr.Reason())
+
+	assert.Equal(t, []int{3}, released)
+	assert.Equal(t, []int{1, 2}, popAll(buffer))
+}
+
+func TestFrameBufferLatestOnly(t *testing.T) {
+	buffer := NewFrameBufferWithPolicy(5, LatestOnly, 0)
+	var released []int
+
+	assert.NoError(t, buffer.Push(policyTestFrame(1, &released)))
+	// Sem consumidor, cada push substitui o frame pendente
+	err := buffer.Push(policyTestFrame(2, &released))
+	var dropErr *DropError
+	assert.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, "policy_latest_only", dropErr.Reason())
+	_ = buffer.Push(policyTestFrame(3, &released))
+
+	assert.Equal(t, []int{1, 2}, released)
+	assert.Equal(t, []int{3}, popAll(buffer))
+	assert.Equal(t, int64(2), buffer.Stats().DroppedFrames)
+}
+
+func TestFrameBufferDecimate(t *testing.T) {
+	buffer := NewFrameBufferWithPolicy(10, Decimate, 3)
+	var released []int
+
+	for i := 1; i <= 7; i++ {
+		err := buffer.Push(policyTestFrame(i, &released))
+		var dropErr *DropError
+		if errors.As(err, &dropErr) {
+			assert.Equal(t, Decimate, dropErr.Policy)
+		}
+	}
+
+	assert.Equal(t, []int{1, 4, 7}, popAll(buffer))
+	assert.Equal(t, []int{2, 3, 5, 6}, released)
+}
+
+func TestFrameBufferDropOldestReason(t *testing.T) {
+	buffer := NewFrameBuffer(1)
+	var released []int
+
+	assert.NoError(t, buffer.Push(policyTestFrame(1, &released)))
+	err := buffer.Push(policyTestFrame(2, &released))
+
+	var dropErr *DropError
+	assert.ErrorAs(t, err, &dropErr)
+	assert.Equal(t, "policy_drop_oldest", dropErr.Reason())
+	assert.Equal(t, []int{1}, released)
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	policy, err := ParseDropPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, DropOldest, policy)
+
+	policy, err = ParseDropPolicy("latest_only")
+	assert.NoError(t, err)
+	assert.Equal(t, LatestOnly, policy)
+
+	_, err = ParseDropPolicy("random")
+	assert.Error(t, err)
+}
+
 func BenchmarkFrameBufferPush(b *testing.B) {
 	buffer := NewFrameBuffer(10000)
 
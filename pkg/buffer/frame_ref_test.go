@@ -0,0 +1,103 @@
+package buffer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/framepool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameReleasedAfterLastReference(t *testing.T) {
+	var released int32
+	frame := NewFrame("cam1", []byte("jpeg"), time.Now(), func() {
+		atomic.AddInt32(&released, 1)
+	})
+
+	recording := frame.Acquire()
+	redis := frame.Acquire()
+	assert.Equal(t, 3, frame.Refs())
+
+	frame.Release()
+	recording.Release()
+	assert.Zero(t, atomic.LoadInt32(&released))
+
+	// Release repetido de uma mesma referência não libera as demais
+	recording.Release()
+	assert.Zero(t, atomic.LoadInt32(&released))
+
+	redis.Release()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&released))
+}
+
+func TestFrameAcquireAfterReleasePanics(t *testing.T) {
+	frame := NewFrame("cam1", []byte("jpeg"), time.Now(), nil)
+	frame.Release()
+
+	assert.Panics(t, func() { frame.Acquire() })
+}
+
+func TestFrameAcquireUnmanagedPanics(t *testing.T) {
+	frame := Frame{CameraID: "cam1", Data: []byte("jpeg")}
+
+	assert.Panics(t, func() { frame.Acquire() })
+	assert.Equal(t, 1, frame.Refs())
+}
+
+func TestFrameFanOutConcurrentSinks(t *testing.T) {
+	pool := framepool.New(framepool.DefaultMaxPooledBytes)
+	const sinks = 4
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		data := pool.Get(1024)
+		data[0] = byte(i)
+		frame := NewFrame("cam1", data, time.Now(), func() {
+			pool.Put(data)
+		})
+
+		for s := 0; s < sinks; s++ {
+			ref := frame.Acquire()
+			wg.Add(1)
+			go func(expected byte) {
+				defer wg.Done()
+				defer ref.Release()
+				// Todos os consumidores leem os mesmos bytes, sem cópia
+				if ref.Data[0] != expected {
+					t.Errorf("frame corrompido: esperado %d, obtido %d", expected, ref.Data[0])
+				}
+			}(byte(i))
+		}
+		frame.Release()
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	assert.Zero(t, stats.Outstanding, "buffers não devolvidos ao pool")
+	assert.Zero(t, stats.InUseBytes)
+}
+
+func TestFrameBufferBudgetWithSharedFrame(t *testing.T) {
+	budget := NewByteBudget("cam1", 100, nil)
+	buffer := NewFrameBuffer(10)
+	buffer.SetByteBudget(budget)
+
+	var released int32
+	frame := NewFrame("cam1", make([]byte, 40), time.Now(), func() {
+		atomic.AddInt32(&released, 1)
+	})
+	assert.NoError(t, buffer.Push(frame))
+
+	popped, ok := buffer.Pop()
+	assert.True(t, ok)
+	sink := popped.Acquire()
+
+	popped.Release()
+	assert.Equal(t, int64(40), budget.Used(), "a reserva vale até a última referência")
+
+	sink.Release()
+	assert.Zero(t, budget.Used())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&released))
+}
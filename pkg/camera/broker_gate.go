@@ -0,0 +1,89 @@
+package camera
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BrokerGate segura a captura enquanto algum broker recusa publicações (por
+// exemplo, o RabbitMQ com alarme de memória ou disco). No modo pausa nenhum
+// frame é capturado; no modo redução cada câmera captura no máximo um frame
+// a cada interval. Um BrokerGate nil não restringe a captura.
+type BrokerGate struct {
+	interval time.Duration // Zero pausa a captura
+
+	mu      sync.RWMutex
+	blocked map[string]string // Origem do bloqueio -> motivo
+	last    map[string]time.Time
+}
+
+// NewBrokerGate cria um gate que, com o broker bloqueado, pausa a captura
+// (interval zero) ou a reduz para um frame a cada interval por câmera.
+func NewBrokerGate(interval time.Duration) *BrokerGate {
+	if interval < 0 {
+		interval = 0
+	}
+	return &BrokerGate{
+		interval: interval,
+		blocked:  make(map[string]string),
+		last:     make(map[string]time.Time),
+	}
+}
+
+// SetBlocked registra o estado de bloqueio de uma origem (um destino AMQP).
+// A captura fica restrita enquanto qualquer origem estiver bloqueada.
+func (g *BrokerGate) SetBlocked(source string, blocked bool, reason string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if blocked {
+		g.blocked[source] = reason
+	} else {
+		delete(g.blocked, source)
+	}
+}
+
+// Blocked informa se alguma origem está bloqueada e os motivos.
+func (g *BrokerGate) Blocked() (bool, string) {
+	if g == nil {
+		return false, ""
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.blocked) == 0 {
+		return false, ""
+	}
+	reasons := make([]string, 0, len(g.blocked))
+	for source, reason := range g.blocked {
+		reasons = append(reasons, source+": "+reason)
+	}
+	sort.Strings(reasons)
+	return true, strings.Join(reasons, "; ")
+}
+
+// Admit informa se a câmera pode capturar um frame agora.
+func (g *BrokerGate) Admit(cameraID string) bool {
+	if g == nil {
+		return true
+	}
+	now := time.Now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.blocked) == 0 {
+		g.last[cameraID] = now
+		return true
+	}
+	if g.interval == 0 {
+		return false
+	}
+	if now.Sub(g.last[cameraID]) < g.interval {
+		return false
+	}
+	g.last[cameraID] = now
+	return true
+}
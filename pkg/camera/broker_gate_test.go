@@ -0,0 +1,71 @@
+package camera
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/logger"
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBrokerGatePause(t *testing.T) {
+	gate := NewBrokerGate(0)
+	assert.True(t, gate.Admit("cam1"))
+
+	gate.SetBlocked("amqp", true, "low on memory")
+	gate.SetBlocked("backup", true, "channel flow paused")
+	blocked, reason := gate.Blocked()
+	assert.True(t, blocked)
+	assert.Equal(t, "amqp: low on memory; backup: channel flow paused", reason)
+	assert.False(t, gate.Admit("cam1"))
+
+	gate.SetBlocked("amqp", false, "")
+	assert.False(t, gate.Admit("cam1"), "ainda há uma origem bloqueada")
+
+	gate.SetBlocked("backup", false, "")
+	blocked, _ = gate.Blocked()
+	assert.False(t, blocked)
+	assert.True(t, gate.Admit("cam1"))
+}
+
+func TestBrokerGateReducedRate(t *testing.T) {
+	gate := NewBrokerGate(50 * time.Millisecond)
+	gate.SetBlocked("amqp", true, "low on disk")
+
+	assert.True(t, gate.Admit("cam1"))
+	assert.True(t, gate.Admit("cam2"), "o intervalo é por câmera")
+	assert.False(t, gate.Admit("cam1"))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, gate.Admit("cam1"))
+}
+
+func TestBrokerGateNil(t *testing.T) {
+	var gate *BrokerGate
+	gate.SetBlocked("amqp", true, "x")
+	assert.True(t, gate.Admit("cam1"))
+}
+
+func TestClassicCaptureBrokerBlockedCountsPausedTime(t *testing.T) {
+	if logger.Log == nil {
+		logger.Log = zap.NewNop().Sugar()
+	}
+
+	gate := NewBrokerGate(0)
+	gate.SetBlocked("amqp", true, "low on memory")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := NewCapture(ctx, Config{ID: "cam-blocked", URL: "rtsp://invalido"}, 10*time.Millisecond,
+		nil, nil, nil, nil, nil, buffer.NewFrameBuffer(1), nil, false, 0, nil, nil, nil, nil, gate)
+	c.classicCaptureLoop()
+
+	// Nenhum frame foi capturado: o bloqueio conta como tempo pausado
+	assert.Zero(t, testutil.ToFloat64(metrics.FramesDropped.WithLabelValues("cam-blocked", "broker_blocked")))
+	assert.Greater(t, testutil.ToFloat64(metrics.CaptureBrokerBlockedSeconds.WithLabelValues("cam-blocked")), 0.0)
+}
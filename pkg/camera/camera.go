@@ -5,12 +5,15 @@ import (
 	"context"
 	"errors"
 	"os/exec"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/T3-Labs/edge-video/internal/metadata"
 	"github.com/T3-Labs/edge-video/internal/storage"
 	"github.com/T3-Labs/edge-video/pkg/buffer"
 	"github.com/T3-Labs/edge-video/pkg/circuit"
+	"github.com/T3-Labs/edge-video/pkg/framepool"
 	"github.com/T3-Labs/edge-video/pkg/logger"
 	"github.com/T3-Labs/edge-video/pkg/memcontrol"
 	"github.com/T3-Labs/edge-video/pkg/metrics"
@@ -24,10 +27,17 @@ import (
 type Config struct {
 	ID  string
 	URL string
+	// ProbeTimeout habilita a verificação RTSP (ProbeRTSP) antes de criar o FFmpeg.
+	// Zero desabilita a verificação.
+	ProbeTimeout time.Duration
+	// MaxFrameAge descarta frames que esperaram mais que isso no buffer antes
+	// de serem publicados. Zero desabilita o corte.
+	MaxFrameAge time.Duration
 }
 
 type Capture struct {
 	ctx               context.Context
+	stopCapture       context.CancelFunc
 	bufferCtx         context.Context
 	bufferCancel      context.CancelFunc
 	config            Config
@@ -43,6 +53,20 @@ type Capture struct {
 	usePersistent     bool
 	monitor           *Monitor
 	memController     *memcontrol.Controller
+	coordinator       *StartCoordinator
+	spawnBudget       *SpawnBudget
+	brokerGate        *BrokerGate
+	captureFailing    bool
+
+	// Encerramento: captureWG acompanha as goroutines de captura e
+	// dispatcherDone é fechado quando o bufferDispatcher termina.
+	captureWG      sync.WaitGroup
+	dispatcherDone chan struct{}
+	started        atomic.Bool
+	popped         atomic.Int64
+	dispatched     atomic.Int64
+
+	sequence atomic.Uint64
 }
 
 func NewCapture(
@@ -60,11 +84,18 @@ func NewCapture(
 	persistentBufferSize int,
 	monitor *Monitor,
 	memController *memcontrol.Controller,
+	coordinator *StartCoordinator,
+	spawnBudget *SpawnBudget,
+	brokerGate *BrokerGate,
 ) *Capture {
+	// A captura e o dispatcher têm contextos separados para que Shutdown pare
+	// de capturar e continue entregando os frames já bufferizados.
+	captureCtx, stopCapture := context.WithCancel(ctx)
 	bufferCtx, bufferCancel := context.WithCancel(ctx)
 
 	capture := &Capture{
-		ctx:            ctx,
+		ctx:            captureCtx,
+		stopCapture:    stopCapture,
 		bufferCtx:      bufferCtx,
 		bufferCancel:   bufferCancel,
 		config:         config,
@@ -79,6 +110,10 @@ func NewCapture(
 		usePersistent:  usePersistent,
 		monitor:        monitor,
 		memController:  memController,
+		coordinator:    coordinator,
+		spawnBudget:    spawnBudget,
+		brokerGate:     brokerGate,
+		dispatcherDone: make(chan struct{}),
 	}
 
 	if usePersistent {
@@ -86,15 +121,32 @@ func NewCapture(
 		if fps == 0 {
 			fps = 30
 		}
-		capture.persistentCapture = NewPersistentCapture(ctx, config.ID, config.URL, 5, fps, persistentBufferSize)
+		capture.persistentCapture = NewPersistentCapture(captureCtx, config.ID, config.URL, 5, fps, persistentBufferSize)
+		capture.persistentCapture.SetPreflight(capture.persistentPreflight)
+		capture.persistentCapture.SetStartCoordinator(coordinator)
+		capture.persistentCapture.SetByteBudget(frameBuffer.ByteBudget())
 	}
 
 	return capture
 }
 
+// Start inicia a captura da câmera. O início efetivo é escalonado pelo
+// StartCoordinator para que todas as câmeras não conectem ao mesmo tempo.
 func (c *Capture) Start() {
+	c.started.Store(true)
 	go c.bufferDispatcher()
 
+	c.captureWG.Add(1)
+	go func() {
+		defer c.captureWG.Done()
+		if err := c.coordinator.WaitTurn(c.ctx); err != nil {
+			return
+		}
+		c.startCapture()
+	}()
+}
+
+func (c *Capture) startCapture() {
 	if c.usePersistent && c.persistentCapture != nil {
 		err := c.persistentCapture.Start()
 		if err != nil {
@@ -107,7 +159,11 @@ func (c *Capture) Start() {
 				c.monitor.RecordFailure(c.config.ID, err)
 			}
 		} else {
-			go c.persistentCaptureLoop()
+			c.captureWG.Add(1)
+			go func() {
+				defer c.captureWG.Done()
+				c.persistentCaptureLoop()
+			}()
 			metrics.CameraConnected.WithLabelValues(c.config.ID).Set(1)
 			if c.monitor != nil {
 				c.monitor.RecordSuccess(c.config.ID)
@@ -118,13 +174,19 @@ func (c *Capture) Start() {
 		}
 	}
 
-	go c.classicCaptureLoop()
+	c.captureWG.Add(1)
+	go func() {
+		defer c.captureWG.Done()
+		c.classicCaptureLoop()
+	}()
 	metrics.CameraConnected.WithLabelValues(c.config.ID).Set(1)
 	logger.Log.Infow("Captura clássica iniciada",
 		"camera_id", c.config.ID)
 }
 
 func (c *Capture) bufferDispatcher() {
+	defer close(c.dispatcherDone)
+
 	for {
 		frame, ok := c.frameBuffer.PopBlocking(c.bufferCtx)
 		if !ok {
@@ -133,17 +195,37 @@ func (c *Capture) bufferDispatcher() {
 			}
 			continue
 		}
+		c.popped.Add(1)
+
+		if c.config.MaxFrameAge > 0 && time.Since(frame.Timestamp) > c.config.MaxFrameAge {
+			if frame.Release != nil {
+				frame.Release()
+			}
+			metrics.FramesDropped.WithLabelValues(c.config.ID, "max_age_exceeded").Inc()
+			metrics.BufferSize.WithLabelValues(c.config.ID).Set(float64(c.frameBuffer.Size()))
+			continue
+		}
 
 		job := c.newJob(frame)
 
-		if err := c.workerPool.Submit(job); err != nil {
+		if err := c.workerPool.Submit(job); err == nil {
+			c.dispatched.Add(1)
+		} else {
 			metrics.FramesDropped.WithLabelValues(c.config.ID, "worker_pool_full").Inc()
-			logger.Log.Warnw("Worker pool cheio, processando sincronamente",
-				"camera_id", c.config.ID)
-			if procErr := job.Process(c.ctx); procErr != nil {
-				logger.Log.Errorw("Erro ao processar frame após fallback",
-					"camera_id", c.config.ID,
-					"error", procErr)
+			if c.workerPool.Ordered() {
+				// Processar fora do pool furaria a fila da câmera e quebraria a ordem
+				logger.Log.Warnw("Worker pool cheio, frame descartado para preservar a ordem",
+					"camera_id", c.config.ID)
+				job.Discard()
+			} else {
+				logger.Log.Warnw("Worker pool cheio, processando sincronamente",
+					"camera_id", c.config.ID)
+				c.dispatched.Add(1)
+				if procErr := job.Process(c.bufferCtx); procErr != nil {
+					logger.Log.Errorw("Erro ao processar frame após fallback",
+						"camera_id", c.config.ID,
+						"error", procErr)
+				}
 			}
 		}
 
@@ -151,6 +233,71 @@ func (c *Capture) bufferDispatcher() {
 	}
 }
 
+// Shutdown encerra a câmera de forma ordenada: para a captura, continua
+// entregando ao worker pool os frames que já estão no buffer até o prazo de
+// ctx e descarta o restante. Retorna quantos frames bufferizados foram
+// entregues e quantos foram abandonados.
+func (c *Capture) Shutdown(ctx context.Context) (flushed, abandoned int) {
+	c.stopCapture()
+
+	captureDone := make(chan struct{})
+	go func() {
+		c.captureWG.Wait()
+		close(captureDone)
+	}()
+	select {
+	case <-captureDone:
+	case <-ctx.Done():
+		logger.Log.Warnw("Prazo de encerramento esgotado aguardando a captura",
+			"camera_id", c.config.ID)
+	}
+
+	popped, dispatched := c.popped.Load(), c.dispatched.Load()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for c.frameBuffer.Size() > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	c.bufferCancel()
+	if c.started.Load() {
+		<-c.dispatcherDone
+	}
+
+	// Frames retirados do buffer mas descartados pelo dispatcher (idade ou
+	// pool cheio) já foram contabilizados nas métricas de descarte
+	flushed = int(c.dispatched.Load() - dispatched)
+	abandoned = int(c.popped.Load()-popped) - flushed
+
+	leftover := 0
+	for {
+		frame, ok := c.frameBuffer.Pop()
+		if !ok {
+			break
+		}
+		if frame.Release != nil {
+			frame.Release()
+		}
+		leftover++
+	}
+	abandoned += leftover
+
+	if leftover > 0 {
+		metrics.FramesDropped.WithLabelValues(c.config.ID, "shutdown").Add(float64(leftover))
+	}
+	metrics.BufferSize.WithLabelValues(c.config.ID).Set(0)
+
+	logger.Log.Infow("Câmera encerrada",
+		"camera_id", c.config.ID,
+		"frames_flushed", flushed,
+		"frames_abandoned", abandoned)
+	return flushed, abandoned
+}
+
 func (c *Capture) persistentCaptureLoop() {
 	logger.Log.Infow("Iniciando loop de captura persistente",
 		"camera_id", c.config.ID)
@@ -170,13 +317,7 @@ func (c *Capture) persistentCaptureLoop() {
 			return
 
 		case <-ticker.C:
-			// LATEST FRAME POLICY: Sempre pega o frame mais recente disponível
-			// Flush de frames antigos acumulados no buffer
-			var frame []byte
-			var ok bool
-
-			// Tenta pegar um frame
-			frame, ok = c.persistentCapture.GetFrameNonBlocking()
+			frame, ok := c.persistentCapture.GetFrameNonBlocking()
 			if !ok {
 				consecutiveFailures++
 				metrics.FramesDropped.WithLabelValues(c.config.ID, "no_frame_available").Inc()
@@ -192,31 +333,59 @@ func (c *Capture) persistentCaptureLoop() {
 				}
 				continue
 			}
+			consecutiveFailures = 0
 
-			// CRÍTICO: Descarta frames antigos acumulados (política Latest Frame da V2)
-			flushedCount := 0
-			for {
-				oldFrame, hasMore := c.persistentCapture.GetFrameNonBlocking()
-				if !hasMore {
-					break
+			// Com o broker bloqueado o FFmpeg continua rodando, mas os frames
+			// são descartados aqui em vez de acumular até o desbloqueio
+			if !c.brokerGate.Admit(c.config.ID) {
+				dropped := 1
+				framepool.Put(frame)
+				for {
+					next, hasMore := c.persistentCapture.GetFrameNonBlocking()
+					if !hasMore {
+						break
+					}
+					framepool.Put(next)
+					dropped++
 				}
-				// Libera o frame antigo
-				releaseFrameBuffer(frame)
-				// Usa o mais recente
-				frame = oldFrame
-				flushedCount++
+				metrics.FramesDropped.WithLabelValues(c.config.ID, "broker_blocked").Add(float64(dropped))
+				continue
 			}
 
-			if flushedCount > 0 {
-				logger.Log.Debugw("Frames antigos descartados (Latest Frame Policy)",
-					"camera_id", c.config.ID,
-					"flushed_count", flushedCount)
-				metrics.FramesDropped.WithLabelValues(c.config.ID, "flushed_old_frames").Add(float64(flushedCount))
+			if c.frameBuffer.Policy() == buffer.LatestOnly {
+				// LATEST FRAME POLICY: descarta os frames acumulados e usa só o mais recente
+				flushedCount := 0
+				for {
+					newer, hasMore := c.persistentCapture.GetFrameNonBlocking()
+					if !hasMore {
+						break
+					}
+					framepool.Put(frame)
+					frame = newer
+					flushedCount++
+				}
+
+				if flushedCount > 0 {
+					logger.Log.Debugw("Frames antigos descartados (Latest Frame Policy)",
+						"camera_id", c.config.ID,
+						"flushed_count", flushedCount)
+					metrics.FramesDropped.WithLabelValues(c.config.ID, "policy_latest_only").Add(float64(flushedCount))
+				}
 			}
 
-			consecutiveFailures = 0
-			metrics.FrameSizeBytes.WithLabelValues(c.config.ID).Observe(float64(len(frame)))
-			c.enqueueFrame(frame, false)
+			// Nas demais políticas todos os frames acumulados são entregues, em
+			// ordem, e o frame buffer decide o que descartar
+			for frame != nil {
+				metrics.FrameSizeBytes.WithLabelValues(c.config.ID).Observe(float64(len(frame)))
+				c.enqueueFrame(frame, false)
+
+				frame = nil
+				if c.frameBuffer.Policy() != buffer.LatestOnly {
+					if next, hasMore := c.persistentCapture.GetFrameNonBlocking(); hasMore {
+						frame = next
+					}
+				}
+			}
 
 			if c.monitor != nil {
 				c.monitor.RecordSuccess(c.config.ID)
@@ -263,6 +432,15 @@ func (c *Capture) classicCaptureLoop() {
 			}
 		}
 
+		// Com o broker bloqueado a captura é pausada ou reduzida. Nenhum frame
+		// é capturado, então o intervalo conta como tempo pausado, não como
+		// frame descartado
+		if !c.brokerGate.Admit(c.config.ID) {
+			metrics.CaptureBrokerBlockedSeconds.WithLabelValues(c.config.ID).Add(c.interval.Seconds())
+			time.Sleep(c.interval)
+			continue
+		}
+
 		start := time.Now()
 		c.captureAndPublish()
 
@@ -277,14 +455,38 @@ func (c *Capture) classicCaptureLoop() {
 func (c *Capture) captureAndPublish() {
 	start := time.Now()
 
-	err := c.circuitBreaker.Call(func() error {
+	// Aguarda uma vaga no orçamento global de processos FFmpeg. Esperas longas
+	// descartam o frame em vez de acumular processos atrasados.
+	release, err := c.spawnBudget.Acquire(c.ctx, c.config.ID)
+	if err != nil {
+		if errors.Is(err, ErrSpawnWaitExceeded) {
+			metrics.FramesDropped.WithLabelValues(c.config.ID, "spawn_budget_timeout").Inc()
+			logger.Log.Warnw("Frame descartado: sem vaga para criar o FFmpeg",
+				"camera_id", c.config.ID,
+				"waited", time.Since(start))
+		}
+		return
+	}
+	defer release()
+
+	err = c.circuitBreaker.Call(func() error {
+		// Após uma falha, verifica a câmera antes de criar outro processo FFmpeg
+		if c.captureFailing && c.config.ProbeTimeout > 0 {
+			if err := c.probe(); err != nil {
+				return err
+			}
+		}
 		return c.doCapture()
 	})
+	c.captureFailing = err != nil
 
 	if err != nil {
 		// Classifica o tipo de erro
 		errorType := "unknown"
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		var probeErr *ProbeError
+		if errors.As(err, &probeErr) {
+			errorType = "probe_" + string(probeErr.Result)
+		} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			errorType = "context_error"
 		} else if err.Error() == "circuit breaker "+c.config.ID+" aberto" {
 			errorType = "circuit_breaker_open"
@@ -314,6 +516,35 @@ func (c *Capture) captureAndPublish() {
 	}
 }
 
+// probe executa a verificação RTSP da câmera e registra o resultado nas métricas.
+func (c *Capture) probe() error {
+	err := ProbeRTSP(c.ctx, c.config.URL, c.config.ProbeTimeout)
+
+	result := ProbeOK
+	var probeErr *ProbeError
+	if errors.As(err, &probeErr) {
+		result = probeErr.Result
+	}
+	metrics.CameraProbeResults.WithLabelValues(c.config.ID, string(result)).Inc()
+
+	if err != nil {
+		logger.Log.Warnw("Câmera reprovada na verificação RTSP",
+			"camera_id", c.config.ID,
+			"probe_result", result,
+			"error", err)
+	}
+	return err
+}
+
+// persistentPreflight é chamado pela captura persistente antes de (re)iniciar o
+// FFmpeg, alimentando o circuit breaker da câmera sem criar processos.
+func (c *Capture) persistentPreflight() error {
+	if c.config.ProbeTimeout <= 0 {
+		return nil
+	}
+	return c.circuitBreaker.Call(c.probe)
+}
+
 func (c *Capture) doCapture() error {
 	cmd := exec.CommandContext(
 		c.ctx,
@@ -396,65 +627,137 @@ func (c *Capture) enqueueFrame(frameData []byte, copyData bool) {
 
 	data := frameData
 	if copyData {
-		buf := getFrameBuffer(len(frameData))
+		buf := framepool.Get(len(frameData))
 		copy(buf, frameData)
 		data = buf
 	}
 
-	frame := buffer.Frame{
-		CameraID:  c.config.ID,
-		Data:      data,
-		Timestamp: time.Now(),
-		Release: func() {
-			releaseFrameBuffer(data)
-		},
-	}
+	frame := buffer.NewFrame(c.config.ID, data, time.Now(), func() {
+		framepool.Put(data)
+	})
+	frame.Sequence = c.sequence.Add(1)
 
 	if err := c.frameBuffer.Push(frame); err != nil {
-		metrics.FramesDropped.WithLabelValues(c.config.ID, "frame_buffer_full").Inc()
-		logger.Log.Warnw("Frame buffer cheio, frame substituído",
-			"camera_id", c.config.ID,
-			"buffer_size", c.frameBuffer.Capacity())
+		var dropErr *buffer.DropError
+		if errors.As(err, &dropErr) {
+			if dropErr.Dropped > 0 {
+				metrics.FramesDropped.WithLabelValues(c.config.ID, dropErr.Reason()).Add(float64(dropErr.Dropped))
+			}
+			if dropErr.OverBudget > 0 {
+				metrics.FramesDropped.WithLabelValues(c.config.ID, "byte_budget").Add(float64(dropErr.OverBudget))
+			}
+		}
+		// A decimação descarta frames por projeto; não é motivo de alerta
+		if dropErr == nil || dropErr.Policy != buffer.Decimate || dropErr.OverBudget > 0 {
+			logger.Log.Warnw("Frame descartado pelo frame buffer",
+				"camera_id", c.config.ID,
+				"buffer_size", c.frameBuffer.Capacity(),
+				"reason", err.Error())
+		}
 	}
 
 	metrics.BufferSize.WithLabelValues(c.config.ID).Set(float64(c.frameBuffer.Size()))
 }
 
 func (c *Capture) newJob(frame buffer.Frame) *FrameProcessJob {
+	// Os destinos best-effort ficam com uma referência do frame (Acquire)
+	if !frame.Refcounted() {
+		frame = buffer.NewFrame(frame.CameraID, frame.Data, frame.Timestamp, frame.Release)
+	}
 	return &FrameProcessJob{
 		cameraID:      frame.CameraID,
-		frameData:     frame.Data,
+		frame:         frame,
 		timestamp:     frame.Timestamp,
+		sequence:      frame.Sequence,
+		compressor:    c.compressor,
 		publisher:     c.publisher,
 		redisStore:    c.redisStore,
 		metaPublisher: c.metaPublisher,
-		release:       frame.Release,
 	}
 }
 
 type FrameProcessJob struct {
 	cameraID      string
-	frameData     []byte
+	frame         buffer.Frame // Data é o JPEG; liberado ao fim do job
 	timestamp     time.Time
+	sequence      uint64
+	compressor    *util.Compressor
 	publisher     mq.Publisher
 	redisStore    *storage.RedisStore
 	metaPublisher *metadata.Publisher
-	release       func()
 }
 
 func (j *FrameProcessJob) GetID() string {
 	return j.cameraID + "_" + j.timestamp.Format("20060102150405.000")
 }
 
+// QueueKey coloca os frames de cada câmera na sua própria fila do worker pool.
+func (j *FrameProcessJob) QueueKey() string {
+	return j.cameraID
+}
+
+// Discard libera o frame de um job que não será processado.
+func (j *FrameProcessJob) Discard() {
+	j.release()
+}
+
+func (j *FrameProcessJob) release() {
+	if j.frame.Release != nil {
+		j.frame.Release()
+	}
+}
+
+// compress comprime o frame quando a câmera usa compressão. Se o zstd não
+// reduzir o JPEG, o frame segue sem compressão; a razão é registrada nos
+// dois casos para avaliar se vale a pena comprimir.
+func (j *FrameProcessJob) compress(info *mq.FrameInfo) []byte {
+	if j.compressor == nil {
+		return j.frame.Data
+	}
+
+	compressed, err := j.compressor.Compress(j.frame.Data)
+	if err != nil {
+		logger.Log.Warnw("Erro ao comprimir frame, publicando sem compressão",
+			"camera_id", j.cameraID,
+			"error", err)
+		return j.frame.Data
+	}
+
+	metrics.CompressionRatio.WithLabelValues(j.cameraID).Observe(float64(len(j.frame.Data)) / float64(len(compressed)))
+	if len(compressed) >= len(j.frame.Data) {
+		return j.frame.Data
+	}
+
+	info.ContentEncoding = mq.ContentEncodingZstd
+	return compressed
+}
+
+// frameEncoding é o campo encoding dos metadados: "jpeg" ou "jpeg+zstd".
+func frameEncoding(info mq.FrameInfo) string {
+	if info.ContentEncoding == mq.ContentEncodingZstd {
+		return "jpeg+zstd"
+	}
+	return "jpeg"
+}
+
 func (j *FrameProcessJob) Process(ctx context.Context) error {
-	defer func() {
-		if j.release != nil {
-			j.release()
-		}
-	}()
+	defer j.release()
 	start := time.Now()
 
-	err := j.publisher.Publish(ctx, j.cameraID, j.frameData)
+	info := mq.NewFrameInfo(j.cameraID, j.sequence, j.timestamp, j.frame.Data)
+	payload := j.compress(&info)
+	// A chave do Redis é gerada antes de publicar para viajar com o frame: o
+	// spool a guarda para reenviar os metadados
+	info.StorageKey = j.redisStore.FrameKey(j.cameraID, j.timestamp)
+
+	// Os destinos best-effort compartilham o frame publicado em vez de copiá-lo
+	frame := j.frame
+	if info.ContentEncoding == mq.ContentEncodingZstd {
+		frame = buffer.NewFrame(j.cameraID, payload, j.timestamp, nil)
+		defer frame.Release()
+	}
+	ctx = mq.WithFrame(mq.WithFrameInfo(ctx, info), frame)
+	err := j.publisher.Publish(ctx, j.cameraID, payload)
 	if err != nil {
 		logger.Log.Errorw("Erro ao publicar frame",
 			"camera_id", j.cameraID,
@@ -467,9 +770,12 @@ func (j *FrameProcessJob) Process(ctx context.Context) error {
 
 	if j.redisStore.Enabled() {
 		width, height := 1280, 720
+		if info.Width > 0 && info.Height > 0 {
+			width, height = info.Width, info.Height
+		}
 
-		key, err := j.redisStore.SaveFrame(ctx, j.cameraID, j.timestamp, j.frameData)
-		if err != nil {
+		key := info.StorageKey
+		if err := j.redisStore.SaveFrameWithKey(ctx, key, payload); err != nil {
 			if errors.Is(err, redis.ErrClosed) {
 				logger.Log.Errorw("Redis store error (connection closed)",
 					"camera_id", j.cameraID,
@@ -486,7 +792,7 @@ func (j *FrameProcessJob) Process(ctx context.Context) error {
 		metrics.StorageOperations.WithLabelValues("save_frame", "success").Inc()
 
 		if j.metaPublisher.Enabled() {
-			err = j.metaPublisher.PublishMetadata(j.cameraID, j.timestamp, key, width, height, len(j.frameData), "jpeg")
+			err = j.metaPublisher.PublishMetadata(j.cameraID, j.timestamp, key, width, height, len(payload), frameEncoding(info))
 			if err != nil {
 				if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.ChannelError {
 					logger.Log.Errorw("Metadata publish error (channel closed)",
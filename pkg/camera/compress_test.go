@@ -0,0 +1,58 @@
+package camera
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/mq"
+	"github.com/T3-Labs/edge-video/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameProcessJobCompress(t *testing.T) {
+	compressor, err := util.NewCompressor(3)
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte("jpeg"), 4096)
+	job := &FrameProcessJob{cameraID: "cam1", frame: buffer.Frame{Data: data}, timestamp: time.Now(), compressor: compressor}
+	info := mq.NewFrameInfo("cam1", 1, job.timestamp, data)
+
+	payload := job.compress(&info)
+	assert.Less(t, len(payload), len(data))
+	assert.Equal(t, mq.ContentEncodingZstd, info.ContentEncoding)
+	assert.Equal(t, "jpeg+zstd", frameEncoding(info))
+
+	out, err := util.Decompress(payload)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestFrameProcessJobCompressSkipsIncompressible(t *testing.T) {
+	compressor, err := util.NewCompressor(3)
+	require.NoError(t, err)
+
+	data := make([]byte, 16<<10)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	job := &FrameProcessJob{cameraID: "cam1", frame: buffer.Frame{Data: data}, compressor: compressor}
+	info := mq.NewFrameInfo("cam1", 1, time.Now(), data)
+
+	payload := job.compress(&info)
+	assert.Equal(t, data, payload)
+	assert.Equal(t, mq.ContentEncodingIdentity, info.ContentEncoding)
+	assert.Equal(t, "jpeg", frameEncoding(info))
+}
+
+func TestFrameProcessJobWithoutCompressor(t *testing.T) {
+	data := []byte("jpeg")
+	job := &FrameProcessJob{cameraID: "cam1", frame: buffer.Frame{Data: data}}
+	info := mq.NewFrameInfo("cam1", 1, time.Now(), data)
+
+	assert.Equal(t, data, job.compress(&info))
+	assert.Equal(t, mq.ContentEncodingIdentity, info.ContentEncoding)
+}
@@ -0,0 +1,127 @@
+package camera
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// StartCoordinator evita o efeito manada quando todas as câmeras (re)iniciam
+// ao mesmo tempo, por exemplo após uma queda de energia na loja:
+//   - escalona o Start de cada câmera com um intervalo fixo mais jitter;
+//   - limita quantos processos FFmpeg podem estar iniciando simultaneamente;
+//   - fornece jitter para os atrasos de reconexão.
+//
+// Um coordinator nil não aplica nenhuma restrição.
+type StartCoordinator struct {
+	stagger time.Duration
+	jitter  time.Duration
+	settle  time.Duration
+	slots   chan struct{}
+
+	mu       sync.Mutex
+	nextSlot time.Time
+}
+
+// NewStartCoordinator cria um coordinator. stagger é o intervalo entre o início
+// de duas câmeras, jitter o atraso aleatório máximo somado a cada espera e
+// maxConcurrentStarts quantos FFmpeg podem estar iniciando ao mesmo tempo.
+// Um processo deixa de contar como "iniciando" ao entregar o primeiro frame ou
+// após settle.
+func NewStartCoordinator(stagger, jitter time.Duration, maxConcurrentStarts int, settle time.Duration) *StartCoordinator {
+	if maxConcurrentStarts <= 0 {
+		maxConcurrentStarts = 4
+	}
+	if settle <= 0 {
+		settle = 10 * time.Second
+	}
+
+	return &StartCoordinator{
+		stagger: stagger,
+		jitter:  jitter,
+		settle:  settle,
+		slots:   make(chan struct{}, maxConcurrentStarts),
+	}
+}
+
+// WaitTurn bloqueia até a vez da câmera iniciar. Cada chamada reserva o próximo
+// horário disponível, espaçado de stagger do anterior.
+func (sc *StartCoordinator) WaitTurn(ctx context.Context) error {
+	if sc == nil {
+		return nil
+	}
+
+	sc.mu.Lock()
+	now := time.Now()
+	slot := sc.nextSlot
+	if slot.Before(now) {
+		slot = now
+	}
+	sc.nextSlot = slot.Add(sc.stagger)
+	sc.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(slot)+sc.randomJitter())
+}
+
+// AcquireStart reserva uma vaga para iniciar um processo FFmpeg. A função
+// retornada libera a vaga e pode ser chamada mais de uma vez; se não for
+// chamada, a vaga é liberada automaticamente após o tempo de settle.
+func (sc *StartCoordinator) AcquireStart(ctx context.Context) (func(), error) {
+	if sc == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	select {
+	case sc.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	metrics.FFmpegStartWait.Observe(time.Since(start).Seconds())
+	metrics.FFmpegStarting.Inc()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-sc.slots
+			metrics.FFmpegStarting.Dec()
+		})
+	}
+	time.AfterFunc(sc.settle, release)
+
+	return release, nil
+}
+
+// Jitter soma a d um atraso aleatório entre zero e o jitter configurado.
+func (sc *StartCoordinator) Jitter(d time.Duration) time.Duration {
+	if sc == nil {
+		return d
+	}
+	return d + sc.randomJitter()
+}
+
+func (sc *StartCoordinator) randomJitter() time.Duration {
+	if sc.jitter <= 0 {
+		return 0
+	}
+	return rand.N(sc.jitter)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
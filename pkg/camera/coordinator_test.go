@@ -0,0 +1,170 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStartCoordinatorStaggersStarts(t *testing.T) {
+	sc := NewStartCoordinator(50*time.Millisecond, 0, 4, time.Second)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var offsets []time.Duration
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, sc.WaitTurn(context.Background()))
+			mu.Lock()
+			offsets = append(offsets, time.Since(start))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var last time.Duration
+	for _, offset := range offsets {
+		if offset > last {
+			last = offset
+		}
+	}
+	assert.GreaterOrEqual(t, last, 150*time.Millisecond)
+}
+
+func TestStartCoordinatorLimitsConcurrentStarts(t *testing.T) {
+	sc := NewStartCoordinator(0, 0, 2, time.Minute)
+
+	var starting, maxStarting int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := sc.AcquireStart(context.Background())
+			require.NoError(t, err)
+
+			current := atomic.AddInt32(&starting, 1)
+			for {
+				max := atomic.LoadInt32(&maxStarting)
+				if current <= max || atomic.CompareAndSwapInt32(&maxStarting, max, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&starting, -1)
+
+			release()
+			release()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxStarting))
+}
+
+func TestStartCoordinatorSettleReleasesSlot(t *testing.T) {
+	sc := NewStartCoordinator(0, 0, 1, 50*time.Millisecond)
+
+	_, err := sc.AcquireStart(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	release, err := sc.AcquireStart(ctx)
+	require.NoError(t, err)
+	release()
+}
+
+func TestNilStartCoordinator(t *testing.T) {
+	var sc *StartCoordinator
+
+	assert.NoError(t, sc.WaitTurn(context.Background()))
+	release, err := sc.AcquireStart(context.Background())
+	assert.NoError(t, err)
+	release()
+	assert.Equal(t, time.Second, sc.Jitter(time.Second))
+}
+
+func TestPersistentCaptureStartDoesNotHoldLockDuringPreflight(t *testing.T) {
+	if logger.Log == nil {
+		logger.Log = zap.NewNop().Sugar()
+	}
+
+	pc := NewPersistentCapture(context.Background(), "cam1", "rtsp://invalido", 5, 5, 4)
+	probing := make(chan struct{})
+	unblock := make(chan struct{})
+	pc.SetPreflight(func() error {
+		close(probing)
+		<-unblock
+		return errors.New("câmera indisponível")
+	})
+
+	started := make(chan error, 1)
+	go func() { started <- pc.Start() }()
+	<-probing
+
+	// Durante o probe a câmera continua respondendo
+	done := make(chan struct{})
+	go func() {
+		pc.IsRunning()
+		pc.SetStartCoordinator(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pc.mu retido durante a verificação prévia")
+	}
+	assert.Error(t, pc.Start(), "Start concorrente é recusado")
+
+	close(unblock)
+	require.NoError(t, <-started)
+	assert.True(t, pc.IsRunning())
+	pc.Stop()
+	assert.False(t, pc.IsRunning())
+}
+
+func TestPersistentCaptureStopWhileWaitingForSlot(t *testing.T) {
+	if logger.Log == nil {
+		logger.Log = zap.NewNop().Sugar()
+	}
+
+	sc := NewStartCoordinator(0, 0, 1, time.Minute)
+	_, err := sc.AcquireStart(context.Background())
+	require.NoError(t, err)
+
+	pc := NewPersistentCapture(context.Background(), "cam1", "rtsp://invalido", 5, 5, 4)
+	pc.SetStartCoordinator(sc)
+
+	started := make(chan error, 1)
+	go func() { started <- pc.Start() }()
+	require.Eventually(t, pc.IsRunning, time.Second, 5*time.Millisecond)
+
+	// Stop não espera a vaga e cancela a espera do Start
+	stopped := make(chan struct{})
+	go func() {
+		pc.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop bloqueado pela espera no coordinator")
+	}
+	assert.ErrorIs(t, <-started, context.Canceled)
+	assert.False(t, pc.IsRunning())
+}
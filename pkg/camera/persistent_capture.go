@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
@@ -11,7 +12,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/framepool"
 	"github.com/T3-Labs/edge-video/pkg/logger"
+	"github.com/T3-Labs/edge-video/pkg/metrics"
 )
 
 type PersistentCapture struct {
@@ -38,6 +42,10 @@ type PersistentCapture struct {
 
 	readCtx    context.Context    // Context para a goroutine readFrames
 	readCancel context.CancelFunc // Cancel para a goroutine readFrames
+
+	preflight   func() error       // Verificação executada antes de iniciar o FFmpeg
+	coordinator *StartCoordinator  // Limita inícios simultâneos e aplica jitter
+	budget      *buffer.ByteBudget // Bytes dos frames retidos no canal
 }
 
 func NewPersistentCapture(ctx context.Context, cameraID, rtspURL string, quality int, fps int, bufferSize int) *PersistentCapture {
@@ -64,24 +72,41 @@ func NewPersistentCapture(ctx context.Context, cameraID, rtspURL string, quality
 
 func (pc *PersistentCapture) Start() error {
 	pc.mu.Lock()
-	defer pc.mu.Unlock()
-
 	if pc.running {
+		pc.mu.Unlock()
 		return fmt.Errorf("captura já está rodando")
 	}
+	// A verificação prévia e a espera por uma vaga no coordinator rodam sem
+	// pc.mu; restarting impede um Restart concorrente nesse intervalo
+	pc.running = true
+	pc.restarting = true
+	pc.mu.Unlock()
 
-	err := pc.startFFmpeg()
-	if err != nil {
+	defer func() {
+		pc.mu.Lock()
+		pc.restarting = false
+		pc.mu.Unlock()
+	}()
+
+	release, err := pc.prepareStart()
+	if err == nil {
+		err = pc.startFFmpeg(release)
+	}
+	if errors.Is(err, errPreflight) {
+		// Câmera indisponível: não cria o FFmpeg agora, o monitorHealth
+		// tenta novamente via Restart quando o timeout de frames expirar
+		logger.Log.Warnw("Verificação prévia falhou, FFmpeg será iniciado no próximo restart",
+			"camera_id", pc.cameraID,
+			"error", err)
+	} else if err != nil {
+		pc.mu.Lock()
+		pc.running = false
+		pc.mu.Unlock()
 		return err
 	}
 
-	// Cria context específico para readFrames
-	pc.readCtx, pc.readCancel = context.WithCancel(pc.ctx)
-
-	go pc.readFrames()
 	go pc.monitorHealth()
 
-	pc.running = true
 	logger.Log.Infow("Captura persistente iniciada",
 		"camera_id", pc.cameraID,
 		"quality", pc.quality)
@@ -89,7 +114,37 @@ func (pc *PersistentCapture) Start() error {
 	return nil
 }
 
-func (pc *PersistentCapture) startFFmpeg() error {
+// errPreflight indica que a verificação prévia falhou em prepareStart.
+var errPreflight = errors.New("verificação prévia falhou")
+
+// prepareStart executa a verificação prévia e obtém uma vaga no coordinator.
+// Não segura pc.mu: o probe e a espera pela vaga podem levar segundos e
+// bloqueariam Stop, IsRunning e os Set* da câmera.
+func (pc *PersistentCapture) prepareStart() (func(), error) {
+	pc.mu.RLock()
+	preflight, coordinator := pc.preflight, pc.coordinator
+	pc.mu.RUnlock()
+
+	if preflight != nil {
+		if err := preflight(); err != nil {
+			return nil, fmt.Errorf("%w: %w", errPreflight, err)
+		}
+	}
+	return coordinator.AcquireStart(pc.ctx)
+}
+
+// startFFmpeg inicia o processo FFmpeg e a goroutine readFrames com a vaga
+// obtida em prepareStart, que é liberada no primeiro frame. Se a captura foi
+// parada enquanto aguardava a vaga, libera a vaga sem iniciar nada.
+func (pc *PersistentCapture) startFFmpeg(release func()) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if err := pc.ctx.Err(); err != nil {
+		release()
+		return err
+	}
+
 	pc.cmd = exec.CommandContext(
 		pc.ctx,
 		"ffmpeg",
@@ -109,25 +164,33 @@ func (pc *PersistentCapture) startFFmpeg() error {
 	var err error
 	pc.stdout, err = pc.cmd.StdoutPipe()
 	if err != nil {
+		release()
 		return fmt.Errorf("erro ao criar stdout pipe: %w", err)
 	}
 
 	pc.stderr, err = pc.cmd.StderrPipe()
 	if err != nil {
+		release()
 		return fmt.Errorf("erro ao criar stderr pipe: %w", err)
 	}
 
 	err = pc.cmd.Start()
 	if err != nil {
+		release()
 		return fmt.Errorf("erro ao iniciar FFmpeg: %w", err)
 	}
 
 	go pc.logErrors()
 
+	// Cria context específico para readFrames
+	pc.readCtx, pc.readCancel = context.WithCancel(pc.ctx)
+
+	go pc.readFrames(release)
+
 	return nil
 }
 
-func (pc *PersistentCapture) readFrames() {
+func (pc *PersistentCapture) readFrames(onFirstFrame func()) {
 	reader := bufio.NewReader(pc.stdout)
 	frameBuffer := bytes.NewBuffer(make([]byte, 0, 512*1024))
 
@@ -166,19 +229,30 @@ func (pc *PersistentCapture) readFrames() {
 			tail := frameBuffer.Bytes()[frameBuffer.Len()-2:]
 			if bytes.Equal(tail, jpegEOI) {
 				size := frameBuffer.Len()
-				frameData := getFrameBuffer(size)
+				frameData := framepool.Get(size)
 				copy(frameData, frameBuffer.Bytes())
 				frameData = frameData[:size]
 
 				if bytes.HasPrefix(frameData, jpegSOI) {
-					select {
-					case pc.frameBuffer <- frameData:
-					default:
-						logger.Log.Warnw("Frame buffer cheio, descartando frame",
-							"camera_id", pc.cameraID)
-						releaseFrameBuffer(frameData)
+					budget := pc.byteBudget()
+					if !budget.TryReserve(int64(size)) {
+						metrics.FramesDropped.WithLabelValues(pc.cameraID, "byte_budget").Inc()
+						framepool.Put(frameData)
+					} else {
+						select {
+						case pc.frameBuffer <- frameData:
+						default:
+							logger.Log.Warnw("Frame buffer cheio, descartando frame",
+								"camera_id", pc.cameraID)
+							budget.Release(int64(size))
+							framepool.Put(frameData)
+						}
 					}
 					pc.markFrameReceived()
+					if onFirstFrame != nil {
+						onFirstFrame()
+						onFirstFrame = nil
+					}
 				}
 
 				frameBuffer.Reset()
@@ -226,7 +300,7 @@ func (pc *PersistentCapture) handleError(msg string) {
 		logger.Log.Errorw("Muitos erros em pouco tempo, aguardando antes de reiniciar",
 			"camera_id", pc.cameraID,
 			"error_count", pc.errorCount)
-		time.Sleep(10 * time.Second)
+		time.Sleep(pc.coordinator.Jitter(10 * time.Second))
 	}
 
 	pc.Restart()
@@ -234,17 +308,21 @@ func (pc *PersistentCapture) handleError(msg string) {
 
 func (pc *PersistentCapture) Restart() error {
 	pc.mu.Lock()
-	defer pc.mu.Unlock()
 
 	// Evita restarts simultâneos
 	if pc.restarting {
+		pc.mu.Unlock()
 		logger.Log.Debugw("Restart já em andamento, ignorando",
 			"camera_id", pc.cameraID)
 		return nil
 	}
 
 	pc.restarting = true
-	defer func() { pc.restarting = false }()
+	defer func() {
+		pc.mu.Lock()
+		pc.restarting = false
+		pc.mu.Unlock()
+	}()
 
 	// Cancela a goroutine readFrames atual
 	if pc.readCancel != nil {
@@ -265,11 +343,26 @@ func (pc *PersistentCapture) Restart() error {
 		_ = pc.stderr.Close()
 	}
 
-	// Aguarda um pouco antes de reiniciar
-	time.Sleep(time.Second)
+	coordinator := pc.coordinator
+	pc.mu.Unlock()
+
+	// Aguarda um pouco antes de reiniciar (com jitter para não sincronizar câmeras)
+	if err := sleepContext(pc.ctx, coordinator.Jitter(time.Second)); err != nil {
+		return err
+	}
+
+	release, err := pc.prepareStart()
+	if errors.Is(err, errPreflight) {
+		logger.Log.Warnw("Verificação prévia falhou, restart adiado",
+			"camera_id", pc.cameraID,
+			"error", err)
+		return err
+	}
 
 	// Reinicia o FFmpeg
-	err := pc.startFFmpeg()
+	if err == nil {
+		err = pc.startFFmpeg(release)
+	}
 	if err != nil {
 		logger.Log.Errorw("Erro ao reiniciar FFmpeg",
 			"camera_id", pc.cameraID,
@@ -277,14 +370,10 @@ func (pc *PersistentCapture) Restart() error {
 		return err
 	}
 
-	// Cria novo context para a nova goroutine readFrames
-	pc.readCtx, pc.readCancel = context.WithCancel(pc.ctx)
-
+	pc.mu.Lock()
 	pc.lastRestart = time.Now()
 	pc.errorCount = 0
-
-	// Inicia nova goroutine readFrames
-	go pc.readFrames()
+	pc.mu.Unlock()
 
 	logger.Log.Infow("Captura reiniciada",
 		"camera_id", pc.cameraID)
@@ -292,12 +381,43 @@ func (pc *PersistentCapture) Restart() error {
 	return nil
 }
 
+// SetPreflight define uma verificação executada antes de cada início do FFmpeg.
+// Se a verificação falhar o processo não é criado.
+func (pc *PersistentCapture) SetPreflight(fn func() error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.preflight = fn
+}
+
+// SetByteBudget limita os bytes dos frames aguardando no canal da captura.
+// A reserva é devolvida quando o frame é lido com GetFrame*.
+func (pc *PersistentCapture) SetByteBudget(budget *buffer.ByteBudget) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.budget = budget
+}
+
+func (pc *PersistentCapture) byteBudget() *buffer.ByteBudget {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.budget
+}
+
+// SetStartCoordinator define o coordinator usado para limitar inícios
+// simultâneos do FFmpeg e aplicar jitter aos restarts.
+func (pc *PersistentCapture) SetStartCoordinator(sc *StartCoordinator) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.coordinator = sc
+}
+
 func (pc *PersistentCapture) GetFrame() ([]byte, bool) {
 	select {
 	case frame, ok := <-pc.frameBuffer:
 		if !ok {
 			return nil, false
 		}
+		pc.byteBudget().Release(int64(len(frame)))
 		return frame, true
 	case <-time.After(5 * time.Second):
 		return nil, false
@@ -310,6 +430,7 @@ func (pc *PersistentCapture) GetFrameNonBlocking() ([]byte, bool) {
 		if !ok {
 			return nil, false
 		}
+		pc.byteBudget().Release(int64(len(frame)))
 		return frame, true
 	default:
 		return nil, false
@@ -325,6 +446,7 @@ func (pc *PersistentCapture) GetFrameWithTimeout(timeout time.Duration) ([]byte,
 		if !ok {
 			return nil, false
 		}
+		pc.byteBudget().Release(int64(len(frame)))
 		return frame, true
 	case <-ctx.Done():
 		return nil, false
@@ -360,6 +482,10 @@ func (pc *PersistentCapture) Stop() {
 	}
 
 	close(pc.frameBuffer)
+	for frame := range pc.frameBuffer {
+		pc.budget.Release(int64(len(frame)))
+		framepool.Put(frame)
+	}
 	pc.running = false
 
 	logger.Log.Infow("Captura persistente parada",
@@ -0,0 +1,297 @@
+package camera
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeResult classifica o resultado da verificação RTSP feita antes de iniciar o FFmpeg.
+type ProbeResult string
+
+const (
+	ProbeOK             ProbeResult = "ok"
+	ProbeUnreachable    ProbeResult = "unreachable"
+	ProbeAuthFailed     ProbeResult = "auth_failed"
+	ProbeStreamNotFound ProbeResult = "stream_not_found"
+	ProbeProtocolError  ProbeResult = "protocol_error"
+)
+
+// ProbeError é retornado por ProbeRTSP quando a câmera não está apta a entregar o stream.
+type ProbeError struct {
+	Result ProbeResult
+	Err    error
+}
+
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("probe rtsp (%s): %v", e.Result, e.Err)
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+const probeUserAgent = "edge-video-probe"
+
+// ProbeRTSP faz uma verificação leve da câmera: conexão TCP seguida de
+// OPTIONS e DESCRIBE (com autenticação Basic ou Digest, se exigida).
+// Permite descobrir em milissegundos se a câmera está inacessível, recusa as
+// credenciais ou não possui o stream, sem precisar criar um processo FFmpeg.
+func ProbeRTSP(ctx context.Context, rawURL string, timeout time.Duration) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &ProbeError{Result: ProbeProtocolError, Err: fmt.Errorf("url inválida: %w", err)}
+	}
+
+	if u.Scheme != "rtsp" && u.Scheme != "rtsps" {
+		return &ProbeError{Result: ProbeProtocolError, Err: fmt.Errorf("esquema não suportado: %s", u.Scheme)}
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		port := "554"
+		if u.Scheme == "rtsps" {
+			port = "322"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return &ProbeError{Result: ProbeUnreachable, Err: err}
+	}
+	defer conn.Close()
+
+	if u.Scheme == "rtsps" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return &ProbeError{Result: ProbeUnreachable, Err: err}
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	// A URL enviada nas requisições não pode carregar as credenciais
+	stripped := *u
+	stripped.User = nil
+	requestURL := stripped.String()
+
+	session := &rtspSession{
+		conn:   conn,
+		reader: textproto.NewReader(bufio.NewReader(conn)),
+	}
+
+	status, _, err := session.do("OPTIONS", requestURL, "")
+	if err != nil {
+		return classifyProbeIOError(err)
+	}
+	if status >= 500 {
+		return &ProbeError{Result: ProbeProtocolError, Err: fmt.Errorf("OPTIONS retornou %d", status)}
+	}
+
+	status, header, err := session.do("DESCRIBE", requestURL, "")
+	if err != nil {
+		return classifyProbeIOError(err)
+	}
+
+	if status == 401 {
+		if username == "" {
+			return &ProbeError{Result: ProbeAuthFailed, Err: errors.New("câmera exige autenticação e a url não possui credenciais")}
+		}
+
+		authorization, err := buildRTSPAuthorization(header.Values("WWW-Authenticate"), "DESCRIBE", requestURL, username, password)
+		if err != nil {
+			return &ProbeError{Result: ProbeAuthFailed, Err: err}
+		}
+
+		status, _, err = session.do("DESCRIBE", requestURL, authorization)
+		if err != nil {
+			return classifyProbeIOError(err)
+		}
+	}
+
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == 401 || status == 403:
+		return &ProbeError{Result: ProbeAuthFailed, Err: fmt.Errorf("DESCRIBE retornou %d", status)}
+	case status == 404:
+		return &ProbeError{Result: ProbeStreamNotFound, Err: fmt.Errorf("DESCRIBE retornou %d", status)}
+	default:
+		return &ProbeError{Result: ProbeProtocolError, Err: fmt.Errorf("DESCRIBE retornou %d", status)}
+	}
+}
+
+func classifyProbeIOError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &ProbeError{Result: ProbeUnreachable, Err: err}
+	}
+	return &ProbeError{Result: ProbeProtocolError, Err: err}
+}
+
+type rtspSession struct {
+	conn   net.Conn
+	reader *textproto.Reader
+	cseq   int
+}
+
+func (s *rtspSession) do(method, requestURL, authorization string) (int, textproto.MIMEHeader, error) {
+	s.cseq++
+
+	var req strings.Builder
+	fmt.Fprintf(&req, "%s %s RTSP/1.0\r\n", method, requestURL)
+	fmt.Fprintf(&req, "CSeq: %d\r\n", s.cseq)
+	fmt.Fprintf(&req, "User-Agent: %s\r\n", probeUserAgent)
+	if method == "DESCRIBE" {
+		req.WriteString("Accept: application/sdp\r\n")
+	}
+	if authorization != "" {
+		fmt.Fprintf(&req, "Authorization: %s\r\n", authorization)
+	}
+	req.WriteString("\r\n")
+
+	if _, err := io.WriteString(s.conn, req.String()); err != nil {
+		return 0, nil, err
+	}
+
+	line, err := s.reader.ReadLine()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Formato esperado: RTSP/1.0 200 OK
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "RTSP/") {
+		return 0, nil, fmt.Errorf("resposta RTSP inválida: %q", line)
+	}
+
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("status RTSP inválido: %q", line)
+	}
+
+	header, err := s.reader.ReadMIMEHeader()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Descarta o corpo (SDP) para manter a conexão sincronizada
+	if length, _ := strconv.Atoi(header.Get("Content-Length")); length > 0 {
+		if _, err := io.CopyN(io.Discard, s.reader.R, int64(length)); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return status, header, nil
+}
+
+// buildRTSPAuthorization monta o header Authorization a partir dos desafios
+// WWW-Authenticate. Digest tem preferência sobre Basic.
+func buildRTSPAuthorization(challenges []string, method, requestURL, username, password string) (string, error) {
+	var basic bool
+	for _, challenge := range challenges {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		switch strings.ToLower(scheme) {
+		case "digest":
+			return buildDigestAuthorization(parseAuthParams(params), method, requestURL, username, password), nil
+		case "basic":
+			basic = true
+		}
+	}
+
+	if basic {
+		token := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		return "Basic " + token, nil
+	}
+
+	return "", fmt.Errorf("desafio de autenticação não suportado: %v", challenges)
+}
+
+func buildDigestAuthorization(params map[string]string, method, requestURL, username, password string) string {
+	realm := params["realm"]
+	nonce := params["nonce"]
+
+	ha1 := md5Hex(username + ":" + realm + ":" + password)
+	ha2 := md5Hex(method + ":" + requestURL)
+
+	var response, qopFields string
+	if qop := params["qop"]; qop != "" && strings.Contains(qop, "auth") {
+		cnonce := newCNonce()
+		nc := "00000001"
+		response = md5Hex(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+		qopFields = fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s"`, nc, cnonce)
+	} else {
+		response = md5Hex(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		username, realm, nonce, requestURL, response)
+	if opaque := params["opaque"]; opaque != "" {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization + qopFields
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, s = rest[1:], ""
+			} else {
+				value, s = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func newCNonce() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
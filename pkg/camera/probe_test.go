@@ -0,0 +1,144 @@
+package camera
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRTSPServer responde OPTIONS com 200 e delega DESCRIBE ao handler,
+// que retorna o status e headers extras da resposta.
+func fakeRTSPServer(t *testing.T, describe func(header textproto.MIMEHeader) (int, string)) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := textproto.NewReader(bufio.NewReader(conn))
+				for {
+					line, err := reader.ReadLine()
+					if err != nil {
+						return
+					}
+					header, err := reader.ReadMIMEHeader()
+					if err != nil {
+						return
+					}
+
+					status, extra, body := 200, "", ""
+					if strings.HasPrefix(line, "DESCRIBE") {
+						status, extra = describe(header)
+						if status == 200 {
+							body = "v=0\r\n"
+							extra += fmt.Sprintf("Content-Length: %d\r\n", len(body))
+						}
+					}
+					fmt.Fprintf(conn, "RTSP/1.0 %d X\r\nCSeq: %s\r\n%s\r\n%s", status, header.Get("CSeq"), extra, body)
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestProbeRTSPOK(t *testing.T) {
+	addr := fakeRTSPServer(t, func(header textproto.MIMEHeader) (int, string) {
+		return 200, ""
+	})
+
+	err := ProbeRTSP(context.Background(), "rtsp://"+addr+"/stream", time.Second)
+	assert.NoError(t, err)
+}
+
+func TestProbeRTSPDigestAuth(t *testing.T) {
+	addr := fakeRTSPServer(t, func(header textproto.MIMEHeader) (int, string) {
+		auth := header.Get("Authorization")
+		if auth == "" {
+			return 401, "WWW-Authenticate: Digest realm=\"cam\", nonce=\"abc\"\r\n"
+		}
+		expected := buildDigestAuthorization(map[string]string{"realm": "cam", "nonce": "abc"},
+			"DESCRIBE", extractURI(auth), "admin", "secret")
+		if auth != expected || strings.Contains(auth, "secret") {
+			return 401, ""
+		}
+		return 200, ""
+	})
+
+	err := ProbeRTSP(context.Background(), "rtsp://admin:secret@"+addr+"/stream", time.Second)
+	assert.NoError(t, err)
+
+	err = ProbeRTSP(context.Background(), "rtsp://admin:wrong@"+addr+"/stream", time.Second)
+	var probeErr *ProbeError
+	require.True(t, errors.As(err, &probeErr))
+	assert.Equal(t, ProbeAuthFailed, probeErr.Result)
+}
+
+func TestProbeRTSPBasicAuthWithoutCredentials(t *testing.T) {
+	addr := fakeRTSPServer(t, func(header textproto.MIMEHeader) (int, string) {
+		return 401, "WWW-Authenticate: Basic realm=\"cam\"\r\n"
+	})
+
+	err := ProbeRTSP(context.Background(), "rtsp://"+addr+"/stream", time.Second)
+	var probeErr *ProbeError
+	require.True(t, errors.As(err, &probeErr))
+	assert.Equal(t, ProbeAuthFailed, probeErr.Result)
+}
+
+func TestProbeRTSPStreamNotFound(t *testing.T) {
+	addr := fakeRTSPServer(t, func(header textproto.MIMEHeader) (int, string) {
+		return 404, ""
+	})
+
+	err := ProbeRTSP(context.Background(), "rtsp://"+addr+"/missing", time.Second)
+	var probeErr *ProbeError
+	require.True(t, errors.As(err, &probeErr))
+	assert.Equal(t, ProbeStreamNotFound, probeErr.Result)
+}
+
+func TestProbeRTSPUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	start := time.Now()
+	err = ProbeRTSP(context.Background(), "rtsp://"+addr+"/stream", 500*time.Millisecond)
+	var probeErr *ProbeError
+	require.True(t, errors.As(err, &probeErr))
+	assert.Equal(t, ProbeUnreachable, probeErr.Result)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="IP Camera(23)", nonce="a1b2", qop="auth", stale=FALSE`)
+
+	assert.Equal(t, "IP Camera(23)", params["realm"])
+	assert.Equal(t, "a1b2", params["nonce"])
+	assert.Equal(t, "auth", params["qop"])
+	assert.Equal(t, "FALSE", params["stale"])
+}
+
+func extractURI(auth string) string {
+	start := strings.Index(auth, `uri="`) + len(`uri="`)
+	end := strings.Index(auth[start:], `"`)
+	return auth[start : start+end]
+}
@@ -0,0 +1,103 @@
+package camera
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/internal/metadata"
+	"github.com/T3-Labs/edge-video/internal/storage"
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/logger"
+	"github.com/T3-Labs/edge-video/pkg/mq"
+	"github.com/T3-Labs/edge-video/pkg/worker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// newShutdownTestCapture cria uma câmera sem FFmpeg: os frames são
+// empurrados direto no frame buffer e apenas o dispatcher é iniciado.
+func newShutdownTestCapture(t *testing.T, pool *worker.Pool, publisher mq.Publisher, dispatch bool) (*Capture, *buffer.FrameBuffer) {
+	t.Helper()
+	if logger.Log == nil {
+		logger.Log = zap.NewNop().Sugar()
+	}
+
+	frameBuffer := buffer.NewFrameBuffer(100)
+	c := NewCapture(
+		context.Background(),
+		Config{ID: "cam1", URL: "rtsp://invalido"},
+		time.Second,
+		nil,
+		publisher,
+		storage.NewRedisStore("", 0, "", "", false, "", ""),
+		metadata.NewPublisher(nil, "", "", false),
+		pool,
+		frameBuffer,
+		nil,
+		false,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if dispatch {
+		c.started.Store(true)
+		go c.bufferDispatcher()
+	}
+	return c, frameBuffer
+}
+
+func TestCaptureShutdownFlushesBufferedFrames(t *testing.T) {
+	pool := worker.NewPool(context.Background(), 1, 100)
+	defer pool.Close()
+
+	var published atomic.Int32
+	publisher := &mq.MockPublisher{
+		PublishFunc: func(ctx context.Context, cameraID string, payload []byte) error {
+			time.Sleep(time.Millisecond)
+			published.Add(1)
+			return nil
+		},
+	}
+
+	c, frameBuffer := newShutdownTestCapture(t, pool, publisher, true)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, frameBuffer.Push(buffer.Frame{CameraID: "cam1", Data: []byte("jpeg"), Timestamp: time.Now()}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	flushed, abandoned := c.Shutdown(ctx)
+	assert.Zero(t, abandoned)
+	assert.Equal(t, 10, flushed)
+
+	assert.Zero(t, pool.Shutdown(ctx))
+	assert.Equal(t, int32(10), published.Load())
+}
+
+func TestCaptureShutdownAbandonsOnDeadline(t *testing.T) {
+	pool := worker.NewPool(context.Background(), 1, 100)
+	defer pool.Close()
+
+	publisher := &mq.MockPublisher{}
+	c, frameBuffer := newShutdownTestCapture(t, pool, publisher, false)
+
+	var released atomic.Int32
+	for i := 0; i < 5; i++ {
+		frame := buffer.NewFrame("cam1", []byte("jpeg"), time.Now(), func() { released.Add(1) })
+		assert.NoError(t, frameBuffer.Push(frame))
+	}
+
+	// Sem dispatcher o buffer nunca esvazia: tudo é abandonado no prazo
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	flushed, abandoned := c.Shutdown(ctx)
+	assert.Zero(t, flushed)
+	assert.Equal(t, 5, abandoned)
+	assert.Equal(t, int32(5), released.Load())
+	assert.Zero(t, frameBuffer.Size())
+}
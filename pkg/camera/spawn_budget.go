@@ -0,0 +1,175 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// ErrSpawnWaitExceeded indica que a câmera esperou demais por uma vaga para
+// criar o processo FFmpeg e o frame foi descartado.
+var ErrSpawnWaitExceeded = errors.New("tempo máximo de espera por vaga de FFmpeg excedido")
+
+// SpawnBudget limita quantos processos FFmpeg do modo clássico podem rodar ao
+// mesmo tempo, somando todas as câmeras. As vagas são distribuídas entre as
+// câmeras em round-robin, para que uma câmera rápida não monopolize o
+// orçamento. Um SpawnBudget nil não aplica limite.
+type SpawnBudget struct {
+	capacity int
+	maxWait  time.Duration
+
+	mu     sync.Mutex
+	inUse  int
+	queues map[string][]chan struct{}
+	ring   []string // Câmeras com espera pendente, na ordem em que serão atendidas
+}
+
+// NewSpawnBudget cria um orçamento de maxProcesses processos simultâneos.
+// Esperas maiores que maxWait falham com ErrSpawnWaitExceeded (zero espera
+// indefinidamente).
+func NewSpawnBudget(maxProcesses int, maxWait time.Duration) *SpawnBudget {
+	if maxProcesses <= 0 {
+		maxProcesses = 1
+	}
+
+	return &SpawnBudget{
+		capacity: maxProcesses,
+		maxWait:  maxWait,
+		queues:   make(map[string][]chan struct{}),
+	}
+}
+
+// Acquire aguarda uma vaga para a câmera. A função retornada devolve a vaga e
+// pode ser chamada mais de uma vez.
+func (b *SpawnBudget) Acquire(ctx context.Context, cameraID string) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+
+	b.mu.Lock()
+	if b.inUse < b.capacity && len(b.ring) == 0 {
+		b.inUse++
+		b.mu.Unlock()
+		return b.granted(cameraID, start), nil
+	}
+
+	ready := make(chan struct{}, 1)
+	if len(b.queues[cameraID]) == 0 {
+		b.ring = append(b.ring, cameraID)
+	}
+	b.queues[cameraID] = append(b.queues[cameraID], ready)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return b.granted(cameraID, start), nil
+	case <-timeout:
+		err = ErrSpawnWaitExceeded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	removed := b.removeWaiter(cameraID, ready)
+	b.mu.Unlock()
+
+	if !removed {
+		// A vaga foi concedida enquanto desistíamos: repassa para o próximo
+		b.release()
+	}
+
+	metrics.FFmpegSpawnWait.WithLabelValues(cameraID).Observe(time.Since(start).Seconds())
+	return nil, err
+}
+
+// Stats retorna quantas vagas estão em uso e quantas câmeras aguardam.
+func (b *SpawnBudget) Stats() (inUse, waiting int) {
+	if b == nil {
+		return 0, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, queue := range b.queues {
+		waiting += len(queue)
+	}
+	return b.inUse, waiting
+}
+
+func (b *SpawnBudget) granted(cameraID string, start time.Time) func() {
+	metrics.FFmpegSpawnWait.WithLabelValues(cameraID).Observe(time.Since(start).Seconds())
+	metrics.FFmpegSpawnsActive.Inc()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			metrics.FFmpegSpawnsActive.Dec()
+			b.release()
+		})
+	}
+}
+
+// release transfere a vaga para a próxima câmera do round-robin ou, se ninguém
+// estiver esperando, devolve-a ao orçamento.
+func (b *SpawnBudget) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.ring) == 0 {
+		b.inUse--
+		return
+	}
+
+	cameraID := b.ring[0]
+	b.ring = b.ring[1:]
+
+	queue := b.queues[cameraID]
+	ready := queue[0]
+	if len(queue) > 1 {
+		b.queues[cameraID] = queue[1:]
+		b.ring = append(b.ring, cameraID)
+	} else {
+		delete(b.queues, cameraID)
+	}
+
+	ready <- struct{}{}
+}
+
+func (b *SpawnBudget) removeWaiter(cameraID string, ready chan struct{}) bool {
+	queue := b.queues[cameraID]
+	for i, waiter := range queue {
+		if waiter != ready {
+			continue
+		}
+
+		queue = append(queue[:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			b.queues[cameraID] = queue
+			return true
+		}
+
+		delete(b.queues, cameraID)
+		for j, id := range b.ring {
+			if id == cameraID {
+				b.ring = append(b.ring[:j], b.ring[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
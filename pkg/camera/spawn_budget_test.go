@@ -0,0 +1,98 @@
+package camera
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpawnBudgetLimitsProcesses(t *testing.T) {
+	budget := NewSpawnBudget(2, 0)
+
+	release1, err := budget.Acquire(context.Background(), "cam1")
+	require.NoError(t, err)
+	release2, err := budget.Acquire(context.Background(), "cam2")
+	require.NoError(t, err)
+
+	inUse, _ := budget.Stats()
+	assert.Equal(t, 2, inUse)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = budget.Acquire(ctx, "cam3")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release1()
+	release1()
+	release2()
+
+	inUse, waiting := budget.Stats()
+	assert.Equal(t, 0, inUse)
+	assert.Equal(t, 0, waiting)
+}
+
+func TestSpawnBudgetMaxWait(t *testing.T) {
+	budget := NewSpawnBudget(1, 30*time.Millisecond)
+
+	release, err := budget.Acquire(context.Background(), "cam1")
+	require.NoError(t, err)
+	defer release()
+
+	_, err = budget.Acquire(context.Background(), "cam2")
+	assert.ErrorIs(t, err, ErrSpawnWaitExceeded)
+
+	_, waiting := budget.Stats()
+	assert.Equal(t, 0, waiting)
+}
+
+func TestSpawnBudgetRoundRobin(t *testing.T) {
+	budget := NewSpawnBudget(1, 0)
+
+	hold, err := budget.Acquire(context.Background(), "busy")
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	enqueue := func(cameraID string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := budget.Acquire(context.Background(), cameraID)
+			require.NoError(t, err)
+			mu.Lock()
+			order = append(order, cameraID)
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	// A câmera rápida enfileira três pedidos antes da lenta
+	enqueue("fast")
+	waitQueued(t, budget, 1)
+	enqueue("fast")
+	waitQueued(t, budget, 2)
+	enqueue("fast")
+	waitQueued(t, budget, 3)
+	enqueue("slow")
+	waitQueued(t, budget, 4)
+
+	hold()
+	wg.Wait()
+
+	require.Len(t, order, 4)
+	assert.Equal(t, []string{"fast", "slow", "fast", "fast"}, order)
+}
+
+func waitQueued(t *testing.T, budget *SpawnBudget, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		_, waiting := budget.Stats()
+		return waiting == n
+	}, time.Second, time.Millisecond)
+}
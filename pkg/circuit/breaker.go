@@ -2,6 +2,7 @@ package circuit
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 )
@@ -39,6 +40,14 @@ type Breaker struct {
 	backoffMultiplier float64
 	currentBackoff    time.Duration
 	
+	// Jitter aleatório aplicado ao backoff para evitar que vários circuitos
+	// reabram ao mesmo tempo (ex.: após queda de energia na loja)
+	jitterFraction float64
+	retryAfter     time.Duration
+	
+	// now é o relógio usado para o backoff (substituído nos testes)
+	now func() time.Time
+	
 	mu            sync.RWMutex
 	state         State
 	failures      int64
@@ -62,6 +71,8 @@ func NewBreaker(name string, maxFailures int64, resetTimeout time.Duration) *Bre
 		maxBackoff:        10 * time.Minute,
 		backoffMultiplier: 2.0,
 		currentBackoff:    initialBackoff,
+		retryAfter:        initialBackoff,
+		now:               time.Now,
 		state:             StateClosed,
 		lastStateTime:     time.Now(),
 	}
@@ -92,8 +103,8 @@ func (cb *Breaker) Allow() bool {
 		return true
 		
 	case StateOpen:
-		// Usa backoff exponencial em vez de resetTimeout fixo
-		if time.Since(cb.lastFailTime) > cb.currentBackoff {
+		// Usa backoff exponencial (com jitter) em vez de resetTimeout fixo
+		if cb.now().Sub(cb.lastFailTime) > cb.retryAfter {
 			cb.setState(StateHalfOpen)
 			return true
 		}
@@ -135,28 +146,47 @@ func (cb *Breaker) RecordFailure() {
 	defer cb.mu.Unlock()
 	
 	cb.failures++
-	cb.lastFailTime = time.Now()
+	cb.lastFailTime = cb.now()
 	cb.successes = 0
 	
 	switch cb.state {
 	case StateClosed:
 		if cb.failures >= cb.maxFailures {
-			cb.setState(StateOpen)
-			// Incrementa o backoff exponencialmente quando abrimos o circuito
-			cb.currentBackoff = time.Duration(float64(cb.currentBackoff) * cb.backoffMultiplier)
-			if cb.currentBackoff > cb.maxBackoff {
-				cb.currentBackoff = cb.maxBackoff
-			}
+			cb.open()
 		}
 		
 	case StateHalfOpen:
-		cb.setState(StateOpen)
-		// Incrementa o backoff quando falhamos no estado half-open
-		cb.currentBackoff = time.Duration(float64(cb.currentBackoff) * cb.backoffMultiplier)
-		if cb.currentBackoff > cb.maxBackoff {
-			cb.currentBackoff = cb.maxBackoff
-		}
+		// Falha no estado half-open reabre o circuito
+		cb.open()
+	}
+}
+
+// open abre o circuito e incrementa o backoff exponencialmente.
+func (cb *Breaker) open() {
+	cb.currentBackoff = time.Duration(float64(cb.currentBackoff) * cb.backoffMultiplier)
+	if cb.currentBackoff > cb.maxBackoff {
+		cb.currentBackoff = cb.maxBackoff
+	}
+	cb.retryAfter = cb.currentBackoff
+	if cb.jitterFraction > 0 {
+		cb.retryAfter += time.Duration(rand.Float64() * cb.jitterFraction * float64(cb.currentBackoff))
+	}
+	cb.setState(StateOpen)
+}
+
+// SetJitter define a fração do backoff (0 a 1) adicionada aleatoriamente ao
+// tempo de espera antes da próxima tentativa.
+func (cb *Breaker) SetJitter(fraction float64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
 	}
+	cb.jitterFraction = fraction
 }
 
 func (cb *Breaker) setState(newState State) {
@@ -165,7 +195,7 @@ func (cb *Breaker) setState(newState State) {
 		cb.state = newState
 		cb.lastStateTime = time.Now()
 		fmt.Printf("Circuit breaker %s: %s -> %s (falhas: %d, pr\u00f3xima tentativa em: %v)\n",
-			cb.name, oldState, newState, cb.failures, cb.currentBackoff)
+			cb.name, oldState, newState, cb.failures, cb.retryAfter)
 	}
 }
 
@@ -136,6 +136,30 @@ func TestBreakerHalfOpenFailure(t *testing.T) {
 	assert.Equal(t, StateOpen, breaker.State())
 }
 
+func TestBreakerJitter(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		breaker := NewBreaker("test", 1, 10*time.Second)
+		breaker.SetJitter(0.5)
+		
+		start := time.Now()
+		clock := start
+		breaker.now = func() time.Time { return clock }
+		
+		breaker.RecordFailure()
+		assert.Equal(t, StateOpen, breaker.State())
+		backoff := breaker.Stats().CurrentBackoff
+		
+		// O jitter só atrasa a nova tentativa: antes do backoff é recusada
+		clock = start.Add(backoff)
+		assert.False(t, breaker.Allow())
+		
+		// e no máximo 50% depois dele é admitida
+		clock = start.Add(backoff + backoff/2 + time.Millisecond)
+		assert.True(t, breaker.Allow())
+		assert.Equal(t, StateHalfOpen, breaker.State())
+	}
+}
+
 func TestBreakerConcurrent(t *testing.T) {
 	breaker := NewBreaker("test", 50, 1*time.Second)
 	
@@ -47,3 +47,70 @@ func TestLoadConfig_FileNotFound(t *testing.T) {
 	_, err := LoadConfig("non_existent_file.yaml")
 	assert.Error(t, err)
 }
+
+func TestLoadConfig_StaggerAndJitterZero(t *testing.T) {
+	path := writeConfig(t, `
+protocol: "amqp"
+optimization:
+  startup_stagger_ms: 0
+  circuit_jitter_percent: 0
+cameras:
+  - id: "cam1"
+    url: "rtsp://test.com/1"
+`)
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.Optimization.StartupStaggerMs, "0 explícito é diferente de ausente") {
+		assert.Equal(t, 0, *cfg.Optimization.StartupStaggerMs)
+	}
+	if assert.NotNil(t, cfg.Optimization.CircuitJitterPercent) {
+		assert.Equal(t, 0.0, *cfg.Optimization.CircuitJitterPercent)
+	}
+
+	cfg, err = LoadConfig(writeConfig(t, "protocol: \"amqp\"\n"))
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.Optimization.StartupStaggerMs)
+	assert.Nil(t, cfg.Optimization.CircuitJitterPercent)
+}
+
+func TestCompressedMQTTDestinations(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+protocol: "mqtt"
+compression:
+  enabled: true
+cameras:
+  - id: "cam1"
+    url: "rtsp://test.com/1"
+`))
+	assert.NoError(t, err, "compressão com MQTT continua válida")
+	assert.Equal(t, []string{"mqtt"}, cfg.CompressedMQTTDestinations())
+
+	cfg, err = LoadConfig(writeConfig(t, `
+compression:
+  enabled: false
+destinations:
+  - name: "central"
+    protocol: "amqp"
+  - name: "painel"
+    protocol: "mqtt"
+cameras:
+  - id: "cam1"
+    url: "rtsp://test.com/1"
+    compression: true
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"painel"}, cfg.CompressedMQTTDestinations())
+
+	// Sem câmera comprimindo não há o que avisar
+	cfg, err = LoadConfig(writeConfig(t, `
+protocol: "mqtt"
+compression:
+  enabled: true
+cameras:
+  - id: "cam1"
+    url: "rtsp://test.com/1"
+    compression: false
+`))
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.CompressedMQTTDestinations())
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxRoutingKeyLen é o limite do AMQP para routing keys e nomes de exchange.
+	maxRoutingKeyLen = 255
+	// maxKafkaTopicLen é o limite do Kafka para nomes de tópico.
+	maxKafkaTopicLen = 249
+
+	// DefaultKafkaTopic é o tópico usado quando kafka.topic está vazio.
+	DefaultKafkaTopic = "camera-frames"
+	// DefaultNATSSubject é o template usado quando nats.subject está vazio.
+	DefaultNATSSubject = "camera.{camera_id}.frames"
+)
+
+// ExpandTemplate substitui os placeholders {nome} de tmpl pelos valores de
+// vars. Placeholders desconhecidos ou chaves sem fechamento são erro.
+func ExpandTemplate(tmpl string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	rest := tmpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return "", fmt.Errorf("template %q: '}' sem '{'", tmpl)
+			}
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		b.WriteString(rest[:open])
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("template %q: '{' sem '}'", tmpl)
+		}
+		name := rest[open+1 : open+end]
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("template %q: placeholder desconhecido {%s}", tmpl, name)
+		}
+		b.WriteString(value)
+		rest = rest[open+end+1:]
+	}
+}
+
+// TemplateVars retorna os placeholders disponíveis para a câmera: {vhost},
+// {camera} (ou {camera_id}) e {label.<chave>} para cada label. As chaves dos
+// labels são minúsculas (o viper normaliza as chaves dos mapas).
+func (c CameraConfig) TemplateVars(vhost string) map[string]string {
+	vars := map[string]string{
+		"vhost":     vhost,
+		"camera":    c.ID,
+		"camera_id": c.ID,
+	}
+	for key, value := range c.Labels {
+		vars["label."+strings.ToLower(key)] = value
+	}
+	return vars
+}
+
+// AMQPRoute retorna o exchange e a routing key dos frames da câmera. Sem
+// sobrescrita na câmera valem amqp.exchange e routing_key_prefix + ID.
+func (c CameraConfig) AMQPRoute(amqpCfg AMQPConfig, vhost string) (exchange, routingKey string, err error) {
+	vars := c.TemplateVars(vhost)
+
+	exchange = amqpCfg.Exchange
+	if c.Exchange != "" {
+		if exchange, err = ExpandTemplate(c.Exchange, vars); err != nil {
+			return "", "", fmt.Errorf("câmera %s: exchange: %w", c.ID, err)
+		}
+	}
+
+	routingKey = amqpCfg.RoutingKeyPrefix + c.ID
+	if c.RoutingKey != "" {
+		if routingKey, err = ExpandTemplate(c.RoutingKey, vars); err != nil {
+			return "", "", fmt.Errorf("câmera %s: routing_key: %w", c.ID, err)
+		}
+		if routingKey == "" {
+			return "", "", fmt.Errorf("câmera %s: routing_key vazia", c.ID)
+		}
+	}
+
+	if len(exchange) > maxRoutingKeyLen {
+		return "", "", fmt.Errorf("câmera %s: exchange com mais de %d bytes", c.ID, maxRoutingKeyLen)
+	}
+	if len(routingKey) > maxRoutingKeyLen {
+		return "", "", fmt.Errorf("câmera %s: routing_key com mais de %d bytes", c.ID, maxRoutingKeyLen)
+	}
+	return exchange, routingKey, nil
+}
+
+// KafkaTopic retorna o tópico dos frames da câmera a partir de kafka.topic
+// (padrão DefaultKafkaTopic). Nos valores dos placeholders os caracteres que
+// o Kafka não aceita em tópicos viram '_'.
+func (c CameraConfig) KafkaTopic(kafkaCfg KafkaConfig, vhost string) (string, error) {
+	tmpl := kafkaCfg.Topic
+	if tmpl == "" {
+		tmpl = DefaultKafkaTopic
+	}
+
+	topic, err := ExpandTemplate(tmpl, sanitizeVars(c.TemplateVars(vhost), sanitizeKafkaTopic))
+	if err != nil {
+		return "", fmt.Errorf("câmera %s: kafka.topic: %w", c.ID, err)
+	}
+	switch {
+	case topic == "" || topic == "." || topic == "..":
+		return "", fmt.Errorf("câmera %s: kafka.topic: tópico inválido: %q", c.ID, topic)
+	case len(topic) > maxKafkaTopicLen:
+		return "", fmt.Errorf("câmera %s: kafka.topic com mais de %d bytes", c.ID, maxKafkaTopicLen)
+	case sanitizeKafkaTopic(topic) != topic:
+		return "", fmt.Errorf("câmera %s: kafka.topic: %q tem caracteres fora de [a-zA-Z0-9._-]", c.ID, topic)
+	}
+	return topic, nil
+}
+
+// NATSSubject retorna o subject dos frames da câmera a partir de nats.subject
+// (padrão DefaultNATSSubject). Nos valores dos placeholders os caracteres com
+// significado em subjects viram '_', então um valor nunca cria tokens.
+func (c CameraConfig) NATSSubject(natsCfg NATSConfig, vhost string) (string, error) {
+	subject, err := ExpandTemplate(natsCfg.subjectTemplate(), sanitizeVars(c.TemplateVars(vhost), natsSubjectReplacer.Replace))
+	if err != nil {
+		return "", fmt.Errorf("câmera %s: nats.subject: %w", c.ID, err)
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" || token == "*" || token == ">" || strings.ContainsAny(token, " \t") {
+			return "", fmt.Errorf("câmera %s: nats.subject: subject inválido: %q", c.ID, subject)
+		}
+	}
+	return subject, nil
+}
+
+// StreamSubject retorna o subject do stream JetStream: o template de
+// nats.subject com cada token que tem placeholder trocado por '*'.
+func (n NATSConfig) StreamSubject() string {
+	// Os pontos dentro de placeholders ({label.site}) não separam tokens
+	var tokens []string
+	start, depth := 0, 0
+	tmpl := n.subjectTemplate()
+	for i := 0; i <= len(tmpl); i++ {
+		switch {
+		case i == len(tmpl) || (tmpl[i] == '.' && depth == 0):
+			token := tmpl[start:i]
+			if strings.ContainsRune(token, '{') {
+				token = "*"
+			}
+			tokens = append(tokens, token)
+			start = i + 1
+		case tmpl[i] == '{':
+			depth++
+		case tmpl[i] == '}' && depth > 0:
+			depth--
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+func (n NATSConfig) subjectTemplate() string {
+	if n.Subject == "" {
+		return DefaultNATSSubject
+	}
+	return n.Subject
+}
+
+// natsSubjectReplacer troca os caracteres com significado em subjects.
+var natsSubjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_")
+
+// sanitizeKafkaTopic troca por '_' os caracteres fora de [a-zA-Z0-9._-].
+func sanitizeKafkaTopic(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func sanitizeVars(vars map[string]string, sanitize func(string) string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for name, value := range vars {
+		out[name] = sanitize(value)
+	}
+	return out
+}
+
+// validateCameraRoutes verifica se os templates de todas as câmeras resolvem
+// para cada destino em uso (o do nível superior ou cada item de
+// destinations): exchange e routing key no AMQP, tópico no Kafka e subject no
+// NATS.
+func (c *Config) validateCameraRoutes() error {
+	dests := c.Destinations
+	if len(dests) == 0 {
+		dests = []DestinationConfig{{Protocol: c.Protocol, AMQP: c.AMQP, Kafka: c.Kafka, NATS: c.NATS}}
+	}
+	vhost := c.ExtractVhostFromAMQP()
+
+	var errs []error
+	for _, dest := range dests {
+		name := dest.Name
+		if name == "" {
+			name = dest.Protocol
+		}
+		if name == "" {
+			name = "amqp"
+		}
+		for _, cam := range c.Cameras {
+			var err error
+			switch dest.Protocol {
+			case "", "amqp":
+				_, _, err = cam.AMQPRoute(dest.AMQP, vhostFromURL(dest.AMQP.AmqpURL))
+			case "kafka":
+				_, err = cam.KafkaTopic(dest.Kafka, vhost)
+			case "nats":
+				_, err = cam.NATSSubject(dest.NATS, vhost)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
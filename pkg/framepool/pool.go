@@ -0,0 +1,162 @@
+// Package framepool fornece buffers reutilizáveis para frames, organizados em
+// classes de tamanho. Cada classe mantém uma lista limitada de buffers livres,
+// o que permite contabilizar com exatidão os bytes em uso e os bytes guardados
+// no pool (ao contrário de sync.Pool, que descarta itens a cada GC).
+package framepool
+
+import (
+	"sync/atomic"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// ClassSizes são as capacidades dos buffers de cada classe. Um frame usa a
+// menor classe em que cabe; frames maiores que a última classe são alocados
+// sob medida e não voltam ao pool.
+var ClassSizes = []int{64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// DefaultMaxPooledBytes é o limite de bytes guardados pelo pool padrão.
+const DefaultMaxPooledBytes = 64 << 20
+
+var defaultPool = New(DefaultMaxPooledBytes)
+
+// Get obtém do pool padrão um buffer com len == size.
+func Get(size int) []byte {
+	return defaultPool.Get(size)
+}
+
+// Put devolve ao pool padrão um buffer obtido com Get.
+func Put(buf []byte) {
+	defaultPool.Put(buf)
+}
+
+// DefaultStats retorna as estatísticas do pool padrão.
+func DefaultStats() Stats {
+	return defaultPool.Stats()
+}
+
+type sizeClass struct {
+	size int
+	free chan []byte
+}
+
+// Pool é um pool de buffers com classes de tamanho.
+type Pool struct {
+	classes []*sizeClass
+
+	inUseBytes  atomic.Int64
+	pooledBytes atomic.Int64
+	outstanding atomic.Int64
+	hits        atomic.Int64
+	misses      atomic.Int64
+	oversize    atomic.Int64
+}
+
+// Stats resume o estado do pool.
+type Stats struct {
+	InUseBytes  int64 // Capacidade dos buffers entregues e ainda não devolvidos
+	PooledBytes int64 // Capacidade dos buffers livres guardados no pool
+	Outstanding int64 // Buffers entregues e ainda não devolvidos
+	Hits        int64 // Gets atendidos por um buffer livre
+	Misses      int64 // Gets que precisaram alocar um buffer da classe
+	Oversize    int64 // Gets maiores que a maior classe
+}
+
+// New cria um pool que guarda até maxPooledBytes em buffers livres, divididos
+// igualmente entre as classes (ao menos um buffer por classe).
+func New(maxPooledBytes int64) *Pool {
+	perClass := maxPooledBytes / int64(len(ClassSizes))
+
+	p := &Pool{}
+	for _, size := range ClassSizes {
+		slots := int(perClass / int64(size))
+		if slots < 1 {
+			slots = 1
+		}
+		p.classes = append(p.classes, &sizeClass{size: size, free: make(chan []byte, slots)})
+	}
+	return p
+}
+
+// Get retorna um buffer com len == size. O conteúdo não é zerado.
+func (p *Pool) Get(size int) []byte {
+	if size <= 0 {
+		return nil
+	}
+
+	class := p.classFor(size)
+	var buf []byte
+	switch {
+	case class == nil:
+		p.oversize.Add(1)
+		metrics.FramePoolRequests.WithLabelValues("oversize").Inc()
+		buf = make([]byte, size)
+
+	default:
+		select {
+		case buf = <-class.free:
+			p.hits.Add(1)
+			p.pooledBytes.Add(-int64(class.size))
+			metrics.FramePoolRequests.WithLabelValues("hit").Inc()
+		default:
+			p.misses.Add(1)
+			metrics.FramePoolRequests.WithLabelValues("miss").Inc()
+			buf = make([]byte, class.size)
+		}
+	}
+
+	p.inUseBytes.Add(int64(cap(buf)))
+	p.outstanding.Add(1)
+	p.updateMetrics()
+	return buf[:size]
+}
+
+// Put devolve um buffer obtido com Get. O buffer não pode ser usado depois.
+// Buffers que não pertencem a nenhuma classe, ou cuja classe já está com a
+// lista de livres cheia, ficam para o GC.
+func (p *Pool) Put(buf []byte) {
+	if buf == nil {
+		return
+	}
+
+	p.inUseBytes.Add(-int64(cap(buf)))
+	p.outstanding.Add(-1)
+
+	if class := p.classFor(cap(buf)); class != nil && class.size == cap(buf) {
+		select {
+		case class.free <- buf[:0]:
+			p.pooledBytes.Add(int64(class.size))
+		default:
+		}
+	}
+	p.updateMetrics()
+}
+
+// Stats retorna as estatísticas do pool.
+func (p *Pool) Stats() Stats {
+	return Stats{
+		InUseBytes:  p.inUseBytes.Load(),
+		PooledBytes: p.pooledBytes.Load(),
+		Outstanding: p.outstanding.Load(),
+		Hits:        p.hits.Load(),
+		Misses:      p.misses.Load(),
+		Oversize:    p.oversize.Load(),
+	}
+}
+
+func (p *Pool) classFor(size int) *sizeClass {
+	for _, class := range p.classes {
+		if size <= class.size {
+			return class
+		}
+	}
+	return nil
+}
+
+func (p *Pool) updateMetrics() {
+	if p != defaultPool {
+		return
+	}
+	metrics.FramePoolBytes.WithLabelValues("in_use").Set(float64(p.inUseBytes.Load()))
+	metrics.FramePoolBytes.WithLabelValues("pooled").Set(float64(p.pooledBytes.Load()))
+}
@@ -0,0 +1,193 @@
+package framepool
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertNoLeaks falha se algum buffer obtido com Get não foi devolvido.
+func assertNoLeaks(t *testing.T, p *Pool) {
+	t.Helper()
+	stats := p.Stats()
+	assert.Zero(t, stats.Outstanding, "buffers não devolvidos ao pool")
+	assert.Zero(t, stats.InUseBytes, "bytes ainda marcados como em uso")
+}
+
+func TestPoolSizeClasses(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	tests := []struct {
+		size        int
+		expectedCap int
+	}{
+		{1, 64 << 10},
+		{64 << 10, 64 << 10},
+		{64<<10 + 1, 256 << 10},
+		{180 << 10, 256 << 10},
+		{900 << 10, 1 << 20},
+		{3 << 20, 4 << 20},
+	}
+
+	for _, tt := range tests {
+		buf := p.Get(tt.size)
+		assert.Len(t, buf, tt.size)
+		assert.Equal(t, tt.expectedCap, cap(buf), "tamanho %d", tt.size)
+		p.Put(buf)
+	}
+
+	assertNoLeaks(t, p)
+}
+
+func TestPoolReusesBuffers(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	buf := p.Get(100 << 10)
+	buf[0] = 42
+	p.Put(buf)
+
+	assert.Equal(t, int64(256<<10), p.Stats().PooledBytes)
+
+	again := p.Get(200 << 10)
+	assert.Equal(t, byte(42), again[0], "deveria reutilizar o mesmo buffer")
+	assert.Equal(t, int64(1), p.Stats().Hits)
+	assert.Zero(t, p.Stats().PooledBytes)
+
+	p.Put(again)
+	assertNoLeaks(t, p)
+}
+
+func TestPoolOversizeNotPooled(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	buf := p.Get(5 << 20)
+	assert.Len(t, buf, 5<<20)
+	assert.Equal(t, int64(5<<20), p.Stats().InUseBytes)
+	p.Put(buf)
+
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.Oversize)
+	assert.Zero(t, stats.PooledBytes)
+	assertNoLeaks(t, p)
+}
+
+func TestPoolBoundsPooledBytes(t *testing.T) {
+	// 4 classes com 1 MB cada: 16 buffers de 64 KB
+	p := New(4 << 20)
+
+	bufs := make([][]byte, 32)
+	for i := range bufs {
+		bufs[i] = p.Get(1024)
+	}
+	for _, buf := range bufs {
+		p.Put(buf)
+	}
+
+	assert.Equal(t, int64(16*(64<<10)), p.Stats().PooledBytes)
+	assertNoLeaks(t, p)
+}
+
+func TestPoolGetZero(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	assert.Nil(t, p.Get(0))
+	p.Put(nil)
+	assertNoLeaks(t, p)
+}
+
+func TestPoolLeakDetection(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	leaked := p.Get(1024)
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.Outstanding)
+	assert.Equal(t, int64(64<<10), stats.InUseBytes)
+
+	p.Put(leaked)
+	assertNoLeaks(t, p)
+}
+
+func TestPoolConcurrentCameras(t *testing.T) {
+	p := New(DefaultMaxPooledBytes)
+
+	var wg sync.WaitGroup
+	for cam := 0; cam < 16; cam++ {
+		wg.Add(1)
+		go func(cam int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				size := 32<<10 + rand.IntN(2<<20)
+				buf := p.Get(size)
+				require.Len(t, buf, size)
+				// Marca o buffer para detectar compartilhamento entre câmeras
+				buf[0], buf[size-1] = byte(cam), byte(cam)
+				if buf[0] != byte(cam) || buf[size-1] != byte(cam) {
+					t.Errorf("buffer compartilhado entre câmeras")
+				}
+				p.Put(buf)
+			}
+		}(cam)
+	}
+	wg.Wait()
+
+	assertNoLeaks(t, p)
+}
+
+// BenchmarkPool30FPS simula câmeras entregando frames a 30 fps: cada câmera
+// mantém alguns frames em trânsito (buffer + worker pool) antes de devolvê-los.
+func BenchmarkPool30FPS(b *testing.B) {
+	frameSizes := []int{58 << 10, 184 << 10, 355 << 10, 1200 << 10}
+
+	for _, cameras := range []int{8, 32, 128} {
+		b.Run(fmt.Sprintf("cameras=%d", cameras), func(b *testing.B) {
+			p := New(DefaultMaxPooledBytes)
+			const inFlight = 4
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var wg sync.WaitGroup
+			perCamera := b.N/cameras + 1
+			for cam := 0; cam < cameras; cam++ {
+				wg.Add(1)
+				go func(size int) {
+					defer wg.Done()
+					ring := make([][]byte, 0, inFlight)
+					for i := 0; i < perCamera; i++ {
+						buf := p.Get(size)
+						buf[0] = 0xFF
+						ring = append(ring, buf)
+						if len(ring) == inFlight {
+							p.Put(ring[0])
+							ring = append(ring[:0], ring[1:]...)
+						}
+					}
+					for _, buf := range ring {
+						p.Put(buf)
+					}
+				}(frameSizes[cam%len(frameSizes)])
+			}
+			wg.Wait()
+
+			b.StopTimer()
+			stats := p.Stats()
+			if stats.Outstanding != 0 {
+				b.Fatalf("vazamento: %d buffers não devolvidos", stats.Outstanding)
+			}
+			b.ReportMetric(float64(stats.Hits)/float64(stats.Hits+stats.Misses)*100, "hit%")
+		})
+	}
+}
+
+// BenchmarkMakeFrame é a referência sem pool.
+func BenchmarkMakeFrame(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := make([]byte, 184<<10)
+		buf[0] = 0xFF
+	}
+}
@@ -47,6 +47,22 @@ type MemoryStats struct {
 	UsagePercent  float64
 	Level         MemoryLevel
 	Timestamp     time.Time
+
+	// Bytes de frames retidos no pipeline (buffers, filas e processamento),
+	// informados pelo PipelineUsage configurado
+	PipelineBytes        int64
+	PipelineLimitBytes   int64
+	PipelineUsagePercent float64
+	// Nível do pipeline, com limites próprios: um orçamento cheio só
+	// descarta frames, então nunca chega a EMERGENCY
+	PipelineLevel MemoryLevel
+}
+
+// PipelineUsage informa os bytes de frames retidos no pipeline e o limite
+// configurado (zero = sem limite). Implementado por buffer.ByteBudget.
+type PipelineUsage interface {
+	Used() int64
+	Limit() int64
 }
 
 type ThresholdConfig struct {
@@ -56,12 +72,16 @@ type ThresholdConfig struct {
 	EmergencyPercent float64
 	CheckInterval    time.Duration
 	GCTriggerPercent float64
+	// Limites do uso do orçamento de frames do pipeline
+	PipelineWarningPercent  float64
+	PipelineCriticalPercent float64
 }
 
 type Controller struct {
 	mu              sync.RWMutex
 	config          ThresholdConfig
 	currentLevel    MemoryLevel
+	pipelineLevel   MemoryLevel
 	stats           MemoryStats
 	callbacks       map[MemoryLevel][]func(MemoryStats)
 	gcInProgress    bool
@@ -71,6 +91,7 @@ type Controller struct {
 	cancel          context.CancelFunc
 	throttleMap     map[string]*ThrottleState
 	throttleMu      sync.Mutex
+	pipeline        PipelineUsage
 }
 
 type ThrottleState struct {
@@ -100,6 +121,9 @@ func NewController(maxMemoryMB uint64) *Controller {
 		EmergencyPercent: 85.0,
 		CheckInterval:    2 * time.Second,
 		GCTriggerPercent: 70.0,
+
+		PipelineWarningPercent:  80.0,
+		PipelineCriticalPercent: 95.0,
 	}
 
 	c := &Controller{
@@ -161,6 +185,16 @@ func (c *Controller) updateStats() {
 	usagePercent := (float64(allocMB) / float64(c.config.MaxMemoryMB)) * 100
 
 	c.mu.Lock()
+	var pipelineBytes, pipelineLimit int64
+	var pipelinePercent float64
+	if c.pipeline != nil {
+		pipelineBytes = c.pipeline.Used()
+		pipelineLimit = c.pipeline.Limit()
+		if pipelineLimit > 0 {
+			pipelinePercent = float64(pipelineBytes) / float64(pipelineLimit) * 100
+		}
+	}
+
 	c.stats = MemoryStats{
 		Alloc:        memStats.Alloc,
 		TotalAlloc:   memStats.TotalAlloc,
@@ -173,6 +207,11 @@ func (c *Controller) updateStats() {
 		UsagePercent: usagePercent,
 		Level:        c.determineLevel(usagePercent),
 		Timestamp:    time.Now(),
+
+		PipelineBytes:        pipelineBytes,
+		PipelineLimitBytes:   pipelineLimit,
+		PipelineUsagePercent: pipelinePercent,
+		PipelineLevel:        c.determinePipelineLevel(pipelinePercent),
 	}
 	c.mu.Unlock()
 }
@@ -190,16 +229,38 @@ func (c *Controller) determineLevel(usagePercent float64) MemoryLevel {
 	}
 }
 
+// determinePipelineLevel classifica o uso do orçamento de frames. O
+// pipeline no limite pede para reduzir a captura, não para coletar lixo.
+func (c *Controller) determinePipelineLevel(usagePercent float64) MemoryLevel {
+	switch {
+	case usagePercent >= c.config.PipelineCriticalPercent:
+		return MemoryCritical
+	case usagePercent >= c.config.PipelineWarningPercent:
+		return MemoryWarning
+	default:
+		return MemoryNormal
+	}
+}
+
 func (c *Controller) checkAndAct() {
 	c.mu.Lock()
 	stats := c.stats
 	oldLevel := c.currentLevel
 	newLevel := stats.Level
+	oldPipelineLevel := c.pipelineLevel
+	c.pipelineLevel = stats.PipelineLevel
 	c.mu.Unlock()
 
 	if newLevel != oldLevel {
 		c.onLevelChange(oldLevel, newLevel, stats)
 	}
+	if stats.PipelineLevel != oldPipelineLevel && logger.Log != nil {
+		logger.Log.Warnw("Nível do pipeline de frames alterado",
+			"old_level", oldPipelineLevel,
+			"new_level", stats.PipelineLevel,
+			"pipeline_mb", stats.PipelineBytes/1024/1024,
+			"pipeline_percent", fmt.Sprintf("%.2f%%", stats.PipelineUsagePercent))
+	}
 
 	switch newLevel {
 	case MemoryWarning:
@@ -223,7 +284,9 @@ func (c *Controller) onLevelChange(old, new MemoryLevel, stats MemoryStats) {
 			"new_level", new,
 			"usage_percent", fmt.Sprintf("%.2f%%", stats.UsagePercent),
 			"alloc_mb", stats.Alloc/1024/1024,
-			"heap_mb", stats.HeapAlloc/1024/1024)
+			"heap_mb", stats.HeapAlloc/1024/1024,
+			"pipeline_mb", stats.PipelineBytes/1024/1024,
+			"pipeline_percent", fmt.Sprintf("%.2f%%", stats.PipelineUsagePercent))
 	}
 
 	c.notifyCallbacks(new, stats)
@@ -306,6 +369,14 @@ func (c *Controller) triggerGC(reason string) {
 	}()
 }
 
+// SetPipelineUsage registra a fonte do uso de memória do pipeline de frames,
+// normalmente o orçamento global de bytes.
+func (c *Controller) SetPipelineUsage(usage PipelineUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pipeline = usage
+}
+
 func (c *Controller) GetStats() MemoryStats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -318,8 +389,21 @@ func (c *Controller) GetLevel() MemoryLevel {
 	return c.currentLevel
 }
 
+// GetPipelineLevel retorna o nível de uso do orçamento de frames.
+func (c *Controller) GetPipelineLevel() MemoryLevel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.pipelineLevel
+}
+
+// throttleLevel combina o heap e o pipeline para reduzir a captura. O
+// pipeline não passa de CRITICAL, então sozinho não pausa as câmeras.
+func (c *Controller) throttleLevel() MemoryLevel {
+	return max(c.GetLevel(), c.GetPipelineLevel())
+}
+
 func (c *Controller) ShouldThrottle() bool {
-	level := c.GetLevel()
+	level := c.throttleLevel()
 	return level >= MemoryCritical
 }
 
@@ -342,7 +426,7 @@ func (c *Controller) GetThrottleDelay(cameraID string) time.Duration {
 		c.throttleMap[cameraID] = state
 	}
 
-	level := c.GetLevel()
+	level := c.throttleLevel()
 
 	switch level {
 	case MemoryNormal:
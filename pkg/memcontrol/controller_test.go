@@ -230,3 +230,63 @@ func TestUpdateConfig(t *testing.T) {
 		t.Errorf("WarningPercent esperado: 50.0, obtido: %.2f", config.WarningPercent)
 	}
 }
+
+type fakePipelineUsage struct {
+	used, limit int64
+}
+
+func (f *fakePipelineUsage) Used() int64  { return f.used }
+func (f *fakePipelineUsage) Limit() int64 { return f.limit }
+
+func TestPipelineUsageHasOwnLevel(t *testing.T) {
+	// Limite de heap alto o bastante para o heap do teste ficar em NORMAL
+	controller := NewController(1 << 20)
+	usage := &fakePipelineUsage{used: 90, limit: 100}
+	controller.SetPipelineUsage(usage)
+
+	controller.updateStats()
+	controller.checkAndAct()
+	stats := controller.GetStats()
+
+	if stats.PipelineBytes != 90 || stats.PipelineLimitBytes != 100 {
+		t.Errorf("uso do pipeline esperado 90/100, obtido %d/%d", stats.PipelineBytes, stats.PipelineLimitBytes)
+	}
+	if stats.Level != MemoryNormal {
+		t.Errorf("o pipeline não deve alterar o nível do heap, obtido: %s", stats.Level)
+	}
+	if stats.PipelineLevel != MemoryWarning {
+		t.Errorf("nível do pipeline esperado: WARNING, obtido: %s", stats.PipelineLevel)
+	}
+	if controller.ShouldThrottle() {
+		t.Error("pipeline em WARNING não deve limitar a captura")
+	}
+
+	// Orçamento cheio: limita a captura, mas não pausa
+	usage.used = 100
+	controller.updateStats()
+	controller.checkAndAct()
+	if level := controller.GetPipelineLevel(); level != MemoryCritical {
+		t.Errorf("nível do pipeline esperado: CRITICAL, obtido: %s", level)
+	}
+	if !controller.ShouldThrottle() {
+		t.Error("pipeline em CRITICAL deve limitar a captura")
+	}
+	if controller.ShouldPause() {
+		t.Error("o pipeline sozinho não deve pausar a captura")
+	}
+	if controller.GetLevel() != MemoryNormal {
+		t.Errorf("nível do heap esperado: NORMAL, obtido: %s", controller.GetLevel())
+	}
+}
+
+func TestPipelineUsageWithoutLimit(t *testing.T) {
+	controller := NewController(1 << 20)
+	controller.SetPipelineUsage(&fakePipelineUsage{used: 1 << 30})
+
+	controller.updateStats()
+	stats := controller.GetStats()
+
+	if stats.Level != MemoryNormal || stats.PipelineLevel != MemoryNormal {
+		t.Errorf("sem limite o pipeline não deve alterar o nível, obtido: %s/%s", stats.Level, stats.PipelineLevel)
+	}
+}
@@ -47,6 +47,38 @@ var (
 		[]string{"pool_name"},
 	)
 	
+	WorkerPoolWorkers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_worker_pool_workers",
+			Help: "Número atual de workers do pool",
+		},
+		[]string{"pool_name"},
+	)
+	
+	WorkerPoolScaleEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_worker_pool_scale_events_total",
+			Help: "Eventos de autoscaling do worker pool (up, down, blocked)",
+		},
+		[]string{"pool_name", "direction"},
+	)
+	
+	WorkerPoolQueueWait = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_worker_pool_queue_wait_seconds",
+			Help: "Espera média dos jobs na fila do worker pool desde a última avaliação",
+		},
+		[]string{"pool_name"},
+	)
+	
+	WorkerQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_worker_queue_depth",
+			Help: "Jobs aguardando na fila de cada câmera dentro do worker pool",
+		},
+		[]string{"camera_id"},
+	)
+	
 	BufferSize = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "edge_video_buffer_size",
@@ -97,6 +129,15 @@ var (
 		[]string{"camera_id"},
 	)
 	
+	CompressionRatio = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "edge_video_compression_ratio",
+			Help:    "Razão entre o tamanho original e o comprimido (zstd) dos frames",
+			Buckets: []float64{0.95, 1, 1.01, 1.02, 1.05, 1.1, 1.25, 1.5, 2, 3},
+		},
+		[]string{"camera_id"},
+	)
+	
 	LastSuccessfulCapture = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "edge_video_last_successful_capture_timestamp",
@@ -113,6 +154,179 @@ var (
 		[]string{"camera_id"},
 	)
 	
+	CameraProbeResults = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_camera_probe_results_total",
+			Help: "Resultados da verificação RTSP executada antes de iniciar o FFmpeg",
+		},
+		[]string{"camera_id", "result"},
+	)
+	
+	FFmpegStarting = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "edge_video_ffmpeg_starting",
+			Help: "Número de processos FFmpeg em fase de inicialização",
+		},
+	)
+	
+	FFmpegStartWait = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "edge_video_ffmpeg_start_wait_seconds",
+			Help:    "Tempo de espera por uma vaga para iniciar o FFmpeg",
+			Buckets: []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30},
+		},
+	)
+	
+	FFmpegSpawnWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "edge_video_ffmpeg_spawn_wait_seconds",
+			Help:    "Tempo de espera por uma vaga no orçamento de processos FFmpeg do modo clássico",
+			Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"camera_id"},
+	)
+	
+	FFmpegSpawnsActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "edge_video_ffmpeg_spawns_active",
+			Help: "Número de processos FFmpeg do modo clássico em execução",
+		},
+	)
+	
+	FramePoolBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_frame_pool_bytes",
+			Help: "Bytes do pool de buffers de frames (in_use = entregues, pooled = livres no pool)",
+		},
+		[]string{"state"},
+	)
+	
+	FramePoolRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_frame_pool_requests_total",
+			Help: "Pedidos ao pool de buffers de frames (hit, miss, oversize)",
+		},
+		[]string{"result"},
+	)
+	
+	FrameBudgetBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_frame_budget_bytes",
+			Help: "Bytes de frames reservados em cada orçamento (câmera ou global)",
+		},
+		[]string{"budget"},
+	)
+	
+	SpoolEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "edge_video_spool_entries",
+			Help: "Número de mensagens aguardando reenvio no spool em disco",
+		},
+	)
+	
+	SpoolBytes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "edge_video_spool_bytes",
+			Help: "Bytes ocupados pelas mensagens pendentes no spool em disco",
+		},
+	)
+	
+	SpoolOperations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_spool_operations_total",
+			Help: "Operações do spool em disco (write, replay, expired, evicted, error)",
+		},
+		[]string{"operation"},
+	)
+	
+	SinkPublishes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_sink_publishes_total",
+			Help: "Publicações por destino do publisher composto (success, error, dropped)",
+		},
+		[]string{"sink", "result"},
+	)
+	
+	SinkQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_sink_queue_depth",
+			Help: "Mensagens aguardando na fila de cada destino",
+		},
+		[]string{"sink"},
+	)
+	
+	SinkPublishLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "edge_video_sink_publish_latency_seconds",
+			Help:    "Latência de publicação por destino",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"sink"},
+	)
+	
+	AMQPConfirms = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_amqp_confirms_total",
+			Help: "Confirmações do broker por câmera (ack, nack, timeout, lost)",
+		},
+		[]string{"camera_id", "result"},
+	)
+	
+	AMQPConfirmsPending = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "edge_video_amqp_confirms_pending",
+			Help: "Mensagens publicadas aguardando confirmação do broker",
+		},
+	)
+	
+	AMQPReturned = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_amqp_returned_total",
+			Help: "Frames devolvidos pelo broker por falta de fila de destino (mandatory)",
+		},
+		[]string{"camera_id"},
+	)
+	
+	AMQPCameraUnroutable = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_amqp_camera_unroutable",
+			Help: "1 enquanto os frames da câmera voltam sem fila de destino além do prazo de alerta",
+		},
+		[]string{"camera_id"},
+	)
+	
+	AMQPBrokerBlocked = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_amqp_broker_blocked",
+			Help: "1 enquanto o broker bloqueia as publicações (connection.blocked ou channel.flow)",
+		},
+		[]string{"connection"},
+	)
+	
+	CaptureBrokerBlockedSeconds = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_capture_broker_blocked_seconds_total",
+			Help: "Tempo em que a captura clássica ficou pausada pelo bloqueio do broker",
+		},
+		[]string{"camera_id"},
+	)
+	
+	NATSObjectOffloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "edge_video_nats_object_offloads_total",
+			Help: "Frames maiores que o max_payload enviados ao object store do NATS",
+		},
+		[]string{"camera_id"},
+	)
+	
+	TLSCertificateExpiry = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "edge_video_tls_certificate_expiry_timestamp_seconds",
+			Help: "Validade (Unix) dos certificados TLS por conexão e tipo (ca, client, server)",
+		},
+		[]string{"connection", "certificate"},
+	)
+	
 	ActiveCamerasCount = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "edge_video_active_cameras_total",
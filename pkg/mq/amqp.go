@@ -2,33 +2,170 @@ package mq
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/T3-Labs/edge-video/pkg/metrics"
 	"github.com/streadway/amqp"
 )
 
+// ErrBrokerBlocked indica que o broker está recusando publicações
+// (connection.blocked por alarme de memória ou disco, ou channel.flow).
+var ErrBrokerBlocked = errors.New("amqp broker blocked publishing")
+
+// AMQPOptions configura recursos opcionais do AMQPPublisher.
+type AMQPOptions struct {
+	// Confirms habilita publisher confirms: os frames passam a ser publicados
+	// num canal dedicado em modo confirm e cada ack/nack é contabilizado.
+	Confirms bool
+	// ConfirmTimeout é o prazo para o broker confirmar uma mensagem.
+	// Zero usa 5s.
+	ConfirmTimeout time.Duration
+	// WaitForConfirm faz Publish aguardar a confirmação. Nack ou timeout
+	// viram erro, devolvendo a mensagem ao chamador (por exemplo, para o
+	// spool). Sem ele, Publish retorna após o envio e Flush aguarda as
+	// confirmações pendentes.
+	WaitForConfirm bool
+	// TLS é usado nas conexões amqps://. nil usa a configuração padrão
+	// (CAs do sistema, sem certificado de cliente).
+	TLS *tls.Config
+	// Routes sobrescreve o exchange e a routing key por câmera (chave:
+	// camera_id). Câmeras sem rota usam exchange e routingKeyPrefix+ID.
+	Routes map[string]AMQPRoute
+	// Channels é o tamanho do pool de canais usados para publicar frames.
+	// Cada câmera fica presa a um canal, preservando a ordem dos seus
+	// frames, e cada canal tem seu próprio fluxo de confirms. Zero usa 1.
+	Channels int
+	// Name identifica a conexão nas métricas. Vazio usa "amqp".
+	Name string
+	// Mandatory publica os frames com mandatory=true: frames sem fila de
+	// destino voltam do broker (basic.return) em vez de sumirem, e são
+	// contados por câmera.
+	Mandatory bool
+	// UnroutableAlert é por quanto tempo os frames de uma câmera precisam
+	// continuar voltando para OnUnroutable ser avisado. Zero usa 1min.
+	UnroutableAlert time.Duration
+	// Topology é declarada a cada (re)conexão, junto com os exchanges dos
+	// frames (topic, duráveis) que ela não listar. Divergências com o que já
+	// existe no broker viram ErrTopologyDrift.
+	Topology AMQPTopology
+	// FrameExpiration é a expiração de cada frame (propriedade expiration):
+	// o broker descarta frames mais antigos que isso ainda na fila. Zero
+	// não expira.
+	FrameExpiration time.Duration
+}
+
+// AMQPRoute é o destino dos frames de uma câmera.
+type AMQPRoute struct {
+	Exchange   string
+	RoutingKey string
+}
+
 type AMQPPublisher struct {
 	mu               sync.RWMutex
+	reconnectMu      sync.Mutex
 	conn             *amqp.Connection
 	channel          *amqp.Channel
 	notifyClose      chan *amqp.Error
 	exchange         string
 	routingKeyPrefix string
 	amqpURL          string
+	vhost            string
 	closed           bool
+	opts             AMQPOptions
+
+	// pool são os canais de publicação de frames; o canal principal fica
+	// para as declarações e para PublishMessage (metadados)
+	pool       []*amqpChannel
+	assignMu   sync.RWMutex
+	assigned   map[string]*amqpChannel
+	nextAssign int
+
+	// Bloqueio pelo broker: connection.blocked vale para a conexão em que
+	// chegou e channel.flow para o canal do pool
+	blockMu     sync.Mutex
+	blockedConn *amqp.Connection
+	blockReason string
+	flowPaused  map[int]*amqp.Channel
+	blocked     bool
+	onBlocked   func(blocked bool, reason string)
+
+	returns *returnTracker
+
+	// extraExchanges são os exchanges registrados com DeclareExchange
+	extraExchanges []AMQPExchange
+}
+
+// amqpChannel é um canal do pool. Se o broker fechar só este canal (por
+// exemplo, por um erro de publicação), ele é reaberto na mesma conexão sem
+// afetar os demais.
+type amqpChannel struct {
+	index    int
+	mu       sync.RWMutex
+	ch       *amqp.Channel
+	confirms *confirmTracker
+}
+
+func (c *amqpChannel) channel() *amqp.Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ch
 }
 
 func NewAMQPPublisher(amqpURL, exchange, routingKeyPrefix string) (*AMQPPublisher, error) {
+	return NewAMQPPublisherWithOptions(amqpURL, exchange, routingKeyPrefix, AMQPOptions{})
+}
+
+// NewAMQPPublisherWithOptions cria um publisher com os recursos de opts.
+func NewAMQPPublisherWithOptions(amqpURL, exchange, routingKeyPrefix string, opts AMQPOptions) (*AMQPPublisher, error) {
+	if opts.ConfirmTimeout <= 0 {
+		opts.ConfirmTimeout = 5 * time.Second
+	}
+	if opts.Channels <= 0 {
+		opts.Channels = 1
+	}
+	if opts.Name == "" {
+		opts.Name = "amqp"
+	}
+	if opts.UnroutableAlert <= 0 {
+		opts.UnroutableAlert = time.Minute
+	}
+
+	if err := opts.Topology.Validate(); err != nil {
+		return nil, err
+	}
+
+	vhost, _ := ExtractVhostFromURL(amqpURL)
+	if opts.TLS != nil && !strings.HasPrefix(amqpURL, "amqps://") {
+		return nil, errors.New("amqp tls requires an amqps:// URL")
+	}
+
 	publisher := &AMQPPublisher{
 		exchange:         exchange,
 		routingKeyPrefix: routingKeyPrefix,
 		amqpURL:          amqpURL,
+		vhost:            vhost,
+		opts:             opts,
+		assigned:         make(map[string]*amqpChannel),
+		flowPaused:       make(map[int]*amqp.Channel),
+	}
+	for i := 0; i < opts.Channels; i++ {
+		slot := &amqpChannel{index: i}
+		if opts.Confirms {
+			slot.confirms = newConfirmTracker(opts.ConfirmTimeout)
+		}
+		publisher.pool = append(publisher.pool, slot)
+	}
+	if opts.Mandatory {
+		publisher.returns = newReturnTracker(opts.UnroutableAlert)
 	}
 
 	// Tenta conectar com retry
@@ -40,37 +177,42 @@ func NewAMQPPublisher(amqpURL, exchange, routingKeyPrefix string) (*AMQPPublishe
 			log.Printf("Conectado ao RabbitMQ com sucesso")
 			return publisher, nil
 		}
+		if errors.Is(err, ErrTopologyDrift) {
+			// Outra tentativa encontraria a mesma divergência
+			publisher.closeTrackers()
+			return nil, fmt.Errorf("topologia AMQP diverge da existente no broker: %w", err)
+		}
 		log.Printf("Tentativa %d/%d de conexão ao RabbitMQ falhou: %v. Tentando novamente em 5s...", i+1, maxRetries, err)
 		time.Sleep(5 * time.Second)
 	}
 
+	publisher.closeTrackers()
 	return nil, fmt.Errorf("falha ao conectar ao RabbitMQ após %d tentativas: %w", maxRetries, err)
 }
 
 func (p *AMQPPublisher) connect() error {
-	conn, err := amqp.Dial(p.amqpURL)
+	var conn *amqp.Connection
+	var err error
+	if p.opts.TLS != nil {
+		// A biblioteca preenche o ServerName na configuração recebida
+		conn, err = amqp.DialTLS(p.amqpURL, p.opts.TLS.Clone())
+	} else {
+		conn, err = amqp.Dial(p.amqpURL)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
+	go p.watchBlocked(conn, conn.NotifyBlocked(make(chan amqp.Blocking, 1)))
 
-	ch, err := conn.Channel()
-	if err != nil {
+	if err := declareTopology(conn, p.topology()); err != nil {
 		conn.Close()
-		return fmt.Errorf("failed to open a channel: %w", err)
+		return err
 	}
 
-	if err := ch.ExchangeDeclare(
-		p.exchange,
-		"topic",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	); err != nil {
-		ch.Close()
+	ch, err := conn.Channel()
+	if err != nil {
 		conn.Close()
-		return fmt.Errorf("failed to declare an exchange: %w", err)
+		return fmt.Errorf("failed to open a channel: %w", err)
 	}
 
 	notify := make(chan *amqp.Error, 1)
@@ -88,23 +230,256 @@ func (p *AMQPPublisher) connect() error {
 	p.notifyClose = notify
 	p.mu.Unlock()
 
-	go p.handleConnectionClose(notify)
+	// Os frames usam canais próprios: o canal principal é compartilhado
+	// (PublishMessage) e publicações de terceiros nele desalinhariam as
+	// delivery tags dos confirms
+	for _, slot := range p.pool {
+		if err := p.openSlot(conn, slot, nil); err != nil {
+			p.closeCurrent()
+			return err
+		}
+	}
+
+	go p.handleConnectionClose(conn, notify)
 
 	return nil
 }
 
-func (p *AMQPPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
-	routingKey := p.routingKeyPrefix + cameraID
+// openSlot abre o canal de slot em conn, no lugar de failed. Se o canal do
+// slot já não for failed, outra goroutine o reabriu e nada é feito.
+func (p *AMQPPublisher) openSlot(conn *amqp.Connection, slot *amqpChannel, failed *amqp.Channel) error {
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+
+	if slot.ch != failed {
+		return nil
+	}
+	slot.ch = nil
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open publish channel %d: %w", slot.index, err)
+	}
+
+	var confirms chan amqp.Confirmation
+	if slot.confirms != nil {
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return fmt.Errorf("failed to enable publisher confirms: %w", err)
+		}
+		confirms = ch.NotifyPublish(make(chan amqp.Confirmation, confirmBufferSize))
+	}
+
+	notify := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go p.watchFlow(slot, ch, ch.NotifyFlow(make(chan bool, 1)))
+	if p.returns != nil {
+		go p.returns.listen(ch.NotifyReturn(make(chan amqp.Return, returnBufferSize)))
+	}
+	slot.ch = ch
+	if slot.confirms != nil {
+		slot.confirms.attach(ch, confirms)
+	}
+
+	go p.handleSlotClose(conn, slot, ch, notify)
+	return nil
+}
+
+// handleSlotClose reabre o canal do slot quando o broker o fecha com a
+// conexão ainda aberta. Queda da conexão fica com handleConnectionClose.
+func (p *AMQPPublisher) handleSlotClose(conn *amqp.Connection, slot *amqpChannel, ch *amqp.Channel, notify <-chan *amqp.Error) {
+	err, ok := <-notify
+	if !ok || err == nil || p.isClosed() || conn.IsClosed() {
+		return
+	}
+
+	log.Printf("Canal %d de publicação RabbitMQ fechado: %v", slot.index, err)
+
+	var openErr error
+	backoff := []time.Duration{100 * time.Millisecond, 1 * time.Second, 5 * time.Second}
+	for attempt, wait := range backoff {
+		if p.isClosed() || conn.IsClosed() {
+			return
+		}
+		if openErr = p.openSlot(conn, slot, ch); openErr == nil {
+			log.Printf("Canal %d de publicação RabbitMQ reaberto na tentativa %d", slot.index, attempt+1)
+			return
+		}
+		// Uma tentativa que falhou deixa o slot sem canal
+		ch = nil
+		time.Sleep(wait)
+	}
+	log.Printf("Falha ao reabrir o canal %d de publicação RabbitMQ: %v", slot.index, openErr)
+}
+
+// OnBlocked registra fn para ser chamada a cada mudança do estado de
+// bloqueio pelo broker. fn é chamada pela goroutine de leitura da conexão
+// AMQP e não pode bloquear. Se o broker já estiver bloqueando, fn é chamada
+// na hora.
+func (p *AMQPPublisher) OnBlocked(fn func(blocked bool, reason string)) {
+	p.blockMu.Lock()
+	defer p.blockMu.Unlock()
+	p.onBlocked = fn
+	if p.blocked {
+		fn(true, p.blockedReasonLocked())
+	}
+}
+
+// OnUnroutable registra fn para ser avisada quando os frames de uma câmera
+// continuam voltando sem fila de destino por mais de UnroutableAlert, e
+// quando voltam a ser roteados. Só tem efeito com Mandatory.
+func (p *AMQPPublisher) OnUnroutable(fn func(UnroutableEvent)) {
+	if p.returns != nil {
+		p.returns.setHandler(fn)
+	}
+}
+
+// Blocked informa se o broker está bloqueando publicações (na conexão ou em
+// algum canal do pool) e o motivo.
+func (p *AMQPPublisher) Blocked() (bool, string) {
+	p.blockMu.Lock()
+	defer p.blockMu.Unlock()
+	return p.blocked, p.blockedReasonLocked()
+}
+
+func (p *AMQPPublisher) slotBlocked(slot *amqpChannel) (string, bool) {
+	p.blockMu.Lock()
+	defer p.blockMu.Unlock()
+	if p.blockedConn != nil {
+		return p.blockReason, true
+	}
+	if p.flowPaused[slot.index] != nil {
+		return "channel flow paused", true
+	}
+	return "", false
+}
+
+// watchBlocked acompanha connection.blocked/unblocked de conn. Uma conexão
+// nova começa desbloqueada.
+func (p *AMQPPublisher) watchBlocked(conn *amqp.Connection, blockings <-chan amqp.Blocking) {
+	for b := range blockings {
+		p.blockMu.Lock()
+		if b.Active {
+			p.blockedConn = conn
+			p.blockReason = b.Reason
+		} else if p.blockedConn == conn {
+			p.blockedConn = nil
+		}
+		p.updateBlockedLocked()
+		p.blockMu.Unlock()
+	}
+
+	p.blockMu.Lock()
+	if p.blockedConn == conn {
+		p.blockedConn = nil
+		p.updateBlockedLocked()
+	}
+	p.blockMu.Unlock()
+}
+
+// watchFlow acompanha o channel.flow do canal ch do slot.
+func (p *AMQPPublisher) watchFlow(slot *amqpChannel, ch *amqp.Channel, flow <-chan bool) {
+	for active := range flow {
+		p.blockMu.Lock()
+		if !active {
+			p.flowPaused[slot.index] = ch
+		} else if p.flowPaused[slot.index] == ch {
+			delete(p.flowPaused, slot.index)
+		}
+		p.updateBlockedLocked()
+		p.blockMu.Unlock()
+	}
+
+	p.blockMu.Lock()
+	if p.flowPaused[slot.index] == ch {
+		delete(p.flowPaused, slot.index)
+		p.updateBlockedLocked()
+	}
+	p.blockMu.Unlock()
+}
+
+func (p *AMQPPublisher) blockedReasonLocked() string {
+	if p.blockedConn != nil {
+		return p.blockReason
+	}
+	if len(p.flowPaused) > 0 {
+		return "channel flow paused"
+	}
+	return ""
+}
+
+// updateBlockedLocked recalcula o estado agregado e avisa onBlocked quando
+// ele muda. Chamada com blockMu.
+func (p *AMQPPublisher) updateBlockedLocked() {
+	blocked := p.blockedConn != nil || len(p.flowPaused) > 0
+	if blocked == p.blocked {
+		return
+	}
+	p.blocked = blocked
+	reason := p.blockedReasonLocked()
+
+	if blocked {
+		log.Printf("RabbitMQ bloqueou as publicações (%s): %s", p.opts.Name, reason)
+		metrics.AMQPBrokerBlocked.WithLabelValues(p.opts.Name).Set(1)
+	} else {
+		log.Printf("RabbitMQ liberou as publicações (%s)", p.opts.Name)
+		metrics.AMQPBrokerBlocked.WithLabelValues(p.opts.Name).Set(0)
+	}
+	if p.onBlocked != nil {
+		p.onBlocked(blocked, reason)
+	}
+}
+
+// slotFor retorna o canal do pool da câmera. As câmeras são distribuídas
+// entre os canais na ordem em que aparecem e não mudam mais de canal.
+func (p *AMQPPublisher) slotFor(cameraID string) *amqpChannel {
+	p.assignMu.RLock()
+	slot, ok := p.assigned[cameraID]
+	p.assignMu.RUnlock()
+	if ok {
+		return slot
+	}
+
+	p.assignMu.Lock()
+	defer p.assignMu.Unlock()
+	if slot, ok := p.assigned[cameraID]; ok {
+		return slot
+	}
+	slot = p.pool[p.nextAssign%len(p.pool)]
+	p.nextAssign++
+	p.assigned[cameraID] = slot
+	return slot
+}
+
+func (p *AMQPPublisher) Publish(ctx context.Context, cameraID string, payload []byte) (err error) {
+	route := p.route(cameraID)
+	slot := p.slotFor(cameraID)
+
+	// Com o broker bloqueado a publicação travaria até o timeout: falha na
+	// hora e o frame fica com o chamador (spool)
+	if reason, blocked := p.slotBlocked(slot); blocked {
+		return fmt.Errorf("failed to publish a message: %w: %s", ErrBrokerBlocked, reason)
+	}
+
+	msg := p.framePublishing(ctx, cameraID, payload)
+	if p.returns != nil {
+		// Só um frame aceito pelo broker indica que a câmera voltou a ter rota
+		sentAt := time.Now()
+		defer func() {
+			if err == nil {
+				p.returns.published(cameraID, sentAt)
+			}
+		}()
+	}
 	var lastErr error
 
 	for attempt := 0; attempt < 2; attempt++ {
-		ch := p.getChannel()
+		ch := slot.channel()
 		if ch == nil {
-			if recErr := p.reconnect(); recErr != nil {
+			if recErr := p.recover(slot, nil); recErr != nil {
 				lastErr = recErr
 				break
 			}
-			ch = p.getChannel()
+			ch = slot.channel()
 			if ch == nil {
 				lastErr = fmt.Errorf("amqp channel unavailable after reconnect")
 				break
@@ -116,29 +491,46 @@ func (p *AMQPPublisher) Publish(ctx context.Context, cameraID string, payload []
 			break
 		}
 
-		err := ch.Publish(
-			p.exchange,
-			routingKey,
-			false,
-			false,
-			amqp.Publishing{
-				ContentType: "application/octet-stream",
-				Body:        payload,
-				Timestamp:   time.Now(),
-			},
-		)
-		if err == nil {
-			return nil
+		publish := func() error {
+			return ch.Publish(
+				route.Exchange,
+				route.RoutingKey,
+				p.opts.Mandatory,
+				false,
+				msg,
+			)
 		}
 
-		lastErr = err
+		if slot.confirms == nil {
+			err := publish()
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+		} else {
+			pc, err := slot.confirms.publish(ch, cameraID, publish)
+			if err == nil {
+				if !p.opts.WaitForConfirm {
+					return nil
+				}
+				if err := slot.confirms.wait(ctx, pc); err != nil {
+					return fmt.Errorf("failed to publish a message: %w", err)
+				}
+				return nil
+			}
+			lastErr = err
+			if errors.Is(err, errStaleChannel) {
+				// O canal foi trocado por uma reconexão: tenta no novo
+				continue
+			}
+		}
 
-		if !p.shouldReconnect(err) {
+		if !p.shouldReconnect(lastErr) {
 			break
 		}
 
-		if recErr := p.reconnect(); recErr != nil {
-			lastErr = fmt.Errorf("%w; reconnect failed: %v", err, recErr)
+		if recErr := p.recover(slot, ch); recErr != nil {
+			lastErr = fmt.Errorf("%w; reconnect failed: %v", lastErr, recErr)
 			break
 		}
 	}
@@ -146,6 +538,84 @@ func (p *AMQPPublisher) Publish(ctx context.Context, cameraID string, payload []
 	return fmt.Errorf("failed to publish a message: %w", lastErr)
 }
 
+// recover repõe o canal do slot após uma falha em failed: com a conexão
+// aberta basta reabrir o canal; senão reconecta.
+func (p *AMQPPublisher) recover(slot *amqpChannel, failed *amqp.Channel) error {
+	conn := p.getConn()
+	if conn != nil && !conn.IsClosed() {
+		err := p.openSlot(conn, slot, failed)
+		if err == nil || !conn.IsClosed() {
+			return err
+		}
+	}
+	return p.reconnect(conn)
+}
+
+// framePublishing monta a mensagem de um frame. Os cabeçalhos identificam a
+// câmera e, quando o contexto traz uma FrameInfo, a sequência, o instante da
+// captura, as dimensões, a codificação e o hash do frame.
+func (p *AMQPPublisher) framePublishing(ctx context.Context, cameraID string, payload []byte) amqp.Publishing {
+	headers := amqp.Table{"camera_id": cameraID}
+	if p.vhost != "" {
+		headers["vhost"] = p.vhost
+	}
+
+	msg := amqp.Publishing{
+		ContentType: "image/jpeg",
+		Body:        payload,
+		Timestamp:   time.Now(),
+		Headers:     headers,
+	}
+	if p.opts.FrameExpiration > 0 {
+		msg.Expiration = strconv.FormatInt(p.opts.FrameExpiration.Milliseconds(), 10)
+	}
+
+	info, ok := FrameInfoFromContext(ctx)
+	if !ok {
+		return msg
+	}
+
+	msg.Timestamp = info.CaptureTime
+	headers["capture_timestamp"] = info.CaptureTime.UnixMilli()
+
+	encoding := info.ContentEncoding
+	if encoding == "" {
+		encoding = ContentEncodingIdentity
+	}
+	headers["content_encoding"] = encoding
+	if encoding != ContentEncodingIdentity {
+		msg.ContentEncoding = encoding
+	}
+
+	// Frames reenviados pelo spool não têm sequência nem hash
+	if info.Sequence > 0 {
+		msg.MessageId = info.MessageID()
+		headers["sequence"] = int64(info.Sequence)
+	}
+	if info.Hash != "" {
+		headers["frame_hash"] = info.Hash
+	}
+	if info.Width > 0 && info.Height > 0 {
+		headers["width"] = int32(info.Width)
+		headers["height"] = int32(info.Height)
+	}
+	return msg
+}
+
+// Flush aguarda as confirmações pendentes do broker até o prazo de ctx.
+// Sem publisher confirms não há o que aguardar.
+func (p *AMQPPublisher) Flush(ctx context.Context) error {
+	for _, slot := range p.pool {
+		if slot.confirms == nil {
+			continue
+		}
+		if err := slot.confirms.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *AMQPPublisher) Close() error {
 	p.mu.Lock()
 	p.closed = true
@@ -156,6 +626,9 @@ func (p *AMQPPublisher) Close() error {
 	p.notifyClose = nil
 	p.mu.Unlock()
 
+	p.closeTrackers()
+	p.closeSlots()
+
 	var err error
 	if ch != nil {
 		err = ch.Close()
@@ -168,8 +641,44 @@ func (p *AMQPPublisher) Close() error {
 	return err
 }
 
-// GetChannel returns the underlying AMQP channel.
-func (p *AMQPPublisher) GetChannel() *amqp.Channel {
+func (p *AMQPPublisher) closeTrackers() {
+	for _, slot := range p.pool {
+		if slot.confirms != nil {
+			slot.confirms.close()
+		}
+	}
+	if p.returns != nil {
+		p.returns.close()
+	}
+}
+
+func (p *AMQPPublisher) closeSlots() {
+	for _, slot := range p.pool {
+		slot.mu.Lock()
+		ch := slot.ch
+		slot.ch = nil
+		slot.mu.Unlock()
+		if ch != nil {
+			_ = ch.Close()
+		}
+	}
+}
+
+// PublishMessage publishes msg on the main channel of the current
+// connection, so callers follow the publisher across reconnects. It is meant
+// for messages other than frames (e.g. metadata), which do not go through
+// the channel pool and its confirms.
+func (p *AMQPPublisher) PublishMessage(exchange, routingKey string, msg amqp.Publishing) error {
+	ch := p.mainChannel()
+	if ch == nil {
+		return errors.New("amqp channel unavailable")
+	}
+	return ch.Publish(exchange, routingKey, false, false, msg)
+}
+
+func (p *AMQPPublisher) mainChannel() *amqp.Channel {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.channel
 }
 
@@ -194,16 +703,47 @@ func ExtractVhostFromURL(amqpURL string) (string, error) {
 	return vhost, nil
 }
 
-func (p *AMQPPublisher) getChannel() *amqp.Channel {
+// route retorna o destino dos frames da câmera.
+func (p *AMQPPublisher) route(cameraID string) AMQPRoute {
+	if route, ok := p.opts.Routes[cameraID]; ok {
+		return route
+	}
+	return AMQPRoute{Exchange: p.exchange, RoutingKey: p.routingKeyPrefix + cameraID}
+}
+
+// exchanges retorna os exchanges declarados a cada conexão: o padrão e os
+// das rotas por câmera.
+func (p *AMQPPublisher) exchanges() []string {
+	exchanges := []string{p.exchange}
+	seen := map[string]bool{p.exchange: true}
+	for _, route := range p.opts.Routes {
+		if !seen[route.Exchange] {
+			seen[route.Exchange] = true
+			exchanges = append(exchanges, route.Exchange)
+		}
+	}
+	sort.Strings(exchanges[1:])
+	return exchanges
+}
+
+func (p *AMQPPublisher) getConn() *amqp.Connection {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.channel
+	return p.conn
 }
 
-func (p *AMQPPublisher) reconnect() error {
+// reconnect troca a conexão failed por uma nova. Chamadas concorrentes
+// (vários canais do pool percebendo a mesma queda) reconectam uma vez só.
+func (p *AMQPPublisher) reconnect(failed *amqp.Connection) error {
+	p.reconnectMu.Lock()
+	defer p.reconnectMu.Unlock()
+
 	if p.isClosed() {
 		return errors.New("publisher closed")
 	}
+	if conn := p.getConn(); conn != nil && conn != failed && !conn.IsClosed() {
+		return nil
+	}
 
 	p.closeCurrent()
 
@@ -229,6 +769,7 @@ func (p *AMQPPublisher) closeCurrent() {
 	p.notifyClose = nil
 	p.mu.Unlock()
 
+	p.closeSlots()
 	if ch != nil {
 		_ = ch.Close()
 	}
@@ -237,7 +778,7 @@ func (p *AMQPPublisher) closeCurrent() {
 	}
 }
 
-func (p *AMQPPublisher) handleConnectionClose(notify <-chan *amqp.Error) {
+func (p *AMQPPublisher) handleConnectionClose(conn *amqp.Connection, notify <-chan *amqp.Error) {
 	err, ok := <-notify
 	if !ok || p.isClosed() {
 		return
@@ -249,7 +790,7 @@ func (p *AMQPPublisher) handleConnectionClose(notify <-chan *amqp.Error) {
 		log.Printf("Canal RabbitMQ fechado sem erro")
 	}
 
-	if recErr := p.reconnect(); recErr != nil {
+	if recErr := p.reconnect(conn); recErr != nil {
 		log.Printf("Falha ao reconectar ao RabbitMQ após fechamento do canal: %v", recErr)
 	}
 }
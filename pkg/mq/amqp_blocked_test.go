@@ -0,0 +1,117 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type blockedEvents struct {
+	mu     sync.Mutex
+	events []bool
+	reason string
+}
+
+func (e *blockedEvents) record(blocked bool, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, blocked)
+	if blocked {
+		e.reason = reason
+	}
+}
+
+func (e *blockedEvents) get() ([]bool, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]bool(nil), e.events...), e.reason
+}
+
+func TestAMQPPublisher_ConnectionBlocked(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Name: "test-blocked", Channels: 2})
+
+	var events blockedEvents
+	p.OnBlocked(events.record)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("a")))
+
+	broker.setBlocked(true, "low on memory")
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return blocked
+	}, 2*time.Second, 10*time.Millisecond)
+
+	err := p.Publish(ctx, "cam1", []byte("b"))
+	assert.ErrorIs(t, err, ErrBrokerBlocked)
+	assert.ErrorContains(t, err, "low on memory")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AMQPBrokerBlocked.WithLabelValues("test-blocked")))
+
+	broker.setBlocked(false, "")
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return !blocked
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("c")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.AMQPBrokerBlocked.WithLabelValues("test-blocked")))
+
+	got, reason := events.get()
+	assert.Equal(t, []bool{true, false}, got)
+	assert.Equal(t, "low on memory", reason)
+}
+
+func TestAMQPPublisher_ChannelFlow(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Channels: 2})
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("a")))
+	require.NoError(t, p.Publish(ctx, "cam2", []byte("a")))
+
+	// O canal 1 é o principal; cam1 ficou com o canal 2 do pool
+	broker.setFlow(2, false)
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return blocked
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.ErrorIs(t, p.Publish(ctx, "cam1", []byte("b")), ErrBrokerBlocked)
+	assert.NoError(t, p.Publish(ctx, "cam2", []byte("b")), "só o canal pausado recusa")
+
+	broker.setFlow(2, true)
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return !blocked
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, p.Publish(ctx, "cam1", []byte("c")))
+}
+
+func TestAMQPPublisher_BlockedClearsOnReconnect(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{})
+
+	broker.setBlocked(true, "low on disk")
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return blocked
+	}, 2*time.Second, 10*time.Millisecond)
+
+	var events blockedEvents
+	p.OnBlocked(events.record)
+	got, _ := events.get()
+	assert.Equal(t, []bool{true}, got, "OnBlocked informa o estado atual")
+
+	broker.dropConnections()
+	require.Eventually(t, func() bool {
+		blocked, _ := p.Blocked()
+		return !blocked
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,589 @@
+package mq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker é um broker AMQP 0-9-1 mínimo, suficiente para o cliente
+// streadway: handshake, canais, exchange.declare, queue.declare, queue.bind,
+// confirm.select, basic.publish com acks e basic.return, connection.blocked e
+// channel.flow. Declarações divergentes do que já existe fecham o canal com
+// PRECONDITION_FAILED. Cada canal processa as suas mensagens em ordem,
+// levando ackDelay por mensagem, como um processo de canal do RabbitMQ.
+type fakeBroker struct {
+	ln       net.Listener
+	ackDelay time.Duration
+
+	mu        sync.Mutex
+	conns     map[*fakeConn]bool
+	exchanges map[string]fakeExchange
+	queues    map[string]fakeQueue
+	bindings  []fakeBinding
+	noRoute   map[string]bool // routing keys sem fila
+	published []fakeMessage
+}
+
+type fakeExchange struct {
+	Kind    string
+	Durable bool
+}
+
+type fakeQueue struct {
+	Durable    bool
+	AutoDelete bool
+	Args       map[string]any
+}
+
+type fakeBinding struct {
+	Exchange   string
+	Queue      string
+	RoutingKey string
+	Args       map[string]any
+}
+
+type fakeMessage struct {
+	Channel    uint16
+	Exchange   string
+	RoutingKey string
+	Mandatory  bool
+	Body       []byte
+}
+
+func newFakeBroker(t testing.TB, ackDelay time.Duration) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	b := &fakeBroker{
+		ln:        ln,
+		ackDelay:  ackDelay,
+		conns:     make(map[*fakeConn]bool),
+		exchanges: make(map[string]fakeExchange),
+		queues:    make(map[string]fakeQueue),
+		noRoute:   make(map[string]bool),
+	}
+	go b.accept()
+	t.Cleanup(b.close)
+	return b
+}
+
+func (b *fakeBroker) URL() string {
+	return "amqp://guest:guest@" + b.ln.Addr().String() + "/test"
+}
+
+func (b *fakeBroker) accept() {
+	for {
+		nc, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &fakeConn{
+			broker:   b,
+			nc:       nc,
+			r:        bufio.NewReader(nc),
+			w:        bufio.NewWriter(nc),
+			channels: make(map[uint16]*fakeChannel),
+		}
+		b.mu.Lock()
+		b.conns[c] = true
+		b.mu.Unlock()
+		go c.serve()
+	}
+}
+
+func (b *fakeBroker) close() {
+	b.ln.Close()
+	b.dropConnections()
+}
+
+// dropConnections derruba todas as conexões sem connection.close.
+func (b *fakeBroker) dropConnections() {
+	b.mu.Lock()
+	conns := make([]*fakeConn, 0, len(b.conns))
+	for c := range b.conns {
+		conns = append(conns, c)
+	}
+	b.mu.Unlock()
+	for _, c := range conns {
+		c.nc.Close()
+	}
+}
+
+// closeChannel fecha o canal id de todas as conexões pelo lado do broker.
+func (b *fakeBroker) closeChannel(id uint16, code uint16, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		c.mu.Lock()
+		ch := c.channels[id]
+		c.mu.Unlock()
+		if ch != nil {
+			c.send(1, id, method(20, 40, u16(code), shortstr(text), u16(0), u16(0)))
+		}
+	}
+}
+
+// setBlocked envia connection.blocked (com reason) ou connection.unblocked
+// para todas as conexões.
+func (b *fakeBroker) setBlocked(blocked bool, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		if blocked {
+			c.send(1, 0, method(10, 60, shortstr(reason)))
+		} else {
+			c.send(1, 0, method(10, 61))
+		}
+	}
+}
+
+// setFlow envia channel.flow para o canal id de todas as conexões.
+func (b *fakeBroker) setFlow(id uint16, active bool) {
+	var flag byte
+	if active {
+		flag = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		if c.channel(id) != nil {
+			c.send(1, id, method(20, 20, []byte{flag}))
+		}
+	}
+}
+
+// setRoutable define se as mensagens com a routing key têm fila de destino.
+// Mensagens mandatory sem fila voltam com basic.return.
+func (b *fakeBroker) setRoutable(routingKey string, routable bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.noRoute[routingKey] = !routable
+}
+
+func (b *fakeBroker) Published() []fakeMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeMessage(nil), b.published...)
+}
+
+// Exchanges retorna o tipo de cada exchange declarado.
+func (b *fakeBroker) Exchanges() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]string, len(b.exchanges))
+	for k, v := range b.exchanges {
+		out[k] = v.Kind
+	}
+	return out
+}
+
+func (b *fakeBroker) Queues() map[string]fakeQueue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]fakeQueue, len(b.queues))
+	for k, v := range b.queues {
+		out[k] = v
+	}
+	return out
+}
+
+func (b *fakeBroker) Bindings() []fakeBinding {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeBinding(nil), b.bindings...)
+}
+
+// setExchange cria um exchange como se ele já existisse no broker.
+func (b *fakeBroker) setExchange(name, kind string, durable bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.exchanges[name] = fakeExchange{Kind: kind, Durable: durable}
+}
+
+// setQueue cria uma fila como se ela já existisse no broker.
+func (b *fakeBroker) setQueue(name string, q fakeQueue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queues[name] = q
+}
+
+type fakeConn struct {
+	broker *fakeBroker
+	nc     net.Conn
+	r      *bufio.Reader
+
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	mu       sync.Mutex
+	channels map[uint16]*fakeChannel
+}
+
+type fakeChannel struct {
+	id      uint16
+	confirm bool
+	nextTag uint64
+	msg     *fakeMessage // publicação aguardando header/corpo
+	header  []byte       // content header recebido, devolvido no basic.return
+	size    uint64
+	work    chan uint64 // delivery tags a confirmar
+}
+
+func (c *fakeConn) serve() {
+	defer func() {
+		c.nc.Close()
+		c.mu.Lock()
+		for _, ch := range c.channels {
+			close(ch.work)
+		}
+		c.channels = map[uint16]*fakeChannel{}
+		c.mu.Unlock()
+		c.broker.mu.Lock()
+		delete(c.broker.conns, c)
+		c.broker.mu.Unlock()
+	}()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return
+	}
+	c.send(1, 0, method(10, 10, []byte{0, 9}, emptyTable(), longstr("PLAIN"), longstr("en_US")))
+	if !c.expect(10, 11) { // start-ok
+		return
+	}
+	c.send(1, 0, method(10, 30, u16(2047), u32(131072), u16(0)))
+	if !c.expect(10, 31) { // tune-ok
+		return
+	}
+	if !c.expect(10, 40) { // open
+		return
+	}
+	c.send(1, 0, method(10, 41, shortstr("")))
+
+	for {
+		typ, chID, payload, err := readFrame(c.r)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case 1:
+			if !c.handleMethod(chID, payload) {
+				return
+			}
+		case 2:
+			c.handleHeader(chID, payload)
+		case 3:
+			c.handleBody(chID, payload)
+		}
+	}
+}
+
+func (c *fakeConn) expect(class, id uint16) bool {
+	typ, _, payload, err := readFrame(c.r)
+	if err != nil || typ != 1 || len(payload) < 4 {
+		return false
+	}
+	return binary.BigEndian.Uint16(payload) == class && binary.BigEndian.Uint16(payload[2:]) == id
+}
+
+func (c *fakeConn) handleMethod(chID uint16, payload []byte) bool {
+	r := &cursor{b: payload}
+	class, id := r.u16(), r.u16()
+
+	switch {
+	case class == 10 && id == 50: // connection.close
+		c.send(1, 0, method(10, 51))
+		return false
+
+	case class == 20 && id == 10: // channel.open
+		ch := &fakeChannel{id: chID, work: make(chan uint64, 4096)}
+		c.mu.Lock()
+		c.channels[chID] = ch
+		c.mu.Unlock()
+		go c.process(ch)
+		c.send(1, chID, method(20, 11, longstr("")))
+
+	case class == 20 && id == 40: // channel.close do cliente
+		c.removeChannel(chID)
+		c.send(1, chID, method(20, 41))
+
+	case class == 20 && id == 41: // channel.close-ok
+		c.removeChannel(chID)
+
+	case class == 40 && id == 10: // exchange.declare
+		r.u16()
+		name, kind := r.shortstr(), r.shortstr()
+		bits := r.u8()
+		r.table()
+		ex := fakeExchange{Kind: kind, Durable: bits&(1<<1) != 0}
+		c.broker.mu.Lock()
+		current, exists := c.broker.exchanges[name]
+		if !exists {
+			c.broker.exchanges[name] = ex
+		}
+		c.broker.mu.Unlock()
+		if exists && current != ex {
+			c.closeChannelWith(chID, 406, fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg for exchange '%s' in vhost 'test'", name), class, id)
+			break
+		}
+		if bits&(1<<4) == 0 {
+			c.send(1, chID, method(40, 11))
+		}
+
+	case class == 50 && id == 10: // queue.declare
+		r.u16()
+		name := r.shortstr()
+		bits := r.u8()
+		q := fakeQueue{Durable: bits&(1<<1) != 0, AutoDelete: bits&(1<<3) != 0, Args: r.table()}
+		c.broker.mu.Lock()
+		current, exists := c.broker.queues[name]
+		if !exists {
+			c.broker.queues[name] = q
+		}
+		c.broker.mu.Unlock()
+		if exists && !reflect.DeepEqual(current, q) {
+			c.closeChannelWith(chID, 406, fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg for queue '%s' in vhost 'test'", name), class, id)
+			break
+		}
+		if bits&(1<<4) == 0 {
+			c.send(1, chID, method(50, 11, shortstr(name), u32(0), u32(0)))
+		}
+
+	case class == 50 && id == 20: // queue.bind
+		r.u16()
+		b := fakeBinding{Queue: r.shortstr(), Exchange: r.shortstr(), RoutingKey: r.shortstr()}
+		noWait := r.u8()&1 != 0
+		b.Args = r.table()
+		c.broker.mu.Lock()
+		_, hasExchange := c.broker.exchanges[b.Exchange]
+		_, hasQueue := c.broker.queues[b.Queue]
+		duplicate := false
+		for _, existing := range c.broker.bindings {
+			duplicate = duplicate || reflect.DeepEqual(existing, b)
+		}
+		if hasExchange && hasQueue && !duplicate {
+			c.broker.bindings = append(c.broker.bindings, b)
+		}
+		c.broker.mu.Unlock()
+		if !hasExchange || !hasQueue {
+			c.closeChannelWith(chID, 404, fmt.Sprintf("NOT_FOUND - no exchange '%s' or queue '%s'", b.Exchange, b.Queue), class, id)
+			break
+		}
+		if !noWait {
+			c.send(1, chID, method(50, 21))
+		}
+
+	case class == 85 && id == 10: // confirm.select
+		noWait := r.u8()&1 != 0
+		if ch := c.channel(chID); ch != nil {
+			ch.confirm = true
+		}
+		if !noWait {
+			c.send(1, chID, method(85, 11))
+		}
+
+	case class == 60 && id == 40: // basic.publish
+		r.u16()
+		msg := &fakeMessage{Channel: chID, Exchange: r.shortstr(), RoutingKey: r.shortstr()}
+		msg.Mandatory = r.u8()&1 != 0
+		if ch := c.channel(chID); ch != nil {
+			ch.msg = msg
+		}
+	}
+	return true
+}
+
+func (c *fakeConn) handleHeader(chID uint16, payload []byte) {
+	ch := c.channel(chID)
+	if ch == nil || ch.msg == nil {
+		return
+	}
+	ch.header = payload
+	r := &cursor{b: payload}
+	r.u16()
+	r.u16()
+	ch.size = r.u64()
+	if ch.size == 0 {
+		c.complete(ch)
+	}
+}
+
+func (c *fakeConn) handleBody(chID uint16, payload []byte) {
+	ch := c.channel(chID)
+	if ch == nil || ch.msg == nil {
+		return
+	}
+	ch.msg.Body = append(ch.msg.Body, payload...)
+	if uint64(len(ch.msg.Body)) >= ch.size {
+		c.complete(ch)
+	}
+}
+
+func (c *fakeConn) complete(ch *fakeChannel) {
+	msg := *ch.msg
+	ch.msg = nil
+
+	c.broker.mu.Lock()
+	c.broker.published = append(c.broker.published, msg)
+	unroutable := c.broker.noRoute[msg.RoutingKey]
+	c.broker.mu.Unlock()
+
+	// Como no RabbitMQ, o basic.return chega antes do ack
+	if msg.Mandatory && unroutable {
+		c.send(1, ch.id, method(60, 50, u16(312), shortstr("NO_ROUTE"), shortstr(msg.Exchange), shortstr(msg.RoutingKey)))
+		c.send(2, ch.id, ch.header)
+		for body := msg.Body; len(body) > 0; {
+			n := min(len(body), 131072-8)
+			c.send(3, ch.id, body[:n])
+			body = body[n:]
+		}
+	}
+
+	if ch.confirm {
+		ch.nextTag++
+		ch.work <- ch.nextTag
+	}
+}
+
+// process confirma as mensagens do canal em ordem, uma a cada ackDelay.
+func (c *fakeConn) process(ch *fakeChannel) {
+	for tag := range ch.work {
+		if c.broker.ackDelay > 0 {
+			time.Sleep(c.broker.ackDelay)
+		}
+		c.send(1, ch.id, method(60, 80, u64(tag), []byte{0}))
+	}
+}
+
+// closeChannelWith fecha o canal pelo lado do broker, em resposta ao método
+// class.id. O canal é removido quando o cliente responder close-ok.
+func (c *fakeConn) closeChannelWith(id, code uint16, text string, classID, methodID uint16) {
+	c.send(1, id, method(20, 40, u16(code), shortstr(text), u16(classID), u16(methodID)))
+}
+
+func (c *fakeConn) channel(id uint16) *fakeChannel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[id]
+}
+
+func (c *fakeConn) removeChannel(id uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.channels[id]; ok {
+		close(ch.work)
+		delete(c.channels, id)
+	}
+}
+
+func (c *fakeConn) send(typ byte, ch uint16, payload []byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	var hdr [7]byte
+	hdr[0] = typ
+	binary.BigEndian.PutUint16(hdr[1:], ch)
+	binary.BigEndian.PutUint32(hdr[3:], uint32(len(payload)))
+	c.w.Write(hdr[:])
+	c.w.Write(payload)
+	c.w.WriteByte(0xCE)
+	c.w.Flush()
+}
+
+func readFrame(r *bufio.Reader) (typ byte, ch uint16, payload []byte, err error) {
+	var hdr [7]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	typ = hdr[0]
+	ch = binary.BigEndian.Uint16(hdr[1:])
+	payload = make([]byte, binary.BigEndian.Uint32(hdr[3:])+1)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if payload[len(payload)-1] != 0xCE {
+		err = errors.New("frame end inválido")
+		return
+	}
+	return typ, ch, payload[:len(payload)-1], nil
+}
+
+func method(class, id uint16, args ...[]byte) []byte {
+	out := append(u16(class), u16(id)...)
+	for _, a := range args {
+		out = append(out, a...)
+	}
+	return out
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+func shortstr(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+func longstr(s string) []byte  { return append(u32(uint32(len(s))), s...) }
+func emptyTable() []byte       { return u32(0) }
+
+type cursor struct{ b []byte }
+
+func (c *cursor) take(n int) []byte {
+	if n > len(c.b) {
+		panic(fmt.Sprintf("frame curto: %d > %d", n, len(c.b)))
+	}
+	out := c.b[:n]
+	c.b = c.b[n:]
+	return out
+}
+
+func (c *cursor) u8() byte    { return c.take(1)[0] }
+func (c *cursor) u16() uint16 { return binary.BigEndian.Uint16(c.take(2)) }
+func (c *cursor) u32() uint32 { return binary.BigEndian.Uint32(c.take(4)) }
+func (c *cursor) u64() uint64 { return binary.BigEndian.Uint64(c.take(8)) }
+func (c *cursor) shortstr() string {
+	return string(c.take(int(c.u8())))
+}
+
+// table lê uma field table com os tipos que o streadway escreve.
+func (c *cursor) table() map[string]any {
+	r := &cursor{b: c.take(int(c.u32()))}
+	out := make(map[string]any)
+	for len(r.b) > 0 {
+		key := r.shortstr()
+		out[key] = r.value()
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (c *cursor) value() any {
+	switch kind := c.u8(); kind {
+	case 't':
+		return c.u8() != 0
+	case 'b', 'B':
+		return int64(int8(c.u8()))
+	case 's', 'u':
+		return int64(int16(c.u16()))
+	case 'I', 'i':
+		return int64(int32(c.u32()))
+	case 'l':
+		return int64(c.u64())
+	case 'S':
+		return string(c.take(int(c.u32())))
+	case 'F':
+		return c.table()
+	case 'V':
+		return nil
+	default:
+		panic(fmt.Sprintf("tipo de campo não suportado: %q", kind))
+	}
+}
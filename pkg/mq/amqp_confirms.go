@@ -0,0 +1,247 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrPublishNacked indica que o broker rejeitou a mensagem (basic.nack).
+	ErrPublishNacked = errors.New("message nacked by broker")
+	// ErrConfirmTimeout indica que o broker não confirmou a mensagem no prazo.
+	ErrConfirmTimeout = errors.New("publisher confirm timed out")
+	// ErrConfirmLost indica que o canal caiu antes da confirmação chegar.
+	ErrConfirmLost = errors.New("channel closed before publisher confirm")
+
+	errStaleChannel = errors.New("amqp channel replaced during publish")
+)
+
+// confirmBufferSize é a capacidade do canal de confirmações. Precisa
+// acompanhar o volume de mensagens em trânsito para não travar o canal AMQP.
+const confirmBufferSize = 1024
+
+type pendingConfirm struct {
+	cameraID string
+	sent     time.Time
+	done     chan error
+}
+
+// confirmTracker associa as delivery tags de um canal em modo confirm às
+// mensagens publicadas e contabiliza acks, nacks e timeouts por câmera.
+// As tags recomeçam em 1 a cada canal novo (attach).
+type confirmTracker struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	nextTag uint64
+	pending map[uint64]*pendingConfirm
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newConfirmTracker(timeout time.Duration) *confirmTracker {
+	t := &confirmTracker{
+		timeout: timeout,
+		pending: make(map[uint64]*pendingConfirm),
+		stop:    make(chan struct{}),
+	}
+	go t.expireLoop()
+	return t
+}
+
+// attach passa a rastrear ch. As mensagens ainda pendentes do canal anterior
+// são dadas como perdidas.
+func (t *confirmTracker) attach(ch *amqp.Channel, confirms <-chan amqp.Confirmation) {
+	t.mu.Lock()
+	lost := t.pending
+	t.ch = ch
+	t.nextTag = 0
+	t.pending = make(map[uint64]*pendingConfirm)
+	t.mu.Unlock()
+
+	t.fail(lost, "lost", ErrConfirmLost)
+	go t.listen(ch, confirms)
+}
+
+// publish executa fn, que deve publicar uma única mensagem em ch, e registra
+// a delivery tag que o broker vai confirmar.
+func (t *confirmTracker) publish(ch *amqp.Channel, cameraID string, fn func() error) (*pendingConfirm, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ch != t.ch {
+		return nil, errStaleChannel
+	}
+	if err := fn(); err != nil {
+		// A biblioteca só consome a tag quando o envio é bem-sucedido
+		return nil, err
+	}
+
+	t.nextTag++
+	pc := &pendingConfirm{cameraID: cameraID, sent: time.Now(), done: make(chan error, 1)}
+	t.pending[t.nextTag] = pc
+	metrics.AMQPConfirmsPending.Inc()
+	return pc, nil
+}
+
+// wait aguarda a confirmação de pc até o timeout ou o fim de ctx.
+func (t *confirmTracker) wait(ctx context.Context, pc *pendingConfirm) error {
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-pc.done:
+		return err
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	if t.remove(pc) {
+		metrics.AMQPConfirms.WithLabelValues(pc.cameraID, "timeout").Inc()
+	}
+	// A confirmação pode ter chegado junto com o prazo
+	select {
+	case err := <-pc.done:
+		return err
+	default:
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrConfirmTimeout
+}
+
+// flush aguarda até não haver confirmações pendentes.
+func (t *confirmTracker) flush(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		n := t.outstanding()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d publisher confirms still pending: %w", n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *confirmTracker) outstanding() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+func (t *confirmTracker) close() {
+	t.once.Do(func() { close(t.stop) })
+}
+
+func (t *confirmTracker) listen(ch *amqp.Channel, confirms <-chan amqp.Confirmation) {
+	for confirm := range confirms {
+		t.resolve(ch, confirm)
+	}
+
+	// O canal foi fechado: o que não foi confirmado não será mais
+	t.mu.Lock()
+	if t.ch != ch {
+		t.mu.Unlock()
+		return
+	}
+	lost := t.pending
+	t.pending = make(map[uint64]*pendingConfirm)
+	t.mu.Unlock()
+
+	t.fail(lost, "lost", ErrConfirmLost)
+}
+
+func (t *confirmTracker) resolve(ch *amqp.Channel, confirm amqp.Confirmation) {
+	t.mu.Lock()
+	if t.ch != ch {
+		t.mu.Unlock()
+		return
+	}
+	pc, ok := t.pending[confirm.DeliveryTag]
+	delete(t.pending, confirm.DeliveryTag)
+	t.mu.Unlock()
+
+	if !ok {
+		// Já expirou por timeout
+		return
+	}
+	metrics.AMQPConfirmsPending.Dec()
+
+	if confirm.Ack {
+		metrics.AMQPConfirms.WithLabelValues(pc.cameraID, "ack").Inc()
+		pc.done <- nil
+		return
+	}
+	metrics.AMQPConfirms.WithLabelValues(pc.cameraID, "nack").Inc()
+	pc.done <- ErrPublishNacked
+}
+
+func (t *confirmTracker) remove(pc *pendingConfirm) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for tag, p := range t.pending {
+		if p == pc {
+			delete(t.pending, tag)
+			metrics.AMQPConfirmsPending.Dec()
+			return true
+		}
+	}
+	return false
+}
+
+// expireLoop dá como expiradas as mensagens que ninguém está aguardando
+// (modo assíncrono) e que passaram do prazo de confirmação.
+func (t *confirmTracker) expireLoop() {
+	interval := t.timeout / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+
+		expired := make(map[uint64]*pendingConfirm)
+		t.mu.Lock()
+		for tag, pc := range t.pending {
+			if time.Since(pc.sent) > t.timeout {
+				expired[tag] = pc
+				delete(t.pending, tag)
+			}
+		}
+		t.mu.Unlock()
+
+		t.fail(expired, "timeout", ErrConfirmTimeout)
+	}
+}
+
+// fail encerra mensagens já retiradas de pending. O gauge de pendentes é
+// somado entre os trackers (um por canal do pool), por isso é ajustado por
+// diferença e não pelo tamanho de pending.
+func (t *confirmTracker) fail(pending map[uint64]*pendingConfirm, result string, err error) {
+	for _, pc := range pending {
+		metrics.AMQPConfirms.WithLabelValues(pc.cameraID, result).Inc()
+		pc.done <- err
+	}
+	metrics.AMQPConfirmsPending.Sub(float64(len(pending)))
+}
@@ -0,0 +1,108 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConfirmTracker cria um tracker ligado a um canal falso; as
+// confirmações do broker são simuladas pelo canal retornado.
+func newTestConfirmTracker(t *testing.T, timeout time.Duration) (*confirmTracker, *amqp.Channel, chan amqp.Confirmation) {
+	t.Helper()
+	tracker := newConfirmTracker(timeout)
+	t.Cleanup(tracker.close)
+
+	ch := &amqp.Channel{}
+	confirms := make(chan amqp.Confirmation, 16)
+	tracker.attach(ch, confirms)
+	return tracker, ch, confirms
+}
+
+func publishTest(t *testing.T, tracker *confirmTracker, ch *amqp.Channel, cameraID string) *pendingConfirm {
+	t.Helper()
+	pc, err := tracker.publish(ch, cameraID, func() error { return nil })
+	require.NoError(t, err)
+	return pc
+}
+
+func TestConfirmTrackerAckAndNack(t *testing.T) {
+	tracker, ch, confirms := newTestConfirmTracker(t, time.Second)
+
+	first := publishTest(t, tracker, ch, "cam1")
+	second := publishTest(t, tracker, ch, "cam2")
+	assert.Equal(t, 2, tracker.outstanding())
+
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+	assert.NoError(t, tracker.wait(context.Background(), first))
+	assert.ErrorIs(t, tracker.wait(context.Background(), second), ErrPublishNacked)
+	assert.Zero(t, tracker.outstanding())
+}
+
+func TestConfirmTrackerFailedSendKeepsTags(t *testing.T) {
+	tracker, ch, confirms := newTestConfirmTracker(t, time.Second)
+
+	_, err := tracker.publish(ch, "cam1", func() error { return amqp.ErrClosed })
+	assert.ErrorIs(t, err, amqp.ErrClosed)
+
+	// O envio que falhou não consumiu a tag 1
+	pc := publishTest(t, tracker, ch, "cam1")
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	assert.NoError(t, tracker.wait(context.Background(), pc))
+}
+
+func TestConfirmTrackerTimeout(t *testing.T) {
+	tracker, ch, confirms := newTestConfirmTracker(t, 20*time.Millisecond)
+
+	pc := publishTest(t, tracker, ch, "cam1")
+	assert.ErrorIs(t, tracker.wait(context.Background(), pc), ErrConfirmTimeout)
+	assert.Zero(t, tracker.outstanding())
+
+	// Um ack atrasado é ignorado
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	next := publishTest(t, tracker, ch, "cam1")
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	assert.NoError(t, tracker.wait(context.Background(), next))
+}
+
+func TestConfirmTrackerChannelLoss(t *testing.T) {
+	tracker, ch, confirms := newTestConfirmTracker(t, time.Second)
+
+	pc := publishTest(t, tracker, ch, "cam1")
+	close(confirms)
+	assert.ErrorIs(t, tracker.wait(context.Background(), pc), ErrConfirmLost)
+
+	// Publicações no canal antigo são recusadas após a reconexão
+	tracker.attach(&amqp.Channel{}, make(chan amqp.Confirmation))
+	_, err := tracker.publish(ch, "cam1", func() error { return nil })
+	assert.ErrorIs(t, err, errStaleChannel)
+}
+
+func TestConfirmTrackerFlush(t *testing.T) {
+	tracker, ch, confirms := newTestConfirmTracker(t, time.Second)
+
+	publishTest(t, tracker, ch, "cam1")
+	publishTest(t, tracker, ch, "cam1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tracker.flush(ctx), context.DeadlineExceeded)
+
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	assert.NoError(t, tracker.flush(context.Background()))
+}
+
+func TestConfirmTrackerExpiresUnwaited(t *testing.T) {
+	tracker, ch, _ := newTestConfirmTracker(t, 20*time.Millisecond)
+
+	pc := publishTest(t, tracker, ch, "cam1")
+	require.Eventually(t, func() bool { return tracker.outstanding() == 0 }, time.Second, 5*time.Millisecond)
+	assert.ErrorIs(t, <-pc.done, ErrConfirmTimeout)
+}
@@ -0,0 +1,149 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAMQPPublisher(t testing.TB, url string, opts AMQPOptions) *AMQPPublisher {
+	t.Helper()
+	p, err := NewAMQPPublisherWithOptions(url, "cameras", "camera.", opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestAMQPPublisher_ChannelAffinity(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Confirms: true, WaitForConfirm: true, Channels: 4})
+
+	ctx := context.Background()
+	for round := 0; round < 3; round++ {
+		for cam := 0; cam < 8; cam++ {
+			require.NoError(t, p.Publish(ctx, fmt.Sprintf("cam%d", cam), []byte("frame")))
+		}
+	}
+
+	cameraChannel := map[string]uint16{}
+	camerasPerChannel := map[uint16]int{}
+	for _, msg := range broker.Published() {
+		ch, seen := cameraChannel[msg.RoutingKey]
+		if !seen {
+			cameraChannel[msg.RoutingKey] = msg.Channel
+			camerasPerChannel[msg.Channel]++
+			continue
+		}
+		assert.Equal(t, ch, msg.Channel, "%s trocou de canal", msg.RoutingKey)
+	}
+	assert.Len(t, cameraChannel, 8)
+	assert.Len(t, camerasPerChannel, 4)
+	for ch, n := range camerasPerChannel {
+		assert.Equal(t, 2, n, "câmeras no canal %d", ch)
+	}
+}
+
+func TestAMQPPublisher_ReopensClosedChannel(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Confirms: true, WaitForConfirm: true, Channels: 2})
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("a")))
+	require.NoError(t, p.Publish(ctx, "cam2", []byte("a")))
+
+	conn := p.getConn()
+	cam1 := p.slotFor("cam1")
+	cam2 := p.slotFor("cam2")
+	closed, untouched := cam1.channel(), cam2.channel()
+
+	// O canal 1 é o principal; os do pool vêm em seguida
+	broker.closeChannel(2, 406, "PRECONDITION_FAILED")
+
+	require.Eventually(t, func() bool {
+		ch := cam1.channel()
+		return ch != nil && ch != closed
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("b")))
+	require.NoError(t, p.Publish(ctx, "cam2", []byte("b")))
+	assert.Same(t, untouched, cam2.channel(), "os outros canais não são reabertos")
+	assert.Same(t, conn, p.getConn(), "fechar um canal não derruba a conexão")
+}
+
+func TestAMQPPublisher_ReconnectsPoolAfterConnectionLoss(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Confirms: true, WaitForConfirm: true, Channels: 3})
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("a")))
+	conn := p.getConn()
+
+	broker.dropConnections()
+	require.Eventually(t, func() bool {
+		current := p.getConn()
+		return current != nil && current != conn
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for cam := 0; cam < 3; cam++ {
+		require.NoError(t, p.Publish(ctx, fmt.Sprintf("cam%d", cam), []byte("b")))
+	}
+	for _, slot := range p.pool {
+		assert.NotNil(t, slot.channel())
+	}
+}
+
+// BenchmarkAMQPPublisher_Cameras mede a vazão de 24 câmeras publicando em
+// paralelo, aguardando cada confirmação, com pools de tamanhos diferentes.
+// Por padrão usa o broker de teste, que leva 100µs para confirmar cada
+// mensagem por canal; EDGE_VIDEO_AMQP_URL aponta para um RabbitMQ real.
+func BenchmarkAMQPPublisher_Cameras(b *testing.B) {
+	const cameras = 24
+	payload := make([]byte, 64*1024)
+
+	for _, channels := range []int{1, 4, 8, 24} {
+		b.Run(fmt.Sprintf("channels=%d", channels), func(b *testing.B) {
+			url := os.Getenv("EDGE_VIDEO_AMQP_URL")
+			if url == "" {
+				url = newFakeBroker(b, 100*time.Microsecond).URL()
+			}
+			p := newTestAMQPPublisher(b, url, AMQPOptions{Confirms: true, WaitForConfirm: true, Channels: channels})
+
+			ctx := context.Background()
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+
+			var wg sync.WaitGroup
+			errs := make(chan error, cameras)
+			for cam := 0; cam < cameras; cam++ {
+				n := b.N / cameras
+				if cam < b.N%cameras {
+					n++
+				}
+				wg.Add(1)
+				go func(cameraID string, n int) {
+					defer wg.Done()
+					for i := 0; i < n; i++ {
+						if err := p.Publish(ctx, cameraID, payload); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(fmt.Sprintf("cam%02d", cam), n)
+			}
+			wg.Wait()
+
+			b.StopTimer()
+			close(errs)
+			for err := range errs {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package mq
+
+import (
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/streadway/amqp"
+)
+
+// returnBufferSize é a capacidade do canal de basic.return de cada canal do
+// pool. A biblioteca entrega os retornos na goroutine de leitura da conexão.
+const returnBufferSize = 128
+
+// unroutableGrace é por quanto tempo um frame publicado depois do último
+// retorno precisa ficar sem voltar para a câmera ser considerada roteável
+// de novo.
+var unroutableGrace = 2 * time.Second
+
+// UnroutableEvent descreve uma câmera cujos frames voltam do broker sem fila
+// de destino (publicação mandatory), ou que voltou a ter rota.
+type UnroutableEvent struct {
+	CameraID   string
+	Unroutable bool      // false quando os frames voltaram a ser roteados
+	Since      time.Time // Primeiro retorno da sequência
+	Returns    int       // Frames devolvidos desde Since
+	Exchange   string
+	RoutingKey string
+	Reason     string // reply-text do broker (NO_ROUTE)
+}
+
+type unroutableState struct {
+	since time.Time
+	// Primeira publicação após o último retorno: se ela não voltar em
+	// unroutableGrace, a câmera tem rota
+	pendingSince time.Time
+	lastReturn   time.Time
+	returns      int
+	exchange     string
+	routingKey   string
+	reason       string
+	alerted      bool
+}
+
+// returnTracker contabiliza os frames devolvidos (basic.return) por câmera e
+// avisa quando os frames de uma câmera continuam voltando por mais de
+// alertAfter, e de novo quando eles voltam a ser roteados.
+type returnTracker struct {
+	alertAfter time.Duration
+	grace      time.Duration
+
+	mu      sync.Mutex
+	cameras map[string]*unroutableState
+	onEvent func(UnroutableEvent)
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newReturnTracker(alertAfter time.Duration) *returnTracker {
+	t := &returnTracker{
+		alertAfter: alertAfter,
+		grace:      unroutableGrace,
+		cameras:    make(map[string]*unroutableState),
+		stop:       make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+func (t *returnTracker) setHandler(fn func(UnroutableEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onEvent = fn
+}
+
+// published registra que o broker aceitou um frame da câmera enviado em
+// sentAt. Um retorno recebido depois de sentAt pode ser do próprio frame
+// (o basic.return chega antes do ack), então o frame não conta.
+func (t *returnTracker) published(cameraID string, sentAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s := t.cameras[cameraID]; s != nil && s.pendingSince.IsZero() && sentAt.After(s.lastReturn) {
+		s.pendingSince = sentAt
+	}
+}
+
+func (t *returnTracker) listen(returns <-chan amqp.Return) {
+	for r := range returns {
+		t.returned(r, time.Now())
+	}
+}
+
+func (t *returnTracker) returned(r amqp.Return, now time.Time) {
+	cameraID, _ := r.Headers["camera_id"].(string)
+	metrics.AMQPReturned.WithLabelValues(cameraID).Inc()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.cameras[cameraID]
+	if s == nil {
+		s = &unroutableState{since: now}
+		t.cameras[cameraID] = s
+	}
+	s.pendingSince = time.Time{}
+	s.lastReturn = now
+	s.returns++
+	s.exchange = r.Exchange
+	s.routingKey = r.RoutingKey
+	s.reason = r.ReplyText
+}
+
+// check avalia as câmeras com retornos e devolve os eventos a emitir.
+func (t *returnTracker) check(now time.Time) []UnroutableEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []UnroutableEvent
+	for cameraID, s := range t.cameras {
+		ev := UnroutableEvent{
+			CameraID:   cameraID,
+			Since:      s.since,
+			Returns:    s.returns,
+			Exchange:   s.exchange,
+			RoutingKey: s.routingKey,
+			Reason:     s.reason,
+		}
+
+		if !s.pendingSince.IsZero() && now.Sub(s.pendingSince) > t.grace {
+			delete(t.cameras, cameraID)
+			if s.alerted {
+				metrics.AMQPCameraUnroutable.WithLabelValues(cameraID).Set(0)
+				events = append(events, ev)
+			}
+			continue
+		}
+
+		if !s.alerted && now.Sub(s.since) >= t.alertAfter {
+			s.alerted = true
+			metrics.AMQPCameraUnroutable.WithLabelValues(cameraID).Set(1)
+			ev.Unroutable = true
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (t *returnTracker) loop() {
+	interval := t.alertAfter / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-ticker.C:
+			events := t.check(now)
+			t.mu.Lock()
+			fn := t.onEvent
+			t.mu.Unlock()
+			if fn == nil {
+				continue
+			}
+			for _, ev := range events {
+				fn(ev)
+			}
+		}
+	}
+}
+
+func (t *returnTracker) close() {
+	t.once.Do(func() { close(t.stop) })
+}
@@ -0,0 +1,147 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturnTrackerAlertAndRecovery(t *testing.T) {
+	tracker := &returnTracker{alertAfter: time.Minute, grace: unroutableGrace, cameras: make(map[string]*unroutableState)}
+	start := time.Now()
+	ret := amqp.Return{
+		Headers:    amqp.Table{"camera_id": "cam-ret"},
+		Exchange:   "cameras",
+		RoutingKey: "camera.cam-ret",
+		ReplyText:  "NO_ROUTE",
+	}
+
+	tracker.returned(ret, start)
+	tracker.returned(ret, start.Add(30*time.Second))
+	assert.Empty(t, tracker.check(start.Add(30*time.Second)), "ainda dentro do prazo")
+
+	events := tracker.check(start.Add(time.Minute))
+	require.Len(t, events, 1)
+	assert.True(t, events[0].Unroutable)
+	assert.Equal(t, "cam-ret", events[0].CameraID)
+	assert.Equal(t, 2, events[0].Returns)
+	assert.Equal(t, "camera.cam-ret", events[0].RoutingKey)
+	assert.Equal(t, start, events[0].Since)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AMQPCameraUnroutable.WithLabelValues("cam-ret")))
+	assert.Empty(t, tracker.check(start.Add(2*time.Minute)), "o alerta sai uma vez só")
+
+	// Um frame publicado depois do último retorno que não volta em
+	// unroutableGrace encerra o alerta
+	tracker.published("cam-ret", start.Add(time.Minute))
+	events = tracker.check(start.Add(time.Minute + unroutableGrace + time.Second))
+	require.Len(t, events, 1)
+	assert.False(t, events[0].Unroutable)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.AMQPCameraUnroutable.WithLabelValues("cam-ret")))
+	assert.Empty(t, tracker.cameras)
+}
+
+func TestReturnTrackerShortOutageDoesNotAlert(t *testing.T) {
+	tracker := &returnTracker{alertAfter: time.Minute, grace: unroutableGrace, cameras: make(map[string]*unroutableState)}
+	now := time.Now()
+	tracker.returned(amqp.Return{Headers: amqp.Table{"camera_id": "cam1"}}, now)
+	tracker.published("cam1", now.Add(time.Millisecond))
+
+	assert.Empty(t, tracker.check(now.Add(unroutableGrace+time.Second)))
+	assert.Empty(t, tracker.cameras)
+}
+
+func TestReturnTrackerIgnoresReturnedFrame(t *testing.T) {
+	tracker := &returnTracker{alertAfter: time.Minute, grace: unroutableGrace, cameras: make(map[string]*unroutableState)}
+	start := time.Now()
+	tracker.returned(amqp.Return{Headers: amqp.Table{"camera_id": "cam1"}}, start)
+
+	// O retorno do próprio frame chega antes da confirmação da publicação
+	sentAt := start.Add(time.Second)
+	tracker.returned(amqp.Return{Headers: amqp.Table{"camera_id": "cam1"}}, sentAt.Add(time.Millisecond))
+	tracker.published("cam1", sentAt)
+	tracker.check(sentAt.Add(unroutableGrace + time.Second))
+	assert.Contains(t, tracker.cameras, "cam1", "frame devolvido não encerra o alerta")
+}
+
+func TestAMQPPublisher_FailedPublishKeepsCameraUnroutable(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{Mandatory: true, UnroutableAlert: time.Minute})
+	p.returns.returned(amqp.Return{Headers: amqp.Table{"camera_id": "cam1"}}, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, p.Publish(ctx, "cam1", []byte("frame")))
+
+	p.returns.mu.Lock()
+	defer p.returns.mu.Unlock()
+	assert.True(t, p.returns.cameras["cam1"].pendingSince.IsZero(), "publicação que falhou não conta como roteada")
+}
+
+func TestAMQPPublisher_MandatoryReturns(t *testing.T) {
+	grace := unroutableGrace
+	unroutableGrace = 100 * time.Millisecond
+	t.Cleanup(func() { unroutableGrace = grace })
+
+	broker := newFakeBroker(t, 0)
+	broker.setRoutable("camera.cam-lost", false)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{
+		Confirms:        true,
+		WaitForConfirm:  true,
+		Mandatory:       true,
+		UnroutableAlert: 100 * time.Millisecond,
+	})
+
+	var mu sync.Mutex
+	var events []UnroutableEvent
+	p.OnUnroutable(func(ev UnroutableEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	})
+	eventCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			p.Publish(ctx, "cam-lost", []byte("frame"))
+			p.Publish(ctx, "cam-ok", []byte("frame"))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	require.Eventually(t, func() bool { return eventCount() == 1 }, 2*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.True(t, events[0].Unroutable)
+	assert.Equal(t, "cam-lost", events[0].CameraID)
+	assert.Equal(t, "NO_ROUTE", events[0].Reason)
+	mu.Unlock()
+
+	// Uma fila é ligada à routing key: o alerta se encerra
+	broker.setRoutable("camera.cam-lost", true)
+	require.Eventually(t, func() bool { return eventCount() == 2 }, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	assert.False(t, events[1].Unroutable)
+	mu.Unlock()
+	assert.Greater(t, testutil.ToFloat64(metrics.AMQPReturned.WithLabelValues("cam-lost")), 0.0)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.AMQPReturned.WithLabelValues("cam-ok")))
+	for _, msg := range broker.Published() {
+		assert.True(t, msg.Mandatory)
+	}
+}
@@ -0,0 +1,268 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrTopologyDrift indica que o broker já tem um exchange ou fila com o nome
+// configurado, mas com tipo, durabilidade ou argumentos diferentes.
+var ErrTopologyDrift = errors.New("amqp topology drift")
+
+// AMQPTopology é a topologia declarada a cada (re)conexão. A declaração é
+// idempotente: o que já existe igual no broker não muda.
+type AMQPTopology struct {
+	Exchanges []AMQPExchange
+	Queues    []AMQPQueue
+	Bindings  []AMQPBinding
+}
+
+// AMQPExchange descreve um exchange.
+type AMQPExchange struct {
+	Name       string
+	Type       string // topic, direct, fanout ou headers
+	Durable    bool
+	AutoDelete bool
+	Internal   bool
+}
+
+// AMQPQueue descreve uma fila e os seus limites.
+type AMQPQueue struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	// MessageTTL descarta (ou manda para o dead-letter) mensagens mais
+	// antigas que isso. Zero não limita.
+	MessageTTL time.Duration
+	// MaxLength e MaxLengthBytes limitam a fila; Overflow define o que
+	// acontece ao atingir o limite. Zero não limita.
+	MaxLength      int64
+	MaxLengthBytes int64
+	// Overflow: drop-head (padrão do broker: descarta as mais antigas),
+	// reject-publish ou reject-publish-dlx.
+	Overflow             string
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+}
+
+// AMQPBinding liga uma fila a um exchange. Em exchanges headers, Headers e
+// Match (all ou any) definem o filtro e RoutingKey é ignorada.
+type AMQPBinding struct {
+	Exchange   string
+	Queue      string
+	RoutingKey string
+	Headers    map[string]string
+	Match      string
+}
+
+// Validate verifica tipos e opções antes de qualquer declaração.
+func (t AMQPTopology) Validate() error {
+	exchanges := make(map[string]bool)
+	for _, ex := range t.Exchanges {
+		if ex.Name == "" {
+			return errors.New("amqp topology: exchange without a name")
+		}
+		if exchanges[ex.Name] {
+			return fmt.Errorf("amqp topology: exchange %q declared twice", ex.Name)
+		}
+		exchanges[ex.Name] = true
+		switch ex.Type {
+		case amqp.ExchangeTopic, amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeHeaders:
+		default:
+			return fmt.Errorf("amqp topology: exchange %q: invalid type %q", ex.Name, ex.Type)
+		}
+	}
+
+	queues := make(map[string]bool)
+	for _, q := range t.Queues {
+		if q.Name == "" {
+			return errors.New("amqp topology: queue without a name")
+		}
+		if queues[q.Name] {
+			return fmt.Errorf("amqp topology: queue %q declared twice", q.Name)
+		}
+		queues[q.Name] = true
+		switch q.Overflow {
+		case "", "drop-head", "reject-publish", "reject-publish-dlx":
+		default:
+			return fmt.Errorf("amqp topology: queue %q: invalid overflow %q", q.Name, q.Overflow)
+		}
+		if q.MessageTTL < 0 || q.MaxLength < 0 || q.MaxLengthBytes < 0 {
+			return fmt.Errorf("amqp topology: queue %q: negative limit", q.Name)
+		}
+	}
+
+	for _, b := range t.Bindings {
+		if b.Exchange == "" || b.Queue == "" {
+			return fmt.Errorf("amqp topology: binding %q -> %q needs exchange and queue", b.Exchange, b.Queue)
+		}
+		switch b.Match {
+		case "", "all", "any":
+		default:
+			return fmt.Errorf("amqp topology: binding %q -> %q: invalid match %q", b.Exchange, b.Queue, b.Match)
+		}
+	}
+	return nil
+}
+
+func (q AMQPQueue) arguments() amqp.Table {
+	args := amqp.Table{}
+	if q.MessageTTL > 0 {
+		args["x-message-ttl"] = q.MessageTTL.Milliseconds()
+	}
+	if q.MaxLength > 0 {
+		args["x-max-length"] = q.MaxLength
+	}
+	if q.MaxLengthBytes > 0 {
+		args["x-max-length-bytes"] = q.MaxLengthBytes
+	}
+	if q.Overflow != "" {
+		args["x-overflow"] = q.Overflow
+	}
+	if q.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = q.DeadLetterExchange
+	}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+func (b AMQPBinding) arguments() amqp.Table {
+	if len(b.Headers) == 0 {
+		return nil
+	}
+	args := amqp.Table{}
+	for key, value := range b.Headers {
+		args[key] = value
+	}
+	match := b.Match
+	if match == "" {
+		match = "all"
+	}
+	args["x-match"] = match
+	return args
+}
+
+// topology retorna a topologia completa: a configurada, os exchanges dos
+// frames que ela não lista (topic, durável) e os registrados com
+// DeclareExchange.
+func (p *AMQPPublisher) topology() AMQPTopology {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	topology := p.opts.Topology
+	topology.Exchanges = append([]AMQPExchange(nil), topology.Exchanges...)
+	declared := make(map[string]bool)
+	for _, ex := range topology.Exchanges {
+		declared[ex.Name] = true
+	}
+	for _, name := range p.exchanges() {
+		if !declared[name] {
+			declared[name] = true
+			topology.Exchanges = append(topology.Exchanges, AMQPExchange{Name: name, Type: amqp.ExchangeTopic, Durable: true})
+		}
+	}
+	for _, ex := range p.extraExchanges {
+		if !declared[ex.Name] {
+			declared[ex.Name] = true
+			topology.Exchanges = append(topology.Exchanges, ex)
+		}
+	}
+	return topology
+}
+
+// DeclareExchange declara ex agora e a cada reconexão. Um exchange com o
+// mesmo nome na topologia do publisher prevalece. Se o broker recusar a
+// declaração, ex não é registrado.
+func (p *AMQPPublisher) DeclareExchange(ex AMQPExchange) error {
+	if err := (AMQPTopology{Exchanges: []AMQPExchange{ex}}).Validate(); err != nil {
+		return err
+	}
+	for _, declared := range p.topology().Exchanges {
+		if declared.Name == ex.Name {
+			return nil
+		}
+	}
+
+	if conn := p.getConn(); conn != nil {
+		if err := declareTopology(conn, AMQPTopology{Exchanges: []AMQPExchange{ex}}); err != nil {
+			return err
+		}
+	}
+
+	// Sem conexão, a próxima conexão o declara
+	p.mu.Lock()
+	p.extraExchanges = append(p.extraExchanges, ex)
+	p.mu.Unlock()
+	return nil
+}
+
+// declareTopology declara topology em conn, num canal próprio: quando o
+// broker recusa uma declaração ele fecha o canal, e o canal principal não
+// pode cair por isso. Divergências (PRECONDITION_FAILED) são reunidas em um
+// erro ErrTopologyDrift; qualquer outra falha interrompe a declaração.
+func declareTopology(conn *amqp.Connection, topology AMQPTopology) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer func() {
+		if ch != nil {
+			ch.Close()
+		}
+	}()
+
+	var drift []error
+	declare := func(what string, fn func(ch *amqp.Channel) error) error {
+		err := fn(ch)
+		if err == nil {
+			return nil
+		}
+		var amqpErr *amqp.Error
+		if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+			return fmt.Errorf("failed to declare %s: %w", what, err)
+		}
+		drift = append(drift, fmt.Errorf("%w: %s: %s", ErrTopologyDrift, what, amqpErr.Reason))
+
+		// O broker fechou o canal: segue em um novo
+		if ch, err = conn.Channel(); err != nil {
+			ch = nil
+			return fmt.Errorf("failed to open a channel: %w", err)
+		}
+		return nil
+	}
+
+	for _, ex := range topology.Exchanges {
+		err := declare(fmt.Sprintf("exchange %q", ex.Name), func(ch *amqp.Channel) error {
+			return ch.ExchangeDeclare(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, ex.Internal, false, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, q := range topology.Queues {
+		err := declare(fmt.Sprintf("queue %q", q.Name), func(ch *amqp.Channel) error {
+			_, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, false, false, q.arguments())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, b := range topology.Bindings {
+		err := declare(fmt.Sprintf("binding %q -> %q (%q)", b.Exchange, b.Queue, b.RoutingKey), func(ch *amqp.Channel) error {
+			return ch.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, b.arguments())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return errors.Join(drift...)
+}
@@ -0,0 +1,166 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/internal/metadata"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTopology() AMQPTopology {
+	return AMQPTopology{
+		Exchanges: []AMQPExchange{
+			{Name: "cameras", Type: "direct", Durable: true},
+			{Name: "cameras.dlx", Type: "fanout", Durable: true},
+			{Name: "cameras.headers", Type: "headers", Durable: true},
+		},
+		Queues: []AMQPQueue{
+			{
+				Name:               "frames.cam1",
+				Durable:            true,
+				MessageTTL:         30 * time.Second,
+				MaxLength:          100,
+				Overflow:           "drop-head",
+				DeadLetterExchange: "cameras.dlx",
+			},
+			{Name: "frames.dead", Durable: true},
+		},
+		Bindings: []AMQPBinding{
+			{Exchange: "cameras", Queue: "frames.cam1", RoutingKey: "camera.cam1"},
+			{Exchange: "cameras.dlx", Queue: "frames.dead"},
+			{Exchange: "cameras.headers", Queue: "frames.cam1", Headers: map[string]string{"camera_id": "cam1"}},
+		},
+	}
+}
+
+func TestAMQPTopology_Validate(t *testing.T) {
+	assert.NoError(t, testTopology().Validate())
+	assert.NoError(t, AMQPTopology{}.Validate())
+
+	cases := map[string]func(*AMQPTopology){
+		"tipo de exchange":    func(top *AMQPTopology) { top.Exchanges[0].Type = "x-delayed" },
+		"exchange repetido":   func(top *AMQPTopology) { top.Exchanges[1].Name = "cameras" },
+		"overflow":            func(top *AMQPTopology) { top.Queues[0].Overflow = "drop-tail" },
+		"fila sem nome":       func(top *AMQPTopology) { top.Queues[1].Name = "" },
+		"ttl negativo":        func(top *AMQPTopology) { top.Queues[0].MessageTTL = -time.Second },
+		"binding sem fila":    func(top *AMQPTopology) { top.Bindings[0].Queue = "" },
+		"x-match inválido":    func(top *AMQPTopology) { top.Bindings[2].Match = "some" },
+		"exchange sem nome":   func(top *AMQPTopology) { top.Exchanges[2].Name = "" },
+		"max-length negativo": func(top *AMQPTopology) { top.Queues[0].MaxLength = -1 },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			top := testTopology()
+			mutate(&top)
+			assert.Error(t, top.Validate())
+		})
+	}
+}
+
+func TestAMQPPublisher_DeclaresTopology(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{
+		Topology: testTopology(),
+		Routes:   map[string]AMQPRoute{"cam2": {Exchange: "cameras.extra", RoutingKey: "cam2"}},
+	})
+
+	assert.Equal(t, map[string]string{
+		"cameras":         "direct",
+		"cameras.dlx":     "fanout",
+		"cameras.headers": "headers",
+		"cameras.extra":   "topic",
+	}, broker.Exchanges(), "exchanges de rotas fora da topologia continuam topic")
+
+	queues := broker.Queues()
+	require.Contains(t, queues, "frames.cam1")
+	assert.True(t, queues["frames.cam1"].Durable)
+	assert.Equal(t, map[string]any{
+		"x-message-ttl":          int64(30000),
+		"x-max-length":           int64(100),
+		"x-overflow":             "drop-head",
+		"x-dead-letter-exchange": "cameras.dlx",
+	}, queues["frames.cam1"].Args)
+	assert.Nil(t, queues["frames.dead"].Args)
+
+	bindings := broker.Bindings()
+	require.Len(t, bindings, 3)
+	assert.Equal(t, fakeBinding{Exchange: "cameras", Queue: "frames.cam1", RoutingKey: "camera.cam1"}, bindings[0])
+	assert.Equal(t, map[string]any{"camera_id": "cam1", "x-match": "all"}, bindings[2].Args)
+
+	// A reconexão declara tudo de novo sem divergência
+	broker.dropConnections()
+	require.Eventually(t, func() bool {
+		return p.Publish(context.Background(), "cam1", []byte("frame")) == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Len(t, broker.Bindings(), 3)
+}
+
+func TestAMQPPublisher_TopologyDrift(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	broker.setExchange("cameras", "topic", true)
+	broker.setQueue("frames.cam1", fakeQueue{Durable: true, Args: map[string]any{"x-message-ttl": int64(60000)}})
+
+	start := time.Now()
+	_, err := NewAMQPPublisherWithOptions(broker.URL(), "cameras", "camera.", AMQPOptions{Topology: testTopology()})
+	require.ErrorIs(t, err, ErrTopologyDrift)
+	assert.ErrorContains(t, err, `exchange "cameras"`)
+	assert.ErrorContains(t, err, `queue "frames.cam1"`)
+	assert.Less(t, time.Since(start), 5*time.Second, "divergência não é repetida")
+
+	// As demais declarações seguem em canais novos
+	assert.Equal(t, "fanout", broker.Exchanges()["cameras.dlx"])
+	assert.Len(t, broker.Bindings(), 3)
+}
+
+func TestAMQPPublisher_DeclareExchange(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	broker.setExchange("metadata", "fanout", true)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{})
+
+	require.NoError(t, p.DeclareExchange(AMQPExchange{Name: "events", Type: "topic", Durable: true}))
+	assert.Equal(t, "topic", broker.Exchanges()["events"])
+
+	err := p.DeclareExchange(AMQPExchange{Name: "metadata", Type: "topic", Durable: true})
+	assert.ErrorIs(t, err, ErrTopologyDrift)
+	assert.ErrorContains(t, err, "PRECONDITION_FAILED")
+
+	// O canal principal não cai com a recusa
+	assert.NoError(t, p.PublishMessage("events", "x", amqp.Publishing{Body: []byte("ok")}))
+	assert.NoError(t, p.Publish(context.Background(), "cam1", []byte("frame")))
+}
+
+func TestAMQPPublisher_MetadataFollowsReconnect(t *testing.T) {
+	broker := newFakeBroker(t, 0)
+	p := newTestAMQPPublisher(t, broker.URL(), AMQPOptions{})
+	require.NoError(t, p.DeclareExchange(AMQPExchange{Name: "metadata", Type: "topic", Durable: true}))
+
+	published := func() int {
+		count := 0
+		for _, msg := range broker.Published() {
+			if msg.Exchange == "metadata" {
+				count++
+			}
+		}
+		return count
+	}
+
+	meta := metadata.NewPublisher(p, "metadata", "meta", true)
+	require.NoError(t, meta.PublishMetadata("cam1", time.Now(), "", 0, 0, 5, "jpeg"))
+	require.Eventually(t, func() bool { return published() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Depois da reconexão os metadados vão para o canal novo, não para o
+	// canal fechado da conexão anterior
+	ch := p.mainChannel()
+	broker.dropConnections()
+	require.Eventually(t, func() bool {
+		current := p.mainChannel()
+		return current != nil && current != ch
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, meta.PublishMetadata("cam1", time.Now(), "", 0, 0, 5, "jpeg"))
+	require.Eventually(t, func() bool { return published() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,328 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/framepool"
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// SinkPolicy define como a falha de um destino afeta a publicação.
+type SinkPolicy string
+
+const (
+	// SinkRequired: Publish publica no destino na goroutine do chamador e
+	// retorna o seu erro (o que permite ao SpoolPublisher guardar a mensagem).
+	SinkRequired SinkPolicy = "required"
+	// SinkBestEffort: a mensagem é copiada para a fila do destino e Publish
+	// não espera. Com a fila cheia a mensagem é descartada.
+	SinkBestEffort SinkPolicy = "best_effort"
+)
+
+// ParseSinkPolicy converte o nome da configuração. Vazio equivale a required.
+func ParseSinkPolicy(name string) (SinkPolicy, error) {
+	switch SinkPolicy(name) {
+	case "", SinkRequired:
+		return SinkRequired, nil
+	case SinkBestEffort:
+		return SinkBestEffort, nil
+	default:
+		return "", fmt.Errorf("política de destino inválida: %q", name)
+	}
+}
+
+// Sink é um destino do CompositePublisher.
+type Sink struct {
+	Name      string
+	Publisher Publisher
+	Policy    SinkPolicy
+	QueueSize int // Capacidade da fila de um destino best-effort (zero = 100)
+}
+
+// SinkError é a falha de um destino em CompositePublisher.Publish. Com o
+// spool, só os destinos que falharam recebem a mensagem no reenvio.
+type SinkError struct {
+	Sink string
+	Err  error
+}
+
+func (e *SinkError) Error() string { return fmt.Sprintf("destino %s: %v", e.Sink, e.Err) }
+func (e *SinkError) Unwrap() error { return e.Err }
+
+// FailedSinks retorna os destinos com SinkError em err.
+func FailedSinks(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var sinks []string
+		for _, inner := range joined.Unwrap() {
+			sinks = append(sinks, FailedSinks(inner)...)
+		}
+		return sinks
+	}
+	var sinkErr *SinkError
+	if errors.As(err, &sinkErr) {
+		return []string{sinkErr.Sink}
+	}
+	return nil
+}
+
+type sinkMessage struct {
+	ctx      context.Context
+	cameraID string
+	frame    buffer.Frame // Referência do destino, liberada após publicar
+}
+
+type sinkQueue struct {
+	Sink
+	queue   chan sinkMessage // Só best-effort
+	pending atomic.Int64     // Mensagens na fila ou sendo publicadas
+	done    chan struct{}
+	failing atomic.Bool // Para logar só as mudanças de estado
+}
+
+// CompositePublisher publica cada mensagem em vários destinos. Os required são
+// publicados direto na goroutine do chamador, em paralelo entre os destinos,
+// então várias câmeras e workers publicam ao mesmo tempo em cada destino até
+// o limite do publisher dele. Os best-effort têm fila e goroutine próprias e
+// não são aguardados: um destino lento só atrasa a si mesmo.
+type CompositePublisher struct {
+	sinks []*sinkQueue
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewCompositePublisher cria o publisher e inicia a fila de cada destino.
+func NewCompositePublisher(sinks ...Sink) *CompositePublisher {
+	p := &CompositePublisher{}
+	for _, sink := range sinks {
+		if sink.Policy == "" {
+			sink.Policy = SinkRequired
+		}
+		if sink.QueueSize <= 0 {
+			sink.QueueSize = 100
+		}
+		q := &sinkQueue{
+			Sink: sink,
+			done: make(chan struct{}),
+		}
+		p.sinks = append(p.sinks, q)
+		if sink.Policy == SinkBestEffort {
+			q.queue = make(chan sinkMessage, sink.QueueSize)
+			go q.run()
+		} else {
+			close(q.done)
+		}
+	}
+	return p
+}
+
+// Publish entrega a mensagem a todos os destinos e retorna os erros dos
+// destinos required, um SinkError por destino.
+func (p *CompositePublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	return p.publish(ctx, nil, cameraID, payload)
+}
+
+// PublishTo entrega a mensagem apenas aos destinos em sinks. É usado pelo
+// spool para reenviar a mensagem só aos destinos em que ela falhou.
+func (p *CompositePublisher) PublishTo(ctx context.Context, sinks []string, cameraID string, payload []byte) error {
+	only := make(map[string]bool, len(sinks))
+	for _, name := range sinks {
+		only[name] = true
+	}
+	return p.publish(ctx, only, cameraID, payload)
+}
+
+// publish entrega a mensagem aos destinos em only (nil = todos).
+func (p *CompositePublisher) publish(ctx context.Context, only map[string]bool, cameraID string, payload []byte) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return errors.New("composite publisher closed")
+	}
+
+	var required, bestEffort []*sinkQueue
+	for _, q := range p.sinks {
+		if only != nil && !only[q.Name] {
+			continue
+		}
+		if q.Policy == SinkBestEffort {
+			bestEffort = append(bestEffort, q)
+		} else {
+			required = append(required, q)
+		}
+	}
+
+	if len(bestEffort) > 0 {
+		frame := sharedFrame(ctx, cameraID, payload)
+		for _, q := range bestEffort {
+			q.offer(ctx, cameraID, frame.Acquire())
+		}
+		frame.Release()
+	}
+
+	// O último destino usa a própria goroutine do chamador
+	results := make([]error, len(required))
+	var wg sync.WaitGroup
+	for i, q := range required {
+		if i == len(required)-1 {
+			results[i] = q.publishDirect(ctx, cameraID, payload)
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = q.publishDirect(ctx, cameraID, payload)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for i, err := range results {
+		if err != nil {
+			errs = append(errs, &SinkError{Sink: required[i].Name, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush aguarda as filas esvaziarem e repassa o Flush aos destinos.
+func (p *CompositePublisher) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	var errs []error
+	for _, q := range p.sinks {
+		for q.pending.Load() > 0 && ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+		if pending := q.pending.Load(); pending > 0 {
+			errs = append(errs, fmt.Errorf("destino %s: %d mensagens na fila: %w", q.Name, pending, ctx.Err()))
+			continue
+		}
+		if err := Flush(ctx, q.Publisher); err != nil {
+			errs = append(errs, fmt.Errorf("destino %s: %w", q.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close fecha as filas, aguarda os destinos processarem o que já estava
+// nelas e fecha os publishers dos destinos.
+func (p *CompositePublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, q := range p.sinks {
+		if q.queue != nil {
+			close(q.queue)
+		}
+	}
+
+	var errs []error
+	for _, q := range p.sinks {
+		<-q.done
+		if err := q.Publisher.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("destino %s: %w", q.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sharedFrame retorna uma referência ao frame do payload para os destinos
+// best-effort, que o publicam depois que Publish retorna. O frame do pipeline
+// (WithFrame) é compartilhado sem cópia; sem ele o payload, que pertence ao
+// chamador, é copiado uma vez para todos os destinos.
+func sharedFrame(ctx context.Context, cameraID string, payload []byte) buffer.Frame {
+	if frame, ok := FrameFromContext(ctx); ok && frame.Refcounted() && sameBytes(frame.Data, payload) {
+		return frame.Acquire()
+	}
+
+	buf := framepool.Get(len(payload))
+	copy(buf, payload)
+	return buffer.NewFrame(cameraID, buf, time.Now(), func() {
+		framepool.Put(buf)
+	})
+}
+
+// sameBytes indica se a e b são o mesmo slice.
+func sameBytes(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// offer coloca o frame na fila de um destino best-effort. A referência frame
+// passa a ser do destino, que a libera depois de publicar (ou ao descartar).
+func (q *sinkQueue) offer(ctx context.Context, cameraID string, frame buffer.Frame) {
+	msg := sinkMessage{
+		// Mantém os valores (FrameInfo) mas não o cancelamento do chamador
+		ctx:      context.WithoutCancel(ctx),
+		cameraID: cameraID,
+		frame:    frame,
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.queue <- msg:
+		q.updateDepth()
+	default:
+		q.pending.Add(-1)
+		frame.Release()
+		metrics.SinkPublishes.WithLabelValues(q.Name, "dropped").Inc()
+	}
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+
+	for msg := range q.queue {
+		q.updateDepth()
+		q.publish(msg.ctx, msg.cameraID, msg.frame.Data)
+		msg.frame.Release()
+		q.pending.Add(-1)
+	}
+}
+
+// publishDirect publica no destino required na goroutine do chamador.
+func (q *sinkQueue) publishDirect(ctx context.Context, cameraID string, payload []byte) error {
+	q.pending.Add(1)
+	defer q.pending.Add(-1)
+	return q.publish(ctx, cameraID, payload)
+}
+
+// publish publica no destino e registra as métricas e as mudanças de estado.
+func (q *sinkQueue) publish(ctx context.Context, cameraID string, payload []byte) error {
+	start := time.Now()
+	err := q.Publisher.Publish(ctx, cameraID, payload)
+	metrics.SinkPublishLatency.WithLabelValues(q.Name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metrics.SinkPublishes.WithLabelValues(q.Name, "error").Inc()
+		if !q.failing.Swap(true) {
+			log.Printf("Destino %s falhando (%s): %v", q.Name, q.Policy, err)
+		}
+	} else {
+		metrics.SinkPublishes.WithLabelValues(q.Name, "success").Inc()
+		if q.failing.Swap(false) {
+			log.Printf("Destino %s voltou a publicar", q.Name)
+		}
+	}
+	return err
+}
+
+func (q *sinkQueue) updateDepth() {
+	metrics.SinkQueueDepth.WithLabelValues(q.Name).Set(float64(len(q.queue)))
+}
@@ -0,0 +1,306 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher guarda uma cópia de cada payload recebido.
+type recordingPublisher struct {
+	delay time.Duration
+	err   error
+
+	mu       sync.Mutex
+	payloads []string
+	closed   atomic.Bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	p.payloads = append(p.payloads, string(payload))
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+func (p *recordingPublisher) received() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.payloads...)
+}
+
+func TestParseSinkPolicy(t *testing.T) {
+	policy, err := ParseSinkPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, SinkRequired, policy)
+
+	policy, err = ParseSinkPolicy("best_effort")
+	require.NoError(t, err)
+	assert.Equal(t, SinkBestEffort, policy)
+
+	_, err = ParseSinkPolicy("sometimes")
+	assert.Error(t, err)
+}
+
+func TestCompositeRequiredErrors(t *testing.T) {
+	primary := &recordingPublisher{}
+	failing := &recordingPublisher{err: errors.New("broker fora")}
+
+	p := NewCompositePublisher(
+		Sink{Name: "cloud", Publisher: primary},
+		Sink{Name: "local", Publisher: failing, Policy: SinkRequired},
+	)
+	defer p.Close()
+
+	err := p.Publish(context.Background(), "cam1", []byte("frame"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "destino local")
+	assert.Equal(t, []string{"frame"}, primary.received())
+}
+
+func TestCompositeBestEffortDoesNotBlock(t *testing.T) {
+	primary := &recordingPublisher{}
+	slow := &recordingPublisher{delay: 50 * time.Millisecond}
+	failing := &recordingPublisher{err: errors.New("broker fora")}
+
+	p := NewCompositePublisher(
+		Sink{Name: "cloud", Publisher: primary},
+		Sink{Name: "local", Publisher: slow, Policy: SinkBestEffort, QueueSize: 2},
+		Sink{Name: "debug", Publisher: failing, Policy: SinkBestEffort},
+	)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		payload := []byte{'a' + byte(i)}
+		assert.NoError(t, p.Publish(context.Background(), "cam1", payload))
+		// O chamador pode reutilizar o buffer assim que Publish retorna
+		payload[0] = 'x'
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Len(t, primary.received(), 10)
+
+	require.NoError(t, p.Close())
+
+	// Com fila de 2, o destino lento recebe só parte dos frames e sem corrupção
+	got := slow.received()
+	assert.NotEmpty(t, got)
+	assert.Less(t, len(got), 10)
+	for _, payload := range got {
+		assert.NotEqual(t, "x", payload)
+	}
+	assert.True(t, slow.closed.Load())
+	assert.True(t, failing.closed.Load())
+}
+
+// concurrencyPublisher registra quantas publicações estiveram em andamento
+// ao mesmo tempo.
+type concurrencyPublisher struct {
+	delay    time.Duration
+	inFlight atomic.Int32
+	max      atomic.Int32
+}
+
+func (p *concurrencyPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	current := p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	for {
+		max := p.max.Load()
+		if current <= max || p.max.CompareAndSwap(max, current) {
+			break
+		}
+	}
+	time.Sleep(p.delay)
+	return nil
+}
+
+func (p *concurrencyPublisher) Close() error { return nil }
+
+func TestCompositeRequiredPublishesConcurrently(t *testing.T) {
+	cloud := &concurrencyPublisher{delay: 20 * time.Millisecond}
+	backup := &concurrencyPublisher{delay: 20 * time.Millisecond}
+	p := NewCompositePublisher(
+		Sink{Name: "cloud", Publisher: cloud},
+		Sink{Name: "backup", Publisher: backup},
+	)
+	defer p.Close()
+
+	// Vários workers publicando: o destino recebe as publicações em paralelo,
+	// e não uma de cada vez
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, p.Publish(context.Background(), fmt.Sprintf("cam%d", i), []byte("frame")))
+		}()
+	}
+	wg.Wait()
+
+	assert.Greater(t, cloud.max.Load(), int32(1))
+	assert.Greater(t, backup.max.Load(), int32(1))
+}
+
+// pointerPublisher guarda o endereço de cada payload recebido.
+type pointerPublisher struct {
+	delay time.Duration
+
+	mu       sync.Mutex
+	pointers []*byte
+}
+
+func (p *pointerPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	p.pointers = append(p.pointers, &payload[0])
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *pointerPublisher) Close() error { return nil }
+
+func TestCompositeBestEffortSharesFrame(t *testing.T) {
+	local := &pointerPublisher{delay: 20 * time.Millisecond}
+	debug := &pointerPublisher{delay: 20 * time.Millisecond}
+	p := NewCompositePublisher(
+		Sink{Name: "local", Publisher: local, Policy: SinkBestEffort},
+		Sink{Name: "debug", Publisher: debug, Policy: SinkBestEffort},
+	)
+	defer p.Close()
+
+	var released atomic.Bool
+	data := []byte("frame")
+	frame := buffer.NewFrame("cam1", data, time.Now(), func() { released.Store(true) })
+
+	require.NoError(t, p.Publish(WithFrame(context.Background(), frame), "cam1", data))
+	assert.Equal(t, 3, frame.Refs(), "uma referência por destino best-effort")
+
+	// O job libera a sua referência; o frame só volta ao pool depois dos destinos
+	frame.Release()
+	assert.False(t, released.Load())
+	require.NoError(t, p.Flush(context.Background()))
+	assert.True(t, released.Load())
+
+	// Os destinos receberam o próprio buffer do frame, sem cópia
+	assert.Equal(t, []*byte{&data[0]}, local.pointers)
+	assert.Equal(t, []*byte{&data[0]}, debug.pointers)
+}
+
+func TestCompositeFlushDrainsQueues(t *testing.T) {
+	slow := &recordingPublisher{delay: 5 * time.Millisecond}
+	p := NewCompositePublisher(Sink{Name: "local", Publisher: slow, Policy: SinkBestEffort})
+	defer p.Close()
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, p.Publish(context.Background(), "cam1", []byte("frame")))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, p.Flush(ctx))
+	assert.Len(t, slow.received(), 5)
+}
+
+func TestCompositeClosed(t *testing.T) {
+	p := NewCompositePublisher(Sink{Name: "cloud", Publisher: &recordingPublisher{}})
+	require.NoError(t, p.Close())
+	assert.Error(t, p.Publish(context.Background(), "cam1", []byte("frame")))
+	assert.NoError(t, p.Close())
+}
+
+// togglePublisher falha enquanto down estiver ligado.
+type togglePublisher struct {
+	recordingPublisher
+	down atomic.Bool
+}
+
+func (p *togglePublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	if p.down.Load() {
+		return errors.New("destino fora")
+	}
+	return p.recordingPublisher.Publish(ctx, cameraID, payload)
+}
+
+func TestCompositeReportsFailedSinks(t *testing.T) {
+	a := &togglePublisher{}
+	b := &togglePublisher{}
+	c := &togglePublisher{}
+	b.down.Store(true)
+	c.down.Store(true)
+
+	p := NewCompositePublisher(
+		Sink{Name: "a", Publisher: a},
+		Sink{Name: "b", Publisher: b},
+		Sink{Name: "c", Publisher: c},
+	)
+	defer p.Close()
+
+	err := p.Publish(context.Background(), "cam1", []byte("frame"))
+	assert.ElementsMatch(t, []string{"b", "c"}, FailedSinks(err))
+	assert.ElementsMatch(t, []string{"b"}, FailedSinks(fmt.Errorf("spool: %w", &SinkError{Sink: "b", Err: err})))
+	assert.Nil(t, FailedSinks(errors.New("outro erro")))
+
+	c.down.Store(false)
+	err = p.PublishTo(context.Background(), []string{"b", "c"}, "cam1", []byte("retry"))
+	assert.Equal(t, []string{"b"}, FailedSinks(err))
+	assert.Equal(t, []string{"frame"}, a.received(), "PublishTo não envia aos outros destinos")
+	assert.Equal(t, []string{"retry"}, c.received())
+}
+
+func TestCompositeSpoolReplaysOnlyFailedSinks(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	cloud := &togglePublisher{}
+	local := &togglePublisher{}
+	backup := &togglePublisher{}
+	local.down.Store(true)
+	backup.down.Store(true)
+
+	p := NewSpoolPublisher(NewCompositePublisher(
+		Sink{Name: "cloud", Publisher: cloud},
+		Sink{Name: "local", Publisher: local},
+		Sink{Name: "backup", Publisher: backup},
+	), s, 0)
+	defer p.Close()
+
+	require.NoError(t, p.Publish(context.Background(), "cam1", []byte("frame-0")))
+	entries, _ := s.Stats()
+	require.Equal(t, 1, entries)
+
+	// Só local volta: o reenvio para local dá certo e backup continua pendente
+	local.down.Store(false)
+	p.signal()
+	require.Eventually(t, func() bool { return len(local.received()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	entries, _ = s.Stats()
+	assert.Equal(t, 1, entries)
+
+	backup.down.Store(false)
+	p.signal()
+	require.Eventually(t, func() bool {
+		entries, _ := s.Stats()
+		return entries == 0
+	}, 2*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, []string{"frame-0"}, cloud.received(), "cloud já tinha recebido: sem duplicata")
+	assert.Equal(t, []string{"frame-0"}, local.received(), "local recebe uma vez só")
+	assert.Equal(t, []string{"frame-0"}, backup.received())
+}
@@ -0,0 +1,127 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"image/jpeg"
+	"strconv"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+)
+
+// Valores de FrameInfo.ContentEncoding.
+const (
+	ContentEncodingIdentity = "identity"
+	ContentEncodingZstd     = "zstd"
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// FrameInfo descreve o frame sendo publicado. Ela viaja no contexto de
+// Publish (WithFrameInfo) para que wrappers como o SpoolPublisher não
+// precisem conhecê-la; publishers com suporte a cabeçalhos a usam para
+// preencher as propriedades da mensagem.
+type FrameInfo struct {
+	CameraID        string
+	Sequence        uint64 // Sequência do frame na câmera, reiniciada a cada execução
+	CaptureTime     time.Time
+	Width           int // Zero quando não foi possível ler o cabeçalho JPEG
+	Height          int
+	ContentEncoding string // ContentEncodingIdentity ou ContentEncodingZstd
+	Hash            string // CRC-32C do JPEG original, em hexadecimal
+	StorageKey      string // Chave do frame no Redis (vazia sem Redis); não vira cabeçalho
+}
+
+// NewFrameInfo monta a FrameInfo de um JPEG ainda não comprimido.
+func NewFrameInfo(cameraID string, sequence uint64, captureTime time.Time, jpegData []byte) FrameInfo {
+	info := FrameInfo{
+		CameraID:        cameraID,
+		Sequence:        sequence,
+		CaptureTime:     captureTime,
+		ContentEncoding: ContentEncodingIdentity,
+		Hash:            fmt.Sprintf("%08x", crc32.Checksum(jpegData, crc32c)),
+	}
+	// DecodeConfig lê só o cabeçalho, sem decodificar a imagem
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(jpegData)); err == nil {
+		info.Width = cfg.Width
+		info.Height = cfg.Height
+	}
+	return info
+}
+
+// MessageID identifica a mensagem de forma única entre execuções.
+func (i FrameInfo) MessageID() string {
+	return fmt.Sprintf("%s-%d-%d", i.CameraID, i.CaptureTime.UnixNano(), i.Sequence)
+}
+
+type frameInfoKey struct{}
+
+// WithFrameInfo anexa info ao contexto de publicação.
+func WithFrameInfo(ctx context.Context, info FrameInfo) context.Context {
+	return context.WithValue(ctx, frameInfoKey{}, info)
+}
+
+// FrameInfoFromContext retorna a FrameInfo anexada com WithFrameInfo.
+func FrameInfoFromContext(ctx context.Context) (FrameInfo, bool) {
+	info, ok := ctx.Value(frameInfoKey{}).(FrameInfo)
+	return info, ok
+}
+
+type frameKey struct{}
+
+// WithFrame anexa ao contexto de publicação o frame cujo Data é o payload.
+// Destinos que publicam depois de Publish retornar (best-effort) obtêm sua
+// própria referência com Acquire em vez de copiar o payload.
+func WithFrame(ctx context.Context, frame buffer.Frame) context.Context {
+	return context.WithValue(ctx, frameKey{}, frame)
+}
+
+// FrameFromContext retorna o frame anexado com WithFrame.
+func FrameFromContext(ctx context.Context) (buffer.Frame, bool) {
+	frame, ok := ctx.Value(frameKey{}).(buffer.Frame)
+	return frame, ok
+}
+
+type frameHeader struct {
+	key, value string
+}
+
+// frameHeaders espelha os cabeçalhos das mensagens AMQP para protocolos em
+// que os cabeçalhos são strings (Kafka, NATS).
+func frameHeaders(ctx context.Context, cameraID string) []frameHeader {
+	headers := []frameHeader{{"camera_id", cameraID}}
+
+	info, ok := FrameInfoFromContext(ctx)
+	if !ok {
+		return headers
+	}
+
+	encoding := info.ContentEncoding
+	if encoding == "" {
+		encoding = ContentEncodingIdentity
+	}
+	headers = append(headers,
+		frameHeader{"content_type", "image/jpeg"},
+		frameHeader{"content_encoding", encoding},
+		frameHeader{"capture_timestamp", strconv.FormatInt(info.CaptureTime.UnixMilli(), 10)},
+	)
+	if info.Sequence > 0 {
+		headers = append(headers,
+			frameHeader{"message_id", info.MessageID()},
+			frameHeader{"sequence", strconv.FormatUint(info.Sequence, 10)},
+		)
+	}
+	if info.Hash != "" {
+		headers = append(headers, frameHeader{"frame_hash", info.Hash})
+	}
+	if info.Width > 0 && info.Height > 0 {
+		headers = append(headers,
+			frameHeader{"width", strconv.Itoa(info.Width)},
+			frameHeader{"height", strconv.Itoa(info.Height)},
+		)
+	}
+	return headers
+}
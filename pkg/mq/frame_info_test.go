@@ -0,0 +1,80 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+func TestNewFrameInfo(t *testing.T) {
+	ts := time.UnixMilli(1700000000123)
+	info := NewFrameInfo("cam1", 42, ts, testJPEG(t, 64, 48))
+
+	assert.Equal(t, 64, info.Width)
+	assert.Equal(t, 48, info.Height)
+	assert.Equal(t, ContentEncodingIdentity, info.ContentEncoding)
+	assert.Len(t, info.Hash, 8)
+	assert.Equal(t, "cam1-1700000000123000000-42", info.MessageID())
+
+	// Dados que não são JPEG ficam sem dimensões
+	other := NewFrameInfo("cam1", 43, ts, []byte("não é jpeg"))
+	assert.Zero(t, other.Width)
+	assert.NotEqual(t, info.Hash, other.Hash)
+}
+
+func TestFrameInfoContext(t *testing.T) {
+	_, ok := FrameInfoFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := WithFrameInfo(context.Background(), FrameInfo{CameraID: "cam1", Sequence: 7})
+	info, ok := FrameInfoFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, uint64(7), info.Sequence)
+}
+
+func TestAMQPFramePublishingHeaders(t *testing.T) {
+	p := &AMQPPublisher{vhost: "loja1"}
+	payload := testJPEG(t, 32, 16)
+	ts := time.Now().Truncate(time.Millisecond)
+
+	msg := p.framePublishing(context.Background(), "cam1", payload)
+	assert.Equal(t, "image/jpeg", msg.ContentType)
+	assert.Equal(t, amqp.Table{"camera_id": "cam1", "vhost": "loja1"}, msg.Headers)
+	assert.Empty(t, msg.MessageId)
+	assert.Empty(t, msg.Expiration)
+
+	info := NewFrameInfo("cam1", 9, ts, payload)
+	msg = p.framePublishing(WithFrameInfo(context.Background(), info), "cam1", payload)
+	assert.Equal(t, info.MessageID(), msg.MessageId)
+	assert.True(t, ts.Equal(msg.Timestamp))
+	assert.Empty(t, msg.ContentEncoding)
+	assert.Equal(t, int64(9), msg.Headers["sequence"])
+	assert.Equal(t, ts.UnixMilli(), msg.Headers["capture_timestamp"])
+	assert.Equal(t, int32(32), msg.Headers["width"])
+	assert.Equal(t, int32(16), msg.Headers["height"])
+	assert.Equal(t, ContentEncodingIdentity, msg.Headers["content_encoding"])
+	assert.Equal(t, info.Hash, msg.Headers["frame_hash"])
+	assert.NoError(t, msg.Headers.Validate())
+
+	info.ContentEncoding = ContentEncodingZstd
+	msg = p.framePublishing(WithFrameInfo(context.Background(), info), "cam1", payload)
+	assert.Equal(t, ContentEncodingZstd, msg.ContentEncoding)
+
+	p.opts.FrameExpiration = 30 * time.Second
+	msg = p.framePublishing(context.Background(), "cam1", payload)
+	assert.Equal(t, "30000", msg.Expiration)
+}
@@ -0,0 +1,211 @@
+package mq
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// KafkaConfig configura o KafkaPublisher.
+type KafkaConfig struct {
+	Brokers []string
+	// Topics é o tópico de cada câmera (chave: ID), já resolvido a partir do
+	// template da configuração. As demais usam Topic.
+	Topics   map[string]string
+	Topic    string // Vazio usa "camera-frames"
+	ClientID string
+
+	Linger        time.Duration // Espera para formar lotes (zero = sem espera)
+	BatchMaxBytes int32         // Tamanho máximo de um lote (zero = padrão do cliente)
+	Compression   string        // none, gzip, snappy, lz4 ou zstd
+	Acks          string        // all (padrão), leader ou none
+	// DisableIdempotence desliga o produtor idempotente. Obrigatório com
+	// acks diferente de all.
+	DisableIdempotence bool
+	AutoCreateTopics   bool
+
+	TLS           *tls.Config // nil = sem TLS
+	SASLMechanism string      // PLAIN, SCRAM-SHA-256 ou SCRAM-SHA-512; vazio = sem SASL
+	SASLUsername  string
+	SASLPassword  string
+}
+
+// KafkaPublisher publica frames no Kafka. A chave de cada mensagem é o ID da
+// câmera, o que mantém os frames de uma câmera na mesma partição e em ordem.
+type KafkaPublisher struct {
+	client *kgo.Client
+	topics map[string]string
+	topic  string
+}
+
+// NewKafkaPublisher cria o cliente e verifica a conexão com os brokers.
+func NewKafkaPublisher(cfg KafkaConfig) (*KafkaPublisher, error) {
+	opts, err := kafkaOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("kafka client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("kafka ping: %w", err)
+	}
+
+	topic := cfg.Topic
+	if topic == "" {
+		topic = "camera-frames"
+	}
+
+	log.Printf("Conectado ao Kafka (%s)", strings.Join(cfg.Brokers, ","))
+	return &KafkaPublisher{client: client, topics: cfg.Topics, topic: topic}, nil
+}
+
+func kafkaOptions(cfg KafkaConfig) ([]kgo.Opt, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka: nenhum broker configurado")
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ProducerLinger(cfg.Linger),
+	}
+	if cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(cfg.ClientID))
+	}
+	if cfg.BatchMaxBytes > 0 {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(cfg.BatchMaxBytes))
+	}
+	if cfg.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+
+	codec, err := kafkaCompression(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, kgo.ProducerBatchCompression(codec))
+
+	switch cfg.Acks {
+	case "", "all":
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	default:
+		return nil, fmt.Errorf("kafka: acks inválido: %q", cfg.Acks)
+	}
+	if cfg.DisableIdempotence {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	} else if cfg.Acks != "" && cfg.Acks != "all" {
+		return nil, errors.New("kafka: o produtor idempotente exige acks = all")
+	}
+
+	if cfg.TLS != nil {
+		opts = append(opts, kgo.DialTLSConfig(cfg.TLS))
+	}
+
+	mechanism, err := kafkaSASL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+
+	return opts, nil
+}
+
+func kafkaCompression(name string) (kgo.CompressionCodec, error) {
+	switch name {
+	case "", "none":
+		return kgo.NoCompression(), nil
+	case "gzip":
+		return kgo.GzipCompression(), nil
+	case "snappy":
+		return kgo.SnappyCompression(), nil
+	case "lz4":
+		return kgo.Lz4Compression(), nil
+	case "zstd":
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, fmt.Errorf("kafka: compressão inválida: %q", name)
+	}
+}
+
+func kafkaSASL(cfg KafkaConfig) (sasl.Mechanism, error) {
+	switch strings.ToUpper(cfg.SASLMechanism) {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Auth{User: cfg.SASLUsername, Pass: cfg.SASLPassword}.AsMechanism(), nil
+	case "SCRAM-SHA-256":
+		return scram.Auth{User: cfg.SASLUsername, Pass: cfg.SASLPassword}.AsSha256Mechanism(), nil
+	case "SCRAM-SHA-512":
+		return scram.Auth{User: cfg.SASLUsername, Pass: cfg.SASLPassword}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("kafka: mecanismo SASL inválido: %q", cfg.SASLMechanism)
+	}
+}
+
+// Publish produz o frame e aguarda a confirmação do broker. Publicações
+// concorrentes de vários workers são agrupadas em lotes pelo cliente.
+func (p *KafkaPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	record := &kgo.Record{
+		Topic:   p.topicFor(cameraID),
+		Key:     []byte(cameraID),
+		Value:   payload,
+		Headers: kafkaHeaders(ctx, cameraID),
+	}
+	if info, ok := FrameInfoFromContext(ctx); ok {
+		record.Timestamp = info.CaptureTime
+	}
+
+	start := time.Now()
+	if err := p.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("falha ao publicar no Kafka: %w", err)
+	}
+	metrics.PublishLatency.WithLabelValues("kafka").Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// Flush aguarda os lotes pendentes.
+func (p *KafkaPublisher) Flush(ctx context.Context) error {
+	return p.client.Flush(ctx)
+}
+
+func (p *KafkaPublisher) Close() error {
+	p.client.Close()
+	return nil
+}
+
+func (p *KafkaPublisher) topicFor(cameraID string) string {
+	if topic, ok := p.topics[cameraID]; ok {
+		return topic
+	}
+	return p.topic
+}
+
+func kafkaHeaders(ctx context.Context, cameraID string) []kgo.RecordHeader {
+	var headers []kgo.RecordHeader
+	for _, h := range frameHeaders(ctx, cameraID) {
+		headers = append(headers, kgo.RecordHeader{Key: h.key, Value: []byte(h.value)})
+	}
+	return headers
+}
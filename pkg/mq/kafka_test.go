@@ -0,0 +1,136 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func newTestKafkaCluster(t *testing.T, opts ...kfake.Opt) *kfake.Cluster {
+	t.Helper()
+	cluster, err := kfake.NewCluster(append([]kfake.Opt{kfake.NumBrokers(1)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+// consumeKafka lê n registros do tópico desde o início.
+func consumeKafka(t *testing.T, brokers []string, topic string, n int, opts ...kgo.Opt) []*kgo.Record {
+	t.Helper()
+	client, err := kgo.NewClient(append([]kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	}, opts...)...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		require.NoError(t, ctx.Err(), "recebidos %d de %d registros", len(records), n)
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func recordHeader(record *kgo.Record, key string) string {
+	for _, h := range record.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestKafkaPublisherTopicAndKey(t *testing.T) {
+	cluster := newTestKafkaCluster(t, kfake.SeedTopics(4, "frames.cam1", "frames.cam2"))
+
+	p, err := NewKafkaPublisher(KafkaConfig{
+		Brokers:     cluster.ListenAddrs(),
+		Topics:      map[string]string{"cam1": "frames.cam1", "cam2": "frames.cam2"},
+		Linger:      5 * time.Millisecond,
+		Compression: "zstd",
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	ts := time.UnixMilli(1700000000000)
+	for i := 1; i <= 5; i++ {
+		info := FrameInfo{CameraID: "cam1", Sequence: uint64(i), CaptureTime: ts, ContentEncoding: ContentEncodingIdentity, Hash: "abcd", Width: 640, Height: 480}
+		ctx := WithFrameInfo(context.Background(), info)
+		require.NoError(t, p.Publish(ctx, "cam1", []byte(fmt.Sprintf("frame-%d", i))))
+	}
+	require.NoError(t, p.Publish(context.Background(), "cam2", []byte("other")))
+	require.NoError(t, p.Flush(context.Background()))
+
+	records := consumeKafka(t, cluster.ListenAddrs(), "frames.cam1", 5)
+	require.Len(t, records, 5)
+	for i, record := range records {
+		assert.Equal(t, "cam1", string(record.Key))
+		// Mesma chave: mesma partição e ordem preservada
+		assert.Equal(t, records[0].Partition, record.Partition)
+		assert.Equal(t, fmt.Sprintf("frame-%d", i+1), string(record.Value))
+		assert.Equal(t, fmt.Sprint(i+1), recordHeader(record, "sequence"))
+		assert.Equal(t, "640", recordHeader(record, "width"))
+		assert.Equal(t, "abcd", recordHeader(record, "frame_hash"))
+		assert.True(t, ts.Equal(record.Timestamp))
+	}
+
+	other := consumeKafka(t, cluster.ListenAddrs(), "frames.cam2", 1)
+	assert.Equal(t, "cam2", recordHeader(other[0], "camera_id"))
+	assert.Empty(t, recordHeader(other[0], "sequence"))
+}
+
+func TestKafkaPublisherSASL(t *testing.T) {
+	cluster := newTestKafkaCluster(t,
+		kfake.EnableSASL(),
+		kfake.Superuser("SCRAM-SHA-256", "edge", "segredo"),
+		kfake.SeedTopics(1, "camera-frames"),
+	)
+
+	_, err := NewKafkaPublisher(KafkaConfig{
+		Brokers:       cluster.ListenAddrs(),
+		SASLMechanism: "SCRAM-SHA-256",
+		SASLUsername:  "edge",
+		SASLPassword:  "errada",
+	})
+	assert.Error(t, err)
+
+	p, err := NewKafkaPublisher(KafkaConfig{
+		Brokers:       cluster.ListenAddrs(),
+		SASLMechanism: "SCRAM-SHA-256",
+		SASLUsername:  "edge",
+		SASLPassword:  "segredo",
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.Publish(context.Background(), "cam1", []byte("frame")))
+}
+
+func TestKafkaOptionsValidation(t *testing.T) {
+	_, err := kafkaOptions(KafkaConfig{})
+	assert.Error(t, err, "sem brokers")
+
+	_, err = kafkaOptions(KafkaConfig{Brokers: []string{"localhost:9092"}, Compression: "brotli"})
+	assert.Error(t, err)
+
+	_, err = kafkaOptions(KafkaConfig{Brokers: []string{"localhost:9092"}, Acks: "leader"})
+	assert.Error(t, err, "idempotência exige acks = all")
+
+	_, err = kafkaOptions(KafkaConfig{Brokers: []string{"localhost:9092"}, Acks: "leader", DisableIdempotence: true})
+	assert.NoError(t, err)
+
+	_, err = kafkaOptions(KafkaConfig{Brokers: []string{"localhost:9092"}, SASLMechanism: "GSSAPI"})
+	assert.Error(t, err)
+}
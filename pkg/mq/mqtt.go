@@ -2,44 +2,179 @@ package mq
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// ErrMQTTTimeout indica que o broker não confirmou a publicação no prazo.
+var ErrMQTTTimeout = errors.New("mqtt publish timeout")
+
+// MQTTOptions configura os recursos opcionais do MQTTPublisher.
+type MQTTOptions struct {
+	ClientID string // Vazio usa "edge-video-<hostname>"
+	Username string
+	Password string
+	TLS      *tls.Config // nil = sem TLS
+
+	QoS byte // 0, 1 ou 2
+	// PublishTimeout é o prazo para o broker confirmar uma publicação.
+	// Zero usa 5s.
+	PublishTimeout time.Duration
+
+	// RetainLatest publica cada frame também em <topic_prefix><camera>/latest
+	// com retain, para que novos assinantes recebam o último frame.
+	RetainLatest bool
+
+	// StatusTopic é a raiz dos tópicos de status (retidos):
+	//   <StatusTopic>/node           online/offline do nó (offline via Last Will)
+	//   <StatusTopic>/cameras/<id>   eventos de status de cada câmera
+	//   <StatusTopic>/system         eventos de status do sistema
+	// Vazio usa "edge-video/<client_id>".
+	StatusTopic string
+}
+
+// DefaultMQTTOptions retorna as opções usadas por NewMQTTPublisher.
+func DefaultMQTTOptions() MQTTOptions {
+	return MQTTOptions{QoS: 1}
+}
+
 type MQTTPublisher struct {
 	client      mqtt.Client
 	topicPrefix string
+	opts        MQTTOptions
+}
+
+// mqttNodeStatus é a mensagem do tópico <StatusTopic>/node.
+type mqttNodeStatus struct {
+	State    string `json:"state"`
+	ClientID string `json:"client_id"`
 }
 
 func NewMQTTPublisher(broker, topicPrefix string) (*MQTTPublisher, error) {
-	opts := mqtt.NewClientOptions().AddBroker(broker)
-	opts.SetAutoReconnect(true)
-	opts.SetConnectRetry(true)
+	return NewMQTTPublisherWithOptions(broker, topicPrefix, DefaultMQTTOptions())
+}
 
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
+// NewMQTTPublisherWithOptions cria um publisher com os recursos de opts.
+func NewMQTTPublisherWithOptions(broker, topicPrefix string, opts MQTTOptions) (*MQTTPublisher, error) {
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("mqtt: qos inválido: %d", opts.QoS)
+	}
+	if opts.PublishTimeout <= 0 {
+		opts.PublishTimeout = 5 * time.Second
+	}
+	if opts.ClientID == "" {
+		hostname, _ := os.Hostname()
+		opts.ClientID = "edge-video-" + hostname
+	}
+	if opts.StatusTopic == "" {
+		opts.StatusTopic = "edge-video/" + opts.ClientID
+	}
+
+	p := &MQTTPublisher{topicPrefix: topicPrefix, opts: opts}
+
+	clientOpts := mqtt.NewClientOptions().AddBroker(broker)
+	clientOpts.SetClientID(opts.ClientID)
+	clientOpts.SetAutoReconnect(true)
+	clientOpts.SetConnectRetry(true)
+	if opts.Username != "" {
+		clientOpts.SetUsername(opts.Username)
+		clientOpts.SetPassword(opts.Password)
+	}
+	if opts.TLS != nil {
+		clientOpts.SetTLSConfig(opts.TLS)
+	}
+
+	// O broker publica o Last Will se a conexão cair sem DISCONNECT; a cada
+	// (re)conexão o nó volta a se anunciar online
+	offline, _ := json.Marshal(mqttNodeStatus{State: "offline", ClientID: opts.ClientID})
+	clientOpts.SetBinaryWill(p.nodeTopic(), offline, 1, true)
+	clientOpts.SetOnConnectHandler(func(c mqtt.Client) {
+		online, _ := json.Marshal(mqttNodeStatus{State: "online", ClientID: opts.ClientID})
+		c.Publish(p.nodeTopic(), 1, true, online)
+	})
+	clientOpts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Printf("Conexão MQTT perdida: %v", err)
+	})
+
+	p.client = mqtt.NewClient(clientOpts)
+	if token := p.client.Connect(); token.Wait() && token.Error() != nil {
 		return nil, fmt.Errorf("mqtt connect: %w", token.Error())
 	}
-	log.Println("Conectado ao broker MQTT")
-	return &MQTTPublisher{client: client, topicPrefix: topicPrefix}, nil
+	log.Printf("Conectado ao broker MQTT (client_id=%s)", opts.ClientID)
+	return p, nil
 }
 
+// Publish publica o frame no tópico da câmera e, com RetainLatest, no tópico
+// do último frame. O cliente fala MQTT 3.1.1, que não tem user properties: a
+// FrameInfo do contexto não é enviada.
 func (p *MQTTPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
 	topic := p.topicPrefix + cameraID
-	token := p.client.Publish(topic, 1, false, payload)
-	sent := token.Wait()
-	if !sent {
-		return fmt.Errorf("o cliente MQTT não conseguiu enviar a mensagem")
+	if err := p.publish(ctx, topic, false, payload); err != nil {
+		return fmt.Errorf("falha ao publicar no MQTT: %w", err)
 	}
-	if token.Error() != nil {
-		return fmt.Errorf("falha ao publicar no MQTT: %w", token.Error())
+	if p.opts.RetainLatest {
+		if err := p.publish(ctx, topic+"/latest", true, payload); err != nil {
+			return fmt.Errorf("falha ao publicar o último frame no MQTT: %w", err)
+		}
 	}
 	return nil
 }
 
+// PublishCameraStatus publica um evento de status da câmera (JSON) no tópico
+// retido da câmera.
+func (p *MQTTPublisher) PublishCameraStatus(cameraID string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.PublishTimeout)
+	defer cancel()
+	return p.publish(ctx, p.opts.StatusTopic+"/cameras/"+cameraID, true, body)
+}
+
+// PublishSystemStatus publica um evento de status do sistema (JSON).
+func (p *MQTTPublisher) PublishSystemStatus(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.PublishTimeout)
+	defer cancel()
+	return p.publish(ctx, p.opts.StatusTopic+"/system", true, body)
+}
+
+// publish aguarda a confirmação do broker até o prazo de ctx ou
+// PublishTimeout, o que vencer primeiro.
+func (p *MQTTPublisher) publish(ctx context.Context, topic string, retained bool, payload []byte) error {
+	token := p.client.Publish(topic, p.opts.QoS, retained, payload)
+
+	timer := time.NewTimer(p.opts.PublishTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrMQTTTimeout
+	}
+}
+
+func (p *MQTTPublisher) nodeTopic() string {
+	return p.opts.StatusTopic + "/node"
+}
+
+// Close anuncia o nó como offline (o Last Will não é publicado em um
+// encerramento normal) e desconecta.
 func (p *MQTTPublisher) Close() error {
+	var err error
+	if p.client.IsConnectionOpen() {
+		offline, _ := json.Marshal(mqttNodeStatus{State: "offline", ClientID: p.opts.ClientID})
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.PublishTimeout)
+		err = p.publish(ctx, p.nodeTopic(), true, offline)
+		cancel()
+	}
+
 	p.client.Disconnect(250)
-	return nil
+	return err
 }
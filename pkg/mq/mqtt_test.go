@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startMQTTBroker(t *testing.T) string {
+	t.Helper()
+
+	srv := mochi.New(&mochi.Options{InlineClient: true})
+	require.NoError(t, srv.AddHook(new(auth.AllowHook), nil))
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, srv.AddListener(tcp))
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+
+	return "tcp://" + tcp.Address()
+}
+
+var mqttSubscribers atomic.Int64
+
+// subscribeMQTT conecta um assinante e retorna as mensagens de filter.
+func subscribeMQTT(t *testing.T, broker, filter string) <-chan mqtt.Message {
+	t.Helper()
+
+	msgs := make(chan mqtt.Message, 16)
+	clientID := fmt.Sprintf("sub-%d", mqttSubscribers.Add(1))
+	client := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID(clientID))
+	token := client.Connect()
+	require.True(t, token.WaitTimeout(5*time.Second))
+	require.NoError(t, token.Error())
+	t.Cleanup(func() { client.Disconnect(0) })
+
+	token = client.Subscribe(filter, 1, func(_ mqtt.Client, msg mqtt.Message) { msgs <- msg })
+	require.True(t, token.WaitTimeout(5*time.Second))
+	require.NoError(t, token.Error())
+	return msgs
+}
+
+func receiveMQTT(t *testing.T, msgs <-chan mqtt.Message) mqtt.Message {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("mensagem MQTT não recebida")
+		return nil
+	}
+}
+
+func TestMQTTPublisher_PublishAndRetainLatest(t *testing.T) {
+	broker := startMQTTBroker(t)
+
+	opts := DefaultMQTTOptions()
+	opts.ClientID = "edge-1"
+	opts.RetainLatest = true
+	p, err := NewMQTTPublisherWithOptions(broker, "camera/", opts)
+	require.NoError(t, err)
+	defer p.Close()
+
+	frames := subscribeMQTT(t, broker, "camera/cam1")
+	require.NoError(t, p.Publish(context.Background(), "cam1", []byte("frame-1")))
+	msg := receiveMQTT(t, frames)
+	assert.Equal(t, []byte("frame-1"), msg.Payload())
+	assert.False(t, msg.Retained())
+
+	// Um assinante novo recebe o último frame retido
+	latest := subscribeMQTT(t, broker, "camera/cam1/latest")
+	msg = receiveMQTT(t, latest)
+	assert.Equal(t, []byte("frame-1"), msg.Payload())
+	assert.True(t, msg.Retained())
+}
+
+func TestMQTTPublisher_PublishHonorsContext(t *testing.T) {
+	broker := startMQTTBroker(t)
+
+	p, err := NewMQTTPublisher(broker, "camera/")
+	require.NoError(t, err)
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = p.Publish(ctx, "cam1", []byte("frame"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMQTTPublisher_NodeStatusAndStatusEvents(t *testing.T) {
+	broker := startMQTTBroker(t)
+
+	opts := DefaultMQTTOptions()
+	opts.ClientID = "edge-1"
+	p, err := NewMQTTPublisherWithOptions(broker, "camera/", opts)
+	require.NoError(t, err)
+
+	node := subscribeMQTT(t, broker, "edge-video/edge-1/node")
+	var status mqttNodeStatus
+	require.NoError(t, json.Unmarshal(receiveMQTT(t, node).Payload(), &status))
+	assert.Equal(t, mqttNodeStatus{State: "online", ClientID: "edge-1"}, status)
+
+	cameras := subscribeMQTT(t, broker, "edge-video/edge-1/cameras/+")
+	require.NoError(t, p.PublishCameraStatus("cam1", []byte(`{"state":"inactive"}`)))
+	msg := receiveMQTT(t, cameras)
+	assert.Equal(t, "edge-video/edge-1/cameras/cam1", msg.Topic())
+	assert.JSONEq(t, `{"state":"inactive"}`, string(msg.Payload()))
+
+	require.NoError(t, p.Close())
+	require.NoError(t, json.Unmarshal(receiveMQTT(t, node).Payload(), &status))
+	assert.Equal(t, "offline", status.State)
+}
+
+func TestMQTTPublisher_InvalidQoS(t *testing.T) {
+	opts := DefaultMQTTOptions()
+	opts.QoS = 3
+	_, err := NewMQTTPublisherWithOptions("tcp://127.0.0.1:1", "camera/", opts)
+	assert.Error(t, err)
+}
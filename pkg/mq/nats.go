@@ -0,0 +1,259 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSConfig configura o NATSPublisher.
+type NATSConfig struct {
+	URL string
+	// Subjects é o subject de cada câmera (chave: ID), já resolvido a partir
+	// do template da configuração. As demais usam "camera.<id>.frames".
+	Subjects map[string]string
+	// StreamSubject é o subject do stream JetStream; deve cobrir os de
+	// Subjects. Vazio usa "camera.*.frames".
+	StreamSubject string
+	Name          string
+
+	Username string
+	Password string
+	Token    string
+	TLS      *tls.Config // nil = sem TLS
+
+	// JetStream publica no stream Stream e aguarda o ack do servidor. Sem
+	// ele a publicação é NATS core, sem confirmação.
+	JetStream bool
+	Stream    string        // Nome do stream (padrão "CAMERA_FRAMES")
+	MaxAge    time.Duration // Retenção do stream e do object store (zero = sem limite)
+	MaxBytes  int64         // Tamanho máximo do stream e do object store (zero = sem limite)
+	// ObjectBucket recebe os frames maiores que o max_payload do servidor;
+	// no subject vai só uma mensagem de referência. Padrão "camera-frames".
+	ObjectBucket string
+}
+
+// NATSPublisher publica frames no NATS, com ou sem JetStream.
+type NATSPublisher struct {
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	objects  jetstream.ObjectStore
+	subjects map[string]string
+	bucket   string
+}
+
+// NewNATSPublisher conecta ao servidor e, com JetStream, cria ou atualiza o
+// stream e o object store.
+func NewNATSPublisher(cfg NATSConfig) (*NATSPublisher, error) {
+	streamSubject := cfg.StreamSubject
+	if streamSubject == "" {
+		streamSubject = "camera.*.frames"
+	}
+
+	opts := []nats.Option{
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Conexão NATS perdida: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("Reconectado ao NATS (%s)", nc.ConnectedUrl())
+		}),
+	}
+	if cfg.Name != "" {
+		opts = append(opts, nats.Name(cfg.Name))
+	}
+	if cfg.Username != "" {
+		opts = append(opts, nats.UserInfo(cfg.Username, cfg.Password))
+	}
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+	if cfg.TLS != nil {
+		opts = append(opts, nats.Secure(cfg.TLS))
+	}
+
+	url := cfg.URL
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("nats connect: %w", err)
+	}
+
+	p := &NATSPublisher{conn: conn, subjects: cfg.Subjects}
+	if cfg.JetStream {
+		if err := p.setupJetStream(cfg, streamSubject); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	log.Printf("Conectado ao NATS (%s, jetstream=%v)", conn.ConnectedUrl(), cfg.JetStream)
+	return p, nil
+}
+
+func (p *NATSPublisher) setupJetStream(cfg NATSConfig, streamSubject string) error {
+	js, err := jetstream.New(p.conn)
+	if err != nil {
+		return fmt.Errorf("nats jetstream: %w", err)
+	}
+
+	stream := cfg.Stream
+	if stream == "" {
+		stream = "CAMERA_FRAMES"
+	}
+	p.bucket = cfg.ObjectBucket
+	if p.bucket == "" {
+		p.bucket = "camera-frames"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{streamSubject},
+		MaxAge:   cfg.MaxAge,
+		MaxBytes: natsLimit(cfg.MaxBytes),
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("nats stream %s: %w", stream, err)
+	}
+
+	objects, err := js.CreateOrUpdateObjectStore(ctx, jetstream.ObjectStoreConfig{
+		Bucket:   p.bucket,
+		TTL:      cfg.MaxAge,
+		MaxBytes: natsLimit(cfg.MaxBytes),
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("nats object store %s: %w", p.bucket, err)
+	}
+
+	p.js = js
+	p.objects = objects
+	return nil
+}
+
+// natsLimit converte "sem limite" da configuração (zero) para o valor do
+// servidor (-1).
+func natsLimit(n int64) int64 {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// natsSubjectReplacer remove do ID da câmera os caracteres com significado
+// em subjects.
+var natsSubjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_")
+
+func (p *NATSPublisher) subject(cameraID string) string {
+	if subject, ok := p.subjects[cameraID]; ok {
+		return subject
+	}
+	return "camera." + natsSubjectReplacer.Replace(cameraID) + ".frames"
+}
+
+// Publish publica o frame no subject da câmera. Com JetStream aguarda o ack
+// do servidor; frames maiores que o max_payload vão para o object store.
+func (p *NATSPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	msg := nats.NewMsg(p.subject(cameraID))
+	for _, h := range frameHeaders(ctx, cameraID) {
+		msg.Header.Set(h.key, h.value)
+	}
+	msg.Data = payload
+
+	start := time.Now()
+	var err error
+	if p.js == nil {
+		err = p.conn.PublishMsg(msg)
+	} else {
+		err = p.publishJetStream(ctx, cameraID, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao publicar no NATS: %w", err)
+	}
+	metrics.PublishLatency.WithLabelValues("nats").Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// natsObjectChunkSize é o tamanho padrão dos pedaços do object store.
+const natsObjectChunkSize = 128 * 1024
+
+func (p *NATSPublisher) publishJetStream(ctx context.Context, cameraID string, msg *nats.Msg) error {
+	var opts []jetstream.PublishOpt
+	if info, ok := FrameInfoFromContext(ctx); ok && info.Sequence > 0 {
+		// Deduplicação pelo servidor em caso de reenvio (spool)
+		opts = append(opts, jetstream.WithMsgID(info.MessageID()))
+	}
+
+	if int64(msg.Size()) <= p.conn.MaxPayload() {
+		_, err := p.js.PublishMsg(ctx, msg, opts...)
+		return err
+	}
+
+	name := msg.Header.Get("message_id")
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", cameraID, time.Now().UnixNano())
+	}
+	// Os pedaços do objeto também precisam caber no max_payload
+	chunkSize := min(p.conn.MaxPayload(), natsObjectChunkSize)
+	meta := jetstream.ObjectMeta{
+		Name: name,
+		Opts: &jetstream.ObjectMetaOptions{ChunkSize: uint32(chunkSize)},
+	}
+	if _, err := p.objects.Put(ctx, meta, bytes.NewReader(msg.Data)); err != nil {
+		return fmt.Errorf("object store: %w", err)
+	}
+	metrics.NATSObjectOffloads.WithLabelValues(cameraID).Inc()
+
+	// A mensagem de referência leva os mesmos cabeçalhos, sem o frame
+	msg.Header.Set("object_bucket", p.bucket)
+	msg.Header.Set("object_name", name)
+	msg.Header.Set("object_size", strconv.Itoa(len(msg.Data)))
+	msg.Data = nil
+	if _, err := p.js.PublishMsg(ctx, msg, opts...); err != nil {
+		if delErr := p.objects.Delete(context.WithoutCancel(ctx), name); delErr != nil {
+			log.Printf("Falha ao remover o objeto %s do NATS: %v", name, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Flush aguarda o servidor processar as mensagens já enviadas.
+func (p *NATSPublisher) Flush(ctx context.Context) error {
+	if p.conn.IsClosed() {
+		return errors.New("nats connection closed")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return p.conn.Flush()
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close envia as mensagens pendentes e fecha a conexão.
+func (p *NATSPublisher) Close() error {
+	if p.conn.IsClosed() {
+		return nil
+	}
+	err := p.conn.FlushTimeout(5 * time.Second)
+	p.conn.Close()
+	return err
+}
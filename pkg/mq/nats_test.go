@@ -0,0 +1,154 @@
+package mq
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startNATSServer(t *testing.T, maxPayload int32) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:       "127.0.0.1",
+		Port:       -1,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+		MaxPayload: maxPayload,
+		NoLog:      true,
+		NoSigs:     true,
+	})
+	require.NoError(t, err)
+
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(5*time.Second), "nats-server não iniciou")
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func TestNATSPublisher_CorePublish(t *testing.T) {
+	srv := startNATSServer(t, 0)
+
+	sub, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 1)
+	_, err = sub.ChanSubscribe("frames.>", msgs)
+	require.NoError(t, err)
+	require.NoError(t, sub.Flush())
+
+	p, err := NewNATSPublisher(NATSConfig{URL: srv.ClientURL(), Subjects: map[string]string{"cam.1": "frames.cam_1"}})
+	require.NoError(t, err)
+	defer p.Close()
+
+	info := FrameInfo{CameraID: "cam.1", Sequence: 3, CaptureTime: time.UnixMilli(1700000000000), Hash: "abcd"}
+	ctx := WithFrameInfo(context.Background(), info)
+	require.NoError(t, p.Publish(ctx, "cam.1", []byte("frame")))
+	require.NoError(t, p.Flush(context.Background()))
+
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, "frames.cam_1", msg.Subject)
+		assert.Equal(t, []byte("frame"), msg.Data)
+		assert.Equal(t, "cam.1", msg.Header.Get("camera_id"))
+		assert.Equal(t, info.MessageID(), msg.Header.Get("message_id"))
+		assert.Equal(t, "abcd", msg.Header.Get("frame_hash"))
+		assert.Equal(t, "1700000000000", msg.Header.Get("capture_timestamp"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("mensagem não recebida")
+	}
+}
+
+func TestNATSPublisher_CoreRejectsOversized(t *testing.T) {
+	srv := startNATSServer(t, 1024)
+
+	p, err := NewNATSPublisher(NATSConfig{URL: srv.ClientURL()})
+	require.NoError(t, err)
+	defer p.Close()
+
+	err = p.Publish(context.Background(), "cam1", make([]byte, 4096))
+	assert.ErrorIs(t, err, nats.ErrMaxPayload)
+}
+
+func TestNATSPublisher_JetStream(t *testing.T) {
+	srv := startNATSServer(t, 0)
+
+	p, err := NewNATSPublisher(NATSConfig{
+		URL:       srv.ClientURL(),
+		JetStream: true,
+		Stream:    "FRAMES",
+		MaxAge:    time.Hour,
+		MaxBytes:  1 << 20,
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	ctx := WithFrameInfo(context.Background(), FrameInfo{CameraID: "cam1", Sequence: 1, CaptureTime: time.Now()})
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("frame")))
+	// Reenvio do mesmo frame é descartado pela deduplicação
+	require.NoError(t, p.Publish(ctx, "cam1", []byte("frame")))
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	stream, err := js.Stream(context.Background(), "FRAMES")
+	require.NoError(t, err)
+	streamInfo, err := stream.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"camera.*.frames"}, streamInfo.Config.Subjects)
+	assert.Equal(t, time.Hour, streamInfo.Config.MaxAge)
+	assert.Equal(t, int64(1<<20), streamInfo.Config.MaxBytes)
+	assert.Equal(t, uint64(1), streamInfo.State.Msgs)
+
+	msg, err := stream.GetLastMsgForSubject(context.Background(), "camera.cam1.frames")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("frame"), msg.Data)
+}
+
+func TestNATSPublisher_JetStreamObjectStore(t *testing.T) {
+	srv := startNATSServer(t, 1024)
+
+	p, err := NewNATSPublisher(NATSConfig{URL: srv.ClientURL(), JetStream: true, ObjectBucket: "big-frames"})
+	require.NoError(t, err)
+	defer p.Close()
+
+	payload := make([]byte, 8192)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	info := FrameInfo{CameraID: "cam1", Sequence: 7, CaptureTime: time.Now()}
+	require.NoError(t, p.Publish(WithFrameInfo(context.Background(), info), "cam1", payload))
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	stream, err := js.Stream(context.Background(), "CAMERA_FRAMES")
+	require.NoError(t, err)
+	ref, err := stream.GetLastMsgForSubject(context.Background(), "camera.cam1.frames")
+	require.NoError(t, err)
+	assert.Empty(t, ref.Data)
+	assert.Equal(t, "big-frames", ref.Header.Get("object_bucket"))
+	assert.Equal(t, info.MessageID(), ref.Header.Get("object_name"))
+	assert.Equal(t, "8192", ref.Header.Get("object_size"))
+
+	objects, err := js.ObjectStore(context.Background(), "big-frames")
+	require.NoError(t, err)
+	obj, err := objects.Get(context.Background(), ref.Header.Get("object_name"))
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+}
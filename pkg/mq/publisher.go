@@ -6,3 +6,17 @@ type Publisher interface {
 	Publish(ctx context.Context, cameraID string, payload []byte) error
 	Close() error
 }
+
+// Flusher é implementado por publishers que entregam mensagens de forma
+// assíncrona. Flush aguarda as mensagens pendentes até o prazo de ctx.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush aguarda as mensagens pendentes de p, se ele implementar Flusher.
+func Flush(ctx context.Context, p Publisher) error {
+	if f, ok := p.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
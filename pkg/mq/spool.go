@@ -0,0 +1,680 @@
+package mq
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// SpoolMode define o que é guardado no spool para cada publicação que falhou.
+type SpoolMode string
+
+const (
+	// SpoolModeFrames guarda o frame completo para reenvio.
+	SpoolModeFrames SpoolMode = "frames"
+	// SpoolModeMetadata guarda apenas câmera, horário e tamanho do frame,
+	// ocupando poucos bytes por mensagem.
+	SpoolModeMetadata SpoolMode = "metadata"
+)
+
+const (
+	spoolSegmentExt    = ".seg"
+	spoolIndexFile     = "index.json"
+	spoolHeaderSize    = 8
+	spoolFixedBodySize = 8 + 4 + 1 + 2
+	spoolMaxRecordSize = 64 << 20
+	spoolFlagPayload   = 1
+	spoolFlagZstd      = 2
+	spoolFlagSinks     = 4
+	spoolFlagFrameMeta = 8
+)
+
+var errSpoolCorrupt = errors.New("registro do spool corrompido")
+
+// SpoolConfig configura o spool em disco.
+type SpoolConfig struct {
+	Dir          string
+	SegmentBytes int64         // Tamanho a partir do qual um novo segmento é criado
+	MaxBytes     int64         // Limite total; os segmentos mais antigos são descartados (zero = sem limite)
+	MaxAge       time.Duration // Mensagens mais antigas não são reenviadas (zero = sem limite)
+	Mode         SpoolMode
+}
+
+// SpoolEntry é uma mensagem guardada no spool. Payload é nil no modo metadata.
+type SpoolEntry struct {
+	CameraID        string
+	Timestamp       time.Time
+	Size            int
+	Payload         []byte
+	ContentEncoding string // ContentEncodingZstd se Payload está comprimido
+	// Sinks são os destinos do CompositePublisher em que a publicação
+	// falhou; vazio reenvia para todos
+	Sinks []string
+	// Dados do frame usados para reenviar os metadados no modo metadata
+	RedisKey      string
+	Width, Height int
+}
+
+type spoolSegment struct {
+	seq     uint64
+	size    int64
+	entries int
+}
+
+// spoolCursor é a posição da próxima mensagem a reenviar, persistida no índice.
+type spoolCursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// Spool é uma fila FIFO em disco para mensagens que não puderam ser publicadas.
+// As mensagens são gravadas em segmentos append-only, cada registro com CRC, e
+// o índice guarda a posição de leitura; após um crash os registros
+// incompletos no fim de um segmento são descartados e o reenvio continua de
+// onde parou.
+type Spool struct {
+	cfg SpoolConfig
+
+	mu        sync.Mutex
+	segments  []*spoolSegment // segments[0] é sempre o segmento do cursor
+	writer    *os.File        // Último segmento, aberto para append
+	reader    *os.File        // Segmento do cursor
+	cursor    spoolCursor
+	readCount int   // Registros já consumidos no segmento do cursor
+	peekLen   int64 // Tamanho do registro retornado pelo último Peek
+	entries   int
+	bytes     int64
+	closed    bool
+}
+
+// OpenSpool abre (ou cria) o spool no diretório configurado e recupera as
+// mensagens pendentes de uma execução anterior.
+func OpenSpool(cfg SpoolConfig) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("diretório do spool não configurado")
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = SpoolModeFrames
+	}
+	if cfg.Mode != SpoolModeFrames && cfg.Mode != SpoolModeMetadata {
+		return nil, fmt.Errorf("modo de spool inválido: %q", cfg.Mode)
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 16 << 20
+	}
+	// Garante ao menos quatro segmentos dentro do limite, para que o descarte
+	// dos mais antigos libere espaço de forma gradual
+	if cfg.MaxBytes > 0 && cfg.SegmentBytes > cfg.MaxBytes/4 {
+		cfg.SegmentBytes = max(cfg.MaxBytes/4, 1)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("falha ao criar diretório do spool: %w", err)
+	}
+
+	s := &Spool{cfg: cfg}
+	if err := s.recover(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if s.entries > 0 {
+		log.Printf("Spool recuperado: %d mensagens pendentes (%d bytes) em %s", s.entries, s.bytes, cfg.Dir)
+	}
+	s.updateMetrics()
+	return s, nil
+}
+
+func (s *Spool) recover() error {
+	if data, err := os.ReadFile(filepath.Join(s.cfg.Dir, spoolIndexFile)); err == nil {
+		if err := json.Unmarshal(data, &s.cursor); err != nil {
+			log.Printf("Índice do spool inválido, reenviando desde o início: %v", err)
+			s.cursor = spoolCursor{}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("falha ao ler índice do spool: %w", err)
+	}
+
+	seqs, err := s.listSegments()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range seqs {
+		path := s.segmentPath(seq)
+		if seq < s.cursor.Segment {
+			// Segmento já reenviado por completo
+			_ = os.Remove(path)
+			continue
+		}
+
+		seg, consumed, err := scanSpoolSegment(path, seq, s.cursor)
+		if err != nil {
+			return err
+		}
+		if len(s.segments) == 0 && seq == s.cursor.Segment {
+			s.readCount = consumed
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	if len(s.segments) == 0 {
+		return s.startSegment(s.cursor.Segment + 1)
+	}
+
+	head := s.segments[0]
+	if head.seq != s.cursor.Segment {
+		s.cursor = spoolCursor{Segment: head.seq}
+		s.readCount = 0
+	}
+	if s.cursor.Offset > head.size {
+		s.cursor.Offset = head.size
+		s.readCount = head.entries
+	}
+
+	for i, seg := range s.segments {
+		pending, pendingBytes := seg.entries, seg.size
+		if i == 0 {
+			pending -= s.readCount
+			pendingBytes -= s.cursor.Offset
+		}
+		s.entries += pending
+		s.bytes += pendingBytes
+	}
+
+	last := s.segments[len(s.segments)-1]
+	writer, err := os.OpenFile(s.segmentPath(last.seq), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir segmento do spool: %w", err)
+	}
+	s.writer = writer
+	return s.saveCursor()
+}
+
+func (s *Spool) listSegments() ([]uint64, error) {
+	dirEntries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar segmentos do spool: %w", err)
+	}
+
+	var seqs []uint64
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// scanSpoolSegment valida os registros de um segmento, truncando o que houver
+// depois do último registro íntegro. consumed é o número de registros antes da
+// posição do cursor quando o cursor aponta para este segmento.
+func scanSpoolSegment(path string, seq uint64, cursor spoolCursor) (*spoolSegment, int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("falha ao abrir segmento do spool: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("falha ao ler segmento do spool: %w", err)
+	}
+
+	seg := &spoolSegment{seq: seq}
+	consumed := 0
+	for seg.size < info.Size() {
+		_, n, err := readSpoolRecord(f, seg.size)
+		if err != nil {
+			break
+		}
+		if seq == cursor.Segment && seg.size < cursor.Offset {
+			consumed++
+		}
+		seg.size += n
+		seg.entries++
+	}
+
+	if seg.size < info.Size() {
+		log.Printf("Spool: descartando %d bytes incompletos no fim do segmento %s", info.Size()-seg.size, path)
+		if err := f.Truncate(seg.size); err != nil {
+			return nil, 0, fmt.Errorf("falha ao truncar segmento do spool: %w", err)
+		}
+	}
+	return seg, consumed, nil
+}
+
+// Append grava uma mensagem no fim do spool. Quando o limite de bytes é
+// ultrapassado, os segmentos mais antigos são descartados.
+func (s *Spool) Append(entry SpoolEntry) error {
+	if s.cfg.Mode == SpoolModeMetadata {
+		entry.Payload = nil
+	}
+	record := encodeSpoolRecord(entry)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("spool fechado")
+	}
+	if s.cfg.MaxBytes > 0 && int64(len(record)) > s.cfg.MaxBytes {
+		return fmt.Errorf("mensagem de %d bytes excede o limite do spool", len(record))
+	}
+
+	last := s.segments[len(s.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > s.cfg.SegmentBytes {
+		if err := s.startSegment(last.seq + 1); err != nil {
+			return err
+		}
+		last = s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.writer.Write(record); err != nil {
+		// Remove uma escrita parcial para não corromper os próximos registros
+		_ = s.writer.Truncate(last.size)
+		return fmt.Errorf("falha ao gravar no spool: %w", err)
+	}
+
+	last.size += int64(len(record))
+	last.entries++
+	s.entries++
+	s.bytes += int64(len(record))
+
+	s.enforceLimit()
+	s.updateMetrics()
+	metrics.SpoolOperations.WithLabelValues("write").Inc()
+	return nil
+}
+
+// Peek retorna a mensagem mais antiga sem removê-la; Commit a remove após o
+// reenvio. Mensagens mais antigas que MaxAge são descartadas aqui.
+func (s *Spool) Peek() (SpoolEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed {
+			return SpoolEntry{}, false, errors.New("spool fechado")
+		}
+		if s.entries == 0 {
+			return SpoolEntry{}, false, nil
+		}
+
+		head := s.segments[0]
+		if s.cursor.Offset >= head.size {
+			// Segmento consumido; como ainda há mensagens, existe um próximo
+			if err := s.dropHead(); err != nil {
+				return SpoolEntry{}, false, err
+			}
+			continue
+		}
+
+		if s.reader == nil {
+			reader, err := os.Open(s.segmentPath(head.seq))
+			if err != nil {
+				return SpoolEntry{}, false, fmt.Errorf("falha ao abrir segmento do spool: %w", err)
+			}
+			s.reader = reader
+		}
+
+		entry, n, err := readSpoolRecord(s.reader, s.cursor.Offset)
+		if err != nil {
+			log.Printf("Spool: segmento %d corrompido a partir do byte %d, descartando o restante: %v",
+				head.seq, s.cursor.Offset, err)
+			metrics.SpoolOperations.WithLabelValues("error").Inc()
+			s.entries -= head.entries - s.readCount
+			s.bytes -= head.size - s.cursor.Offset
+			s.readCount = head.entries
+			s.cursor.Offset = head.size
+			s.updateMetrics()
+			continue
+		}
+
+		if s.cfg.MaxAge > 0 && time.Since(entry.Timestamp) > s.cfg.MaxAge {
+			s.peekLen = n
+			if err := s.advance(); err != nil {
+				return SpoolEntry{}, false, err
+			}
+			metrics.SpoolOperations.WithLabelValues("expired").Inc()
+			continue
+		}
+
+		s.peekLen = n
+		return entry, true, nil
+	}
+}
+
+// Commit remove a mensagem retornada pelo último Peek.
+func (s *Spool) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.peekLen == 0 {
+		return nil
+	}
+	return s.advance()
+}
+
+// Stats retorna o número de mensagens pendentes e os bytes que ocupam.
+func (s *Spool) Stats() (entries int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries, s.bytes
+}
+
+// Close fecha os arquivos do spool. As mensagens pendentes continuam em disco.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.saveCursor()
+	if s.writer != nil {
+		if syncErr := s.writer.Sync(); syncErr != nil && err == nil {
+			err = fmt.Errorf("falha ao sincronizar segmento do spool: %w", syncErr)
+		}
+	}
+	s.closeFiles()
+	return err
+}
+
+// advance move o cursor para depois do registro do último Peek.
+// Deve ser chamado com s.mu travado.
+func (s *Spool) advance() error {
+	s.cursor.Offset += s.peekLen
+	s.bytes -= s.peekLen
+	s.readCount++
+	s.entries--
+	s.peekLen = 0
+
+	var err error
+	if s.entries == 0 {
+		// Tudo reenviado: recomeça num segmento novo para liberar o disco
+		err = s.reset()
+	} else {
+		err = s.saveCursor()
+	}
+	s.updateMetrics()
+	return err
+}
+
+// dropHead remove o segmento do cursor e avança para o próximo.
+// Deve ser chamado com s.mu travado e len(s.segments) > 1.
+func (s *Spool) dropHead() error {
+	head := s.segments[0]
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	_ = os.Remove(s.segmentPath(head.seq))
+
+	s.segments = s.segments[1:]
+	s.cursor = spoolCursor{Segment: s.segments[0].seq}
+	s.readCount = 0
+	return s.saveCursor()
+}
+
+// enforceLimit descarta os segmentos mais antigos enquanto o spool estiver
+// acima de MaxBytes. Deve ser chamado com s.mu travado.
+func (s *Spool) enforceLimit() {
+	for s.cfg.MaxBytes > 0 && s.bytes > s.cfg.MaxBytes && len(s.segments) > 1 {
+		head := s.segments[0]
+		dropped := head.entries - s.readCount
+		s.entries -= dropped
+		s.bytes -= head.size - s.cursor.Offset
+		s.peekLen = 0
+
+		if err := s.dropHead(); err != nil {
+			log.Printf("Spool: falha ao atualizar índice após descarte: %v", err)
+		}
+		metrics.SpoolOperations.WithLabelValues("evicted").Add(float64(dropped))
+		log.Printf("Spool cheio: %d mensagens antigas descartadas", dropped)
+	}
+}
+
+// reset apaga todos os segmentos e começa um novo. Deve ser chamado com s.mu
+// travado e o spool vazio.
+func (s *Spool) reset() error {
+	next := s.segments[len(s.segments)-1].seq + 1
+	s.closeFiles()
+	for _, seg := range s.segments {
+		_ = os.Remove(s.segmentPath(seg.seq))
+	}
+	s.segments = nil
+	s.readCount = 0
+	return s.startSegment(next)
+}
+
+// startSegment cria o segmento seq e passa a gravar nele. O segmento anterior
+// é sincronizado com o disco antes de ser fechado.
+func (s *Spool) startSegment(seq uint64) error {
+	if s.writer != nil {
+		if err := s.writer.Sync(); err != nil {
+			return fmt.Errorf("falha ao sincronizar segmento do spool: %w", err)
+		}
+	}
+
+	writer, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("falha ao criar segmento do spool: %w", err)
+	}
+	if err := syncDir(s.cfg.Dir); err != nil {
+		writer.Close()
+		return fmt.Errorf("falha ao sincronizar diretório do spool: %w", err)
+	}
+
+	if s.writer != nil {
+		s.writer.Close()
+	}
+	s.writer = writer
+	s.segments = append(s.segments, &spoolSegment{seq: seq})
+	if len(s.segments) == 1 {
+		s.cursor = spoolCursor{Segment: seq}
+		return s.saveCursor()
+	}
+	return nil
+}
+
+// saveCursor grava o índice de forma atômica (arquivo temporário + rename).
+// O arquivo e o diretório são sincronizados para que o índice sobreviva a uma
+// queda de energia sem voltar a apontar para mensagens já reenviadas.
+func (s *Spool) saveCursor() error {
+	data, err := json.Marshal(s.cursor)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.cfg.Dir, spoolIndexFile)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("falha ao gravar índice do spool: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("falha ao gravar índice do spool: %w", err)
+	}
+	if err := syncDir(s.cfg.Dir); err != nil {
+		return fmt.Errorf("falha ao sincronizar diretório do spool: %w", err)
+	}
+	return nil
+}
+
+// writeFileSync grava data em path e sincroniza o arquivo antes de fechá-lo.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir sincroniza as entradas do diretório (arquivos criados e renomeados).
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *Spool) closeFiles() {
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	if s.writer != nil {
+		s.writer.Close()
+		s.writer = nil
+	}
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+func (s *Spool) updateMetrics() {
+	metrics.SpoolEntries.Set(float64(s.entries))
+	metrics.SpoolBytes.Set(float64(s.bytes))
+}
+
+// encodeSpoolRecord serializa uma mensagem como
+// [tamanho do corpo][crc32 do corpo][timestamp][tamanho][flags][câmera]
+// [destinos][dados do frame][payload]. Os destinos (tamanho e nomes separados
+// por vírgula) só existem com spoolFlagSinks e os dados do frame (largura,
+// altura, tamanho e chave do Redis) com spoolFlagFrameMeta, então registros
+// antigos continuam válidos.
+func encodeSpoolRecord(entry SpoolEntry) []byte {
+	var sinks []byte
+	if len(entry.Sinks) > 0 {
+		joined := strings.Join(entry.Sinks, ",")
+		sinks = binary.BigEndian.AppendUint16(nil, uint16(len(joined)))
+		sinks = append(sinks, joined...)
+	}
+
+	var frameMeta []byte
+	if entry.RedisKey != "" || entry.Width > 0 || entry.Height > 0 {
+		frameMeta = binary.BigEndian.AppendUint16(nil, uint16(entry.Width))
+		frameMeta = binary.BigEndian.AppendUint16(frameMeta, uint16(entry.Height))
+		frameMeta = binary.BigEndian.AppendUint16(frameMeta, uint16(len(entry.RedisKey)))
+		frameMeta = append(frameMeta, entry.RedisKey...)
+	}
+
+	bodyLen := spoolFixedBodySize + len(entry.CameraID) + len(sinks) + len(frameMeta) + len(entry.Payload)
+	record := make([]byte, spoolHeaderSize+bodyLen)
+	body := record[spoolHeaderSize:]
+
+	binary.BigEndian.PutUint64(body[0:], uint64(entry.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint32(body[8:], uint32(entry.Size))
+	if entry.Payload != nil {
+		body[12] = spoolFlagPayload
+	}
+	if entry.ContentEncoding == ContentEncodingZstd {
+		body[12] |= spoolFlagZstd
+	}
+	if sinks != nil {
+		body[12] |= spoolFlagSinks
+	}
+	if frameMeta != nil {
+		body[12] |= spoolFlagFrameMeta
+	}
+	binary.BigEndian.PutUint16(body[13:], uint16(len(entry.CameraID)))
+	rest := body[spoolFixedBodySize:]
+	rest = rest[copy(rest, entry.CameraID):]
+	rest = rest[copy(rest, sinks):]
+	rest = rest[copy(rest, frameMeta):]
+	copy(rest, entry.Payload)
+
+	binary.BigEndian.PutUint32(record[0:], uint32(bodyLen))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(body))
+	return record
+}
+
+// readSpoolRecord lê o registro na posição off e retorna seu tamanho total.
+func readSpoolRecord(r io.ReaderAt, off int64) (SpoolEntry, int64, error) {
+	var header [spoolHeaderSize]byte
+	if _, err := r.ReadAt(header[:], off); err != nil {
+		return SpoolEntry{}, 0, err
+	}
+
+	bodyLen := binary.BigEndian.Uint32(header[0:])
+	if bodyLen < spoolFixedBodySize || bodyLen > spoolMaxRecordSize {
+		return SpoolEntry{}, 0, errSpoolCorrupt
+	}
+
+	body := make([]byte, bodyLen)
+	if _, err := r.ReadAt(body, off+spoolHeaderSize); err != nil {
+		return SpoolEntry{}, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+		return SpoolEntry{}, 0, errSpoolCorrupt
+	}
+
+	cameraLen := int(binary.BigEndian.Uint16(body[13:]))
+	if spoolFixedBodySize+cameraLen > len(body) {
+		return SpoolEntry{}, 0, errSpoolCorrupt
+	}
+
+	entry := SpoolEntry{
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(body[0:]))),
+		Size:      int(binary.BigEndian.Uint32(body[8:])),
+		CameraID:  string(body[spoolFixedBodySize : spoolFixedBodySize+cameraLen]),
+	}
+	rest := body[spoolFixedBodySize+cameraLen:]
+	if body[12]&spoolFlagSinks != 0 {
+		if len(rest) < 2 || 2+int(binary.BigEndian.Uint16(rest)) > len(rest) {
+			return SpoolEntry{}, 0, errSpoolCorrupt
+		}
+		sinksLen := int(binary.BigEndian.Uint16(rest))
+		entry.Sinks = strings.Split(string(rest[2:2+sinksLen]), ",")
+		rest = rest[2+sinksLen:]
+	}
+	if body[12]&spoolFlagFrameMeta != 0 {
+		if len(rest) < 6 || 6+int(binary.BigEndian.Uint16(rest[4:])) > len(rest) {
+			return SpoolEntry{}, 0, errSpoolCorrupt
+		}
+		entry.Width = int(binary.BigEndian.Uint16(rest[0:]))
+		entry.Height = int(binary.BigEndian.Uint16(rest[2:]))
+		keyLen := int(binary.BigEndian.Uint16(rest[4:]))
+		entry.RedisKey = string(rest[6 : 6+keyLen])
+		rest = rest[6+keyLen:]
+	}
+	if body[12]&spoolFlagPayload != 0 {
+		entry.Payload = rest
+	}
+	if body[12]&spoolFlagZstd != 0 {
+		entry.ContentEncoding = ContentEncodingZstd
+	}
+	return entry, int64(spoolHeaderSize) + int64(bodyLen), nil
+}
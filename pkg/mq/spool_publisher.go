@@ -0,0 +1,246 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/buffer"
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// spoolRetryInterval é o intervalo entre tentativas de reenvio enquanto o
+// broker continua indisponível.
+const spoolRetryInterval = 5 * time.Second
+
+// SinkPublisher é um Publisher com vários destinos que publica só em alguns
+// deles. Implementado por CompositePublisher.
+type SinkPublisher interface {
+	Publisher
+	PublishTo(ctx context.Context, sinks []string, cameraID string, payload []byte) error
+}
+
+// SpoolPublisher envolve um Publisher gravando no spool em disco as mensagens
+// cuja publicação falhou. Quando o broker volta, as mensagens são reenviadas
+// na ordem em que foram gravadas, limitadas a replayRate mensagens por
+// segundo para não competir com o tráfego ao vivo.
+type SpoolPublisher struct {
+	inner    Publisher
+	spool    *Spool
+	interval time.Duration
+
+	mu             sync.RWMutex
+	metadataReplay func(ctx context.Context, entry SpoolEntry) error
+
+	// retrySinks são os destinos que ainda faltam para a mensagem no início
+	// do spool, quando o reenvio dela falhou só em parte. Usado só pela
+	// goroutine de reenvio
+	retrySinks []string
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSpoolPublisher cria o publisher e inicia o reenvio em background.
+// replayRate <= 0 reenvia sem limite de taxa.
+func NewSpoolPublisher(inner Publisher, spool *Spool, replayRate float64) *SpoolPublisher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var interval time.Duration
+	if replayRate > 0 {
+		interval = time.Duration(float64(time.Second) / replayRate)
+	}
+
+	p := &SpoolPublisher{
+		inner:    inner,
+		spool:    spool,
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go p.replayLoop()
+	return p
+}
+
+// SetMetadataReplay define como reenviar as mensagens gravadas no modo
+// metadata. Sem essa função, essas mensagens são descartadas no reenvio.
+func (p *SpoolPublisher) SetMetadataReplay(fn func(ctx context.Context, entry SpoolEntry) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metadataReplay = fn
+}
+
+// Publish publica pelo publisher interno e, se falhar, grava a mensagem no
+// spool. A mensagem gravada conta como aceita: o erro só é retornado quando
+// nem o spool consegue guardá-la. Com um CompositePublisher, o spool guarda
+// os destinos que falharam e só eles recebem a mensagem no reenvio.
+func (p *SpoolPublisher) Publish(ctx context.Context, cameraID string, payload []byte) error {
+	err := p.inner.Publish(ctx, cameraID, payload)
+	if err == nil {
+		// O broker está respondendo: antecipa o reenvio das pendentes
+		p.signal()
+		return nil
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+
+	entry := SpoolEntry{
+		CameraID:  cameraID,
+		Timestamp: time.Now(),
+		Size:      len(payload),
+		Payload:   payload,
+		Sinks:     FailedSinks(err),
+	}
+	if info, ok := FrameInfoFromContext(ctx); ok {
+		entry.Timestamp = info.CaptureTime
+		entry.ContentEncoding = info.ContentEncoding
+		entry.RedisKey = info.StorageKey
+		entry.Width = info.Width
+		entry.Height = info.Height
+	}
+	if spoolErr := p.spool.Append(entry); spoolErr != nil {
+		metrics.SpoolOperations.WithLabelValues("error").Inc()
+		return fmt.Errorf("%w; falha ao gravar no spool: %v", err, spoolErr)
+	}
+	return nil
+}
+
+// Flush aguarda as mensagens pendentes do publisher interno. As mensagens
+// do spool não são reenviadas aqui: ficam em disco para a próxima execução.
+func (p *SpoolPublisher) Flush(ctx context.Context) error {
+	return Flush(ctx, p.inner)
+}
+
+// Close interrompe o reenvio e fecha o spool e o publisher interno. As
+// mensagens pendentes ficam em disco para a próxima execução.
+func (p *SpoolPublisher) Close() error {
+	p.cancel()
+	<-p.done
+
+	err := p.spool.Close()
+	if cerr := p.inner.Close(); cerr != nil {
+		err = cerr
+	}
+	return err
+}
+
+func (p *SpoolPublisher) signal() {
+	if entries, _ := p.spool.Stats(); entries == 0 {
+		return
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *SpoolPublisher) replayLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(spoolRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		p.drain()
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain reenvia as mensagens pendentes até o spool esvaziar ou uma publicação
+// falhar.
+func (p *SpoolPublisher) drain() {
+	replayed := 0
+	for p.ctx.Err() == nil {
+		entry, ok, err := p.spool.Peek()
+		if err != nil {
+			log.Printf("Erro ao ler spool: %v", err)
+			return
+		}
+		if !ok {
+			if replayed > 0 {
+				log.Printf("Spool esvaziado: %d mensagens reenviadas", replayed)
+			}
+			return
+		}
+
+		if err := p.replay(entry); err != nil {
+			if replayed > 0 {
+				log.Printf("Reenvio do spool interrompido após %d mensagens: %v", replayed, err)
+			}
+			return
+		}
+		p.retrySinks = nil
+		if err := p.spool.Commit(); err != nil {
+			log.Printf("Erro ao atualizar índice do spool: %v", err)
+		}
+		metrics.SpoolOperations.WithLabelValues("replay").Inc()
+		replayed++
+
+		if p.interval > 0 {
+			select {
+			case <-time.After(p.interval):
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (p *SpoolPublisher) replay(entry SpoolEntry) error {
+	if entry.Payload != nil {
+		// Sequência e hash não são guardados: o reenvio leva só o essencial
+		// para o consumidor interpretar o payload
+		ctx := WithFrameInfo(p.ctx, FrameInfo{
+			CameraID:        entry.CameraID,
+			CaptureTime:     entry.Timestamp,
+			ContentEncoding: entry.ContentEncoding,
+		})
+		// O payload lido do spool é um buffer novo: os destinos best-effort
+		// podem ficar com ele sem copiar
+		frame := buffer.NewFrame(entry.CameraID, entry.Payload, entry.Timestamp, nil)
+		defer frame.Release()
+		return p.replayFrame(WithFrame(ctx, frame), entry)
+	}
+
+	p.mu.RLock()
+	fn := p.metadataReplay
+	p.mu.RUnlock()
+
+	if fn == nil {
+		return nil
+	}
+	return fn(p.ctx, entry)
+}
+
+// replayFrame reenvia o frame só aos destinos que falharam, quando o spool os
+// registrou. Se alguns deles aceitarem e outros não, a próxima tentativa vai
+// só para os que ainda faltam.
+func (p *SpoolPublisher) replayFrame(ctx context.Context, entry SpoolEntry) error {
+	inner, ok := p.inner.(SinkPublisher)
+	if !ok || len(entry.Sinks) == 0 {
+		return p.inner.Publish(ctx, entry.CameraID, entry.Payload)
+	}
+
+	sinks := entry.Sinks
+	if p.retrySinks != nil {
+		sinks = p.retrySinks
+	}
+	err := inner.PublishTo(ctx, sinks, entry.CameraID, entry.Payload)
+	if failed := FailedSinks(err); len(failed) > 0 {
+		p.retrySinks = failed
+	}
+	return err
+}
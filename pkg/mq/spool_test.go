@@ -0,0 +1,344 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spoolTestEntry(cameraID string, i int) SpoolEntry {
+	payload := []byte(fmt.Sprintf("frame-%d", i))
+	return SpoolEntry{CameraID: cameraID, Timestamp: time.Now(), Size: len(payload), Payload: payload}
+}
+
+func drainSpool(t *testing.T, s *Spool) []string {
+	t.Helper()
+	var got []string
+	for {
+		entry, ok, err := s.Peek()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		got = append(got, string(entry.Payload))
+		require.NoError(t, s.Commit())
+	}
+}
+
+func TestSpoolFIFOAcrossSegments(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), SegmentBytes: 64})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, s.Append(spoolTestEntry("cam1", i)))
+	}
+	assert.Greater(t, len(s.segments), 1)
+
+	entries, bytes := s.Stats()
+	assert.Equal(t, 20, entries)
+	assert.Greater(t, bytes, int64(0))
+
+	got := drainSpool(t, s)
+	require.Len(t, got, 20)
+	for i, payload := range got {
+		assert.Equal(t, fmt.Sprintf("frame-%d", i), payload)
+	}
+
+	entries, bytes = s.Stats()
+	assert.Equal(t, 0, entries)
+	assert.Equal(t, int64(0), bytes)
+	assert.Len(t, s.segments, 1)
+}
+
+func TestSpoolRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := OpenSpool(SpoolConfig{Dir: dir, SegmentBytes: 64})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Append(spoolTestEntry("cam1", i)))
+	}
+	for i := 0; i < 4; i++ {
+		_, ok, err := s.Peek()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NoError(t, s.Commit())
+	}
+	// Lida mas não confirmada: deve ser reenviada após reiniciar
+	_, _, err = s.Peek()
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = OpenSpool(SpoolConfig{Dir: dir, SegmentBytes: 64})
+	require.NoError(t, err)
+	defer s.Close()
+
+	entries, _ := s.Stats()
+	assert.Equal(t, 6, entries)
+	assert.Equal(t, []string{"frame-4", "frame-5", "frame-6", "frame-7", "frame-8", "frame-9"}, drainSpool(t, s))
+}
+
+func TestSpoolTruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := OpenSpool(SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Append(spoolTestEntry("cam1", i)))
+	}
+	path := s.segmentPath(s.segments[0].seq)
+	require.NoError(t, s.Close())
+
+	// Simula um crash no meio da gravação do último registro
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	s, err = OpenSpool(SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Append(spoolTestEntry("cam1", 3)))
+	assert.Equal(t, []string{"frame-0", "frame-1", "frame-3"}, drainSpool(t, s))
+}
+
+func TestSpoolEvictsOldestWhenFull(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), MaxBytes: 400})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, s.Append(spoolTestEntry("cam1", i)))
+	}
+
+	entries, bytes := s.Stats()
+	assert.LessOrEqual(t, bytes, int64(400))
+	assert.Less(t, entries, 50)
+
+	got := drainSpool(t, s)
+	require.Len(t, got, entries)
+	// Os descartados são sempre os mais antigos
+	assert.Equal(t, "frame-49", got[len(got)-1])
+}
+
+func TestSpoolSkipsExpiredEntries(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), MaxAge: time.Minute})
+	require.NoError(t, err)
+	defer s.Close()
+
+	old := spoolTestEntry("cam1", 0)
+	old.Timestamp = time.Now().Add(-time.Hour)
+	require.NoError(t, s.Append(old))
+	require.NoError(t, s.Append(spoolTestEntry("cam1", 1)))
+
+	assert.Equal(t, []string{"frame-1"}, drainSpool(t, s))
+}
+
+func TestSpoolMetadataMode(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(SpoolConfig{Dir: dir, Mode: SpoolModeMetadata})
+	require.NoError(t, err)
+
+	ts := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.Append(SpoolEntry{
+		CameraID:        "cam7",
+		Timestamp:       ts,
+		Size:            12345,
+		Payload:         []byte("jpeg"),
+		ContentEncoding: ContentEncodingZstd,
+		RedisKey:        "frames:vhost:cam7:1700000000000:000001",
+		Width:           1920,
+		Height:          1080,
+	}))
+	require.NoError(t, s.Append(SpoolEntry{CameraID: "cam7", Timestamp: ts, Size: 10}))
+	require.NoError(t, s.Close())
+
+	s, err = OpenSpool(SpoolConfig{Dir: dir, Mode: SpoolModeMetadata})
+	require.NoError(t, err)
+	defer s.Close()
+
+	entry, ok, err := s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "cam7", entry.CameraID)
+	assert.Equal(t, 12345, entry.Size)
+	assert.True(t, ts.Equal(entry.Timestamp))
+	assert.Nil(t, entry.Payload)
+	assert.Equal(t, ContentEncodingZstd, entry.ContentEncoding)
+	assert.Equal(t, "frames:vhost:cam7:1700000000000:000001", entry.RedisKey)
+	assert.Equal(t, 1920, entry.Width)
+	assert.Equal(t, 1080, entry.Height)
+	require.NoError(t, s.Commit())
+
+	// Sem chave nem dimensões o registro não leva os dados do frame
+	entry, ok, err = s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 10, entry.Size)
+	assert.Empty(t, entry.RedisKey)
+	assert.Zero(t, entry.Width)
+}
+
+func TestSpoolKeepsContentEncoding(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer s.Close()
+
+	entry := spoolTestEntry("cam1", 0)
+	entry.ContentEncoding = ContentEncodingZstd
+	require.NoError(t, s.Append(entry))
+	require.NoError(t, s.Append(spoolTestEntry("cam1", 1)))
+
+	got, ok, err := s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, ContentEncodingZstd, got.ContentEncoding)
+	require.NoError(t, s.Commit())
+
+	got, ok, err = s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, got.ContentEncoding)
+}
+
+func TestSpoolKeepsFailedSinks(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+
+	entry := spoolTestEntry("cam1", 0)
+	entry.Sinks = []string{"local", "backup"}
+	require.NoError(t, s.Append(entry))
+	require.NoError(t, s.Append(spoolTestEntry("cam1", 1)))
+	require.NoError(t, s.Close())
+
+	s, err = OpenSpool(SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+	defer s.Close()
+
+	got, ok, err := s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []string{"local", "backup"}, got.Sinks)
+	assert.Equal(t, "frame-0", string(got.Payload))
+	require.NoError(t, s.Commit())
+
+	got, ok, err = s.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Nil(t, got.Sinks)
+	assert.Equal(t, "frame-1", string(got.Payload))
+}
+
+func TestOpenSpoolInvalidMode(t *testing.T) {
+	_, err := OpenSpool(SpoolConfig{Dir: filepath.Join(t.TempDir(), "spool"), Mode: "everything"})
+	assert.Error(t, err)
+}
+
+func TestSpoolPublisherReplaysInOrder(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	var online atomic.Bool
+	var mu sync.Mutex
+	var delivered []string
+
+	inner := &MockPublisher{
+		PublishFunc: func(ctx context.Context, cameraID string, payload []byte) error {
+			if !online.Load() {
+				return errors.New("broker indisponível")
+			}
+			mu.Lock()
+			delivered = append(delivered, string(payload))
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	p := NewSpoolPublisher(inner, s, 1000)
+	defer p.Close()
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, p.Publish(context.Background(), "cam1", []byte(fmt.Sprintf("frame-%d", i))))
+	}
+	entries, _ := s.Stats()
+	assert.Equal(t, 5, entries)
+
+	// A primeira publicação bem-sucedida dispara o reenvio
+	online.Store(true)
+	assert.NoError(t, p.Publish(context.Background(), "cam1", []byte("live")))
+
+	require.Eventually(t, func() bool {
+		entries, _ := s.Stats()
+		return entries == 0
+	}, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var replayed []string
+	for _, payload := range delivered {
+		if payload != "live" {
+			replayed = append(replayed, payload)
+		}
+	}
+	assert.Equal(t, []string{"frame-0", "frame-1", "frame-2", "frame-3", "frame-4"}, replayed)
+}
+
+func TestSpoolPublisherMetadataReplay(t *testing.T) {
+	s, err := OpenSpool(SpoolConfig{Dir: t.TempDir(), Mode: SpoolModeMetadata})
+	require.NoError(t, err)
+
+	var online atomic.Bool
+	inner := &MockPublisher{
+		PublishFunc: func(ctx context.Context, cameraID string, payload []byte) error {
+			if !online.Load() {
+				return errors.New("broker indisponível")
+			}
+			return nil
+		},
+	}
+
+	p := NewSpoolPublisher(inner, s, 0)
+	defer p.Close()
+
+	replayed := make(chan SpoolEntry, 1)
+	p.SetMetadataReplay(func(ctx context.Context, entry SpoolEntry) error {
+		replayed <- entry
+		return nil
+	})
+
+	ctx := WithFrameInfo(context.Background(), FrameInfo{
+		CameraID:        "cam1",
+		CaptureTime:     time.Now(),
+		Width:           640,
+		Height:          480,
+		ContentEncoding: ContentEncodingZstd,
+		StorageKey:      "frames:vhost:cam1:1",
+	})
+	assert.NoError(t, p.Publish(ctx, "cam1", []byte("jpeg")))
+	online.Store(true)
+	assert.NoError(t, p.Publish(context.Background(), "cam1", []byte("live")))
+
+	select {
+	case entry := <-replayed:
+		assert.Equal(t, "cam1", entry.CameraID)
+		assert.Equal(t, 4, entry.Size)
+		assert.Nil(t, entry.Payload)
+		assert.Equal(t, "frames:vhost:cam1:1", entry.RedisKey)
+		assert.Equal(t, 640, entry.Width)
+		assert.Equal(t, 480, entry.Height)
+		assert.Equal(t, ContentEncodingZstd, entry.ContentEncoding)
+	case <-time.After(2 * time.Second):
+		t.Fatal("metadado não foi reenviado")
+	}
+}
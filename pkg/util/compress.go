@@ -1,12 +1,14 @@
 package util
 
 import (
-	"bytes"
 	"fmt"
 
 	zstd "github.com/klauspost/compress/zstd"
 )
 
+// Compressor comprime frames com zstd. O encoder é criado uma vez, com o
+// nível configurado, e compartilhado: EncodeAll pode ser chamado de várias
+// goroutines ao mesmo tempo.
 type Compressor struct {
 	encoder *zstd.Encoder
 	level   int
@@ -20,30 +22,17 @@ func NewCompressor(level int) (*Compressor, error) {
 	return &Compressor{encoder: enc, level: level}, nil
 }
 
+// Level retorna o nível zstd configurado.
+func (c *Compressor) Level() int {
+	return c.level
+}
+
 func (c *Compressor) Compress(data []byte) ([]byte, error) {
-	var b bytes.Buffer
-	w, err := zstd.NewWriter(&b)
-	if err != nil {
-		return nil, err
-	}
-	if _, err := w.Write(data); err != nil {
-		w.Close()
-		return nil, err
-	}
-	w.Close()
-	return b.Bytes(), nil
+	return c.encoder.EncodeAll(data, make([]byte, 0, len(data))), nil
 }
 
+var decoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
 func Decompress(data []byte) ([]byte, error) {
-	r, err := zstd.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-	var out bytes.Buffer
-	_, err = out.ReadFrom(r)
-	if err != nil {
-		return nil, err
-	}
-	return out.Bytes(), nil
+	return decoder.DecodeAll(data, nil)
 }
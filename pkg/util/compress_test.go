@@ -0,0 +1,51 @@
+package util
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	c, err := NewCompressor(3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.Level())
+
+	data := bytes.Repeat([]byte("frame"), 10000)
+	compressed, err := c.Compress(data)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(data))
+
+	out, err := Decompress(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestCompressConcurrent(t *testing.T) {
+	c, err := NewCompressor(1)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				data := make([]byte, 32<<10)
+				for k := range data {
+					data[k] = byte(rand.IntN(4))
+				}
+				compressed, err := c.Compress(data)
+				require.NoError(t, err)
+				out, err := Decompress(compressed)
+				require.NoError(t, err)
+				assert.Equal(t, data, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// CertExpiryWarning é a antecedência com que um certificado perto de expirar
+// passa a gerar aviso no log.
+var CertExpiryWarning = 30 * 24 * time.Hour
+
+// TLSOptions descreve os arquivos e ajustes de uma conexão TLS.
+type TLSOptions struct {
+	Name               string // Identifica a conexão nas métricas e avisos (amqp, redis, ...)
+	CAFile             string // Bundle de CAs (PEM); vazio usa as CAs do sistema
+	CertFile           string // Certificado do cliente (PEM), para autenticação mútua
+	KeyFile            string
+	ServerName         string // Sobrescreve o nome verificado no certificado do servidor
+	InsecureSkipVerify bool
+}
+
+// LoadTLSConfig monta um *tls.Config a partir de opts. A validade dos
+// certificados (CA, cliente e, a cada conexão, servidor) é exportada na
+// métrica edge_video_tls_certificate_expiry_timestamp_seconds.
+func LoadTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) > 0 {
+				recordCertExpiry(opts.Name, "server", cs.PeerCertificates[0])
+			}
+			return nil
+		},
+	}
+
+	if opts.CAFile != "" {
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ler CA %s: %w", opts.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("nenhum certificado PEM válido em %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+
+		// Com várias CAs no bundle vale a que expira primeiro
+		if ca := firstToExpire(data); ca != nil {
+			recordCertExpiry(opts.Name, "ca", ca)
+		}
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("cert_file e key_file devem ser informados juntos")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("carregar certificado do cliente: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		if cert.Leaf != nil {
+			recordCertExpiry(opts.Name, "client", cert.Leaf)
+		}
+	}
+
+	return cfg, nil
+}
+
+func firstToExpire(pemData []byte) *x509.Certificate {
+	var first *x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			return first
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if first == nil || cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+}
+
+// certWarned evita repetir o aviso a cada conexão com o mesmo certificado.
+var certWarned sync.Map
+
+func recordCertExpiry(connection, kind string, cert *x509.Certificate) {
+	metrics.TLSCertificateExpiry.WithLabelValues(connection, kind).Set(float64(cert.NotAfter.Unix()))
+
+	remaining := time.Until(cert.NotAfter)
+	if remaining > CertExpiryWarning {
+		return
+	}
+	key := connection + "/" + kind + "/" + cert.SerialNumber.String()
+	if _, warned := certWarned.LoadOrStore(key, struct{}{}); warned {
+		return
+	}
+	if remaining <= 0 {
+		log.Printf("AVISO: certificado TLS %s (%s, %s) expirou em %s",
+			kind, connection, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+		return
+	}
+	log.Printf("AVISO: certificado TLS %s (%s, %s) expira em %s (%d dias)",
+		kind, connection, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339), int(remaining.Hours()/24))
+}
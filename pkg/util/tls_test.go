@@ -0,0 +1,154 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, notAfter time.Time, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestLoadTLSConfig_RecordsCertificateExpiry(t *testing.T) {
+	ca := newTestCert(t, "ca", time.Now().Add(365*24*time.Hour), nil)
+	client := newTestCert(t, "client", time.Now().Add(10*24*time.Hour), ca)
+
+	cfg, err := LoadTLSConfig(TLSOptions{
+		Name:     "test-load",
+		CAFile:   writeTestFile(t, "ca.pem", ca.certPEM),
+		CertFile: writeTestFile(t, "client.pem", client.certPEM),
+		KeyFile:  writeTestFile(t, "client.key", client.keyPEM),
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.Len(t, cfg.Certificates, 1)
+
+	assert.Equal(t, float64(ca.cert.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("test-load", "ca")))
+	assert.Equal(t, float64(client.cert.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("test-load", "client")))
+}
+
+func TestLoadTLSConfig_CABundleUsesFirstToExpire(t *testing.T) {
+	older := newTestCert(t, "ca-1", time.Now().Add(24*time.Hour), nil)
+	newer := newTestCert(t, "ca-2", time.Now().Add(365*24*time.Hour), nil)
+	bundle := append(append([]byte{}, newer.certPEM...), older.certPEM...)
+
+	_, err := LoadTLSConfig(TLSOptions{Name: "test-bundle", CAFile: writeTestFile(t, "ca.pem", bundle)})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(older.cert.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("test-bundle", "ca")))
+}
+
+func TestLoadTLSConfig_RecordsServerCertificate(t *testing.T) {
+	ca := newTestCert(t, "ca", time.Now().Add(365*24*time.Hour), nil)
+	server := newTestCert(t, "server", time.Now().Add(90*24*time.Hour), ca)
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	require.NoError(t, err)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	cfg, err := LoadTLSConfig(TLSOptions{
+		Name:       "test-server",
+		CAFile:     writeTestFile(t, "ca.pem", ca.certPEM),
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), cfg)
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Equal(t, float64(server.cert.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("test-server", "server")))
+}
+
+func TestLoadTLSConfig_Errors(t *testing.T) {
+	_, err := LoadTLSConfig(TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+
+	_, err = LoadTLSConfig(TLSOptions{CAFile: writeTestFile(t, "ca.pem", []byte("not pem"))})
+	assert.Error(t, err)
+
+	_, err = LoadTLSConfig(TLSOptions{CertFile: "client.pem"})
+	assert.Error(t, err, "cert_file sem key_file")
+}
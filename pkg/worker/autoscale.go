@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
+)
+
+// AutoscaleConfig configura o ajuste automático do número de workers.
+type AutoscaleConfig struct {
+	// Name identifica o pool nas métricas (padrão "main").
+	Name string
+	// MinWorkers e MaxWorkers limitam o número de workers.
+	MinWorkers int
+	MaxWorkers int
+	// ScaleUpWait é a espera média na fila a partir da qual workers são adicionados.
+	ScaleUpWait time.Duration
+	// ScaleDownIdle é por quanto tempo o pool precisa ter workers sobrando
+	// antes de remover um deles.
+	ScaleDownIdle time.Duration
+	// Interval é o período de avaliação do controlador.
+	Interval time.Duration
+	// CanScaleUp, se definido, é consultado antes de adicionar workers
+	// (ex.: bloquear o crescimento com memória em nível crítico).
+	CanScaleUp func() bool
+}
+
+func (c *AutoscaleConfig) applyDefaults(current int) {
+	if c.Name == "" {
+		c.Name = "main"
+	}
+	if c.MinWorkers <= 0 {
+		c.MinWorkers = 1
+	}
+	if c.MaxWorkers < c.MinWorkers {
+		c.MaxWorkers = c.MinWorkers
+	}
+	if c.MaxWorkers < current {
+		c.MaxWorkers = current
+	}
+	if c.ScaleUpWait <= 0 {
+		c.ScaleUpWait = 100 * time.Millisecond
+	}
+	if c.ScaleDownIdle <= 0 {
+		c.ScaleDownIdle = 30 * time.Second
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+}
+
+// EnableAutoscale inicia o controlador que adiciona workers quando a espera
+// na fila cresce e remove workers ociosos, respeitando os limites configurados.
+func (p *Pool) EnableAutoscale(cfg AutoscaleConfig) {
+	p.mu.Lock()
+	cfg.applyDefaults(p.workers)
+	if p.workers < cfg.MinWorkers {
+		p.spawnWorkers(cfg.MinWorkers - p.workers)
+	}
+	p.autoscale = &cfg
+	workers := p.workers
+	p.mu.Unlock()
+
+	metrics.WorkerPoolWorkers.WithLabelValues(cfg.Name).Set(float64(workers))
+
+	log.Printf("Autoscaling do worker pool ativado: %d-%d workers, espera alvo %v",
+		cfg.MinWorkers, cfg.MaxWorkers, cfg.ScaleUpWait)
+
+	go p.autoscaleLoop(cfg)
+}
+
+func (p *Pool) autoscaleLoop(cfg AutoscaleConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	var idleSince time.Time
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+
+		case <-ticker.C:
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				return
+			}
+
+			var avgWait time.Duration
+			if p.waitCount > 0 {
+				avgWait = p.waitSum / time.Duration(p.waitCount)
+			}
+			p.waitSum = 0
+			p.waitCount = 0
+
+			workers := p.workers - p.retire
+			queued := p.queued
+			processing := int(atomic.LoadInt32(&p.processing))
+			p.mu.Unlock()
+
+			metrics.WorkerPoolQueueWait.WithLabelValues(cfg.Name).Set(avgWait.Seconds())
+
+			switch {
+			case avgWait > cfg.ScaleUpWait && workers < cfg.MaxWorkers:
+				idleSince = time.Time{}
+				if cfg.CanScaleUp != nil && !cfg.CanScaleUp() {
+					metrics.WorkerPoolScaleEvents.WithLabelValues(cfg.Name, "blocked").Inc()
+					continue
+				}
+
+				// Cresce 25% por rodada (mínimo 1) para reagir rápido sem disparar
+				step := workers / 4
+				if step < 1 {
+					step = 1
+				}
+				if workers+step > cfg.MaxWorkers {
+					step = cfg.MaxWorkers - workers
+				}
+				p.scale(cfg, step, avgWait)
+
+			case queued == 0 && processing < workers-1 && workers > cfg.MinWorkers:
+				if idleSince.IsZero() {
+					idleSince = time.Now()
+					continue
+				}
+				if time.Since(idleSince) >= cfg.ScaleDownIdle {
+					p.scale(cfg, -1, avgWait)
+					idleSince = time.Time{}
+				}
+
+			default:
+				idleSince = time.Time{}
+			}
+		}
+	}
+}
+
+// scale adiciona (delta > 0) ou remove (delta < 0) workers.
+func (p *Pool) scale(cfg AutoscaleConfig, delta int, avgWait time.Duration) {
+	p.mu.Lock()
+	if delta > 0 {
+		// Cancela aposentadorias pendentes antes de criar novos workers
+		reuse := min(delta, p.retire)
+		p.retire -= reuse
+		p.spawnWorkers(delta - reuse)
+	} else {
+		p.retire += -delta
+		p.cond.Broadcast()
+	}
+	target := p.workers - p.retire
+	p.mu.Unlock()
+
+	direction := "up"
+	if delta < 0 {
+		direction = "down"
+	}
+
+	metrics.WorkerPoolWorkers.WithLabelValues(cfg.Name).Set(float64(target))
+	metrics.WorkerPoolScaleEvents.WithLabelValues(cfg.Name, direction).Inc()
+
+	log.Printf("Worker pool redimensionado (%s): %d workers, espera média na fila %v",
+		direction, target, avgWait)
+}
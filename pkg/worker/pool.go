@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/T3-Labs/edge-video/pkg/metrics"
 )
 
 type Job interface {
@@ -13,81 +16,286 @@ type Job interface {
 	GetID() string
 }
 
+// KeyedJob é implementado por jobs que pertencem a uma fila própria dentro do
+// pool (ex.: uma câmera). Jobs sem chave vão para a fila padrão.
+type KeyedJob interface {
+	Job
+	QueueKey() string
+}
+
+// DiscardableJob é implementado por jobs que precisam liberar recursos quando
+// são abandonados na fila durante o encerramento do pool.
+type DiscardableJob interface {
+	Job
+	Discard()
+}
+
+const defaultQueueKey = "default"
+
+// jobQueue é a sub-fila de uma chave. credit é quantos jobs ainda podem ser
+// servidos na rodada atual do round-robin ponderado.
+type jobQueue struct {
+	key    string
+	jobs   []queuedJob
+	weight int
+	credit int
+	busy   bool // Modo ordenado: um job desta fila está em processamento
+}
+
+type queuedJob struct {
+	job        Job
+	enqueuedAt time.Time
+}
+
 type Pool struct {
-	jobs       chan Job
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queues   map[string]*jobQueue
+	active   []*jobQueue // Filas com jobs pendentes, na ordem do round-robin
+	cursor   int
+	queued   int
+	capacity int
+	closed   bool
+	ordered  bool
+
+	// Autoscaling: workers é o número atual de workers, retire quantos devem
+	// encerrar na próxima oportunidade e waitSum/waitCount acumulam o tempo de
+	// espera na fila desde a última avaliação do controlador
+	workers   int
+	nextID    int
+	retire    int
+	waitSum   time.Duration
+	waitCount int64
+	autoscale *AutoscaleConfig
+
 	results    chan error
-	workers    int
 	ctx        context.Context
 	cancel     context.CancelFunc
 	processing int32
-	
+
 	totalProcessed int64
 	totalErrors    int64
 }
 
 func NewPool(ctx context.Context, workers int, bufferSize int) *Pool {
 	ctx, cancel := context.WithCancel(ctx)
-	
+
 	pool := &Pool{
-		jobs:    make(chan Job, bufferSize),
-		results: make(chan error, bufferSize),
-		workers: workers,
-		ctx:     ctx,
-		cancel:  cancel,
+		queues:   make(map[string]*jobQueue),
+		capacity: bufferSize,
+		results:  make(chan error, bufferSize),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
-	
-	for i := 0; i < workers; i++ {
-		go pool.worker(i)
-	}
-	
+	pool.cond = sync.NewCond(&pool.mu)
+
+	pool.mu.Lock()
+	pool.spawnWorkers(workers)
+	pool.mu.Unlock()
+
 	go pool.resultCollector()
-	
+	go pool.wakeOnCancel()
+
 	log.Printf("Worker pool inicializado: %d workers, buffer de %d", workers, bufferSize)
-	
+
 	return pool
 }
 
+// SetWeight define o peso de uma fila no round-robin: uma fila com peso 3
+// recebe até 3 jobs por rodada enquanto uma com peso 1 recebe 1. O peso também
+// define a fatia da capacidade garantida para a fila quando o pool está cheio.
+func (p *Pool) SetWeight(key string, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queueFor(key).weight = weight
+}
+
+// SetOrdered ativa o modo ordenado: os jobs de uma mesma chave (câmera) são
+// processados um de cada vez, na ordem de submissão, enquanto chaves
+// diferentes continuam em paralelo. Deve ser chamado antes do primeiro Submit.
+func (p *Pool) SetOrdered(ordered bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ordered = ordered
+}
+
+// Ordered indica se o pool está em modo ordenado.
+func (p *Pool) Ordered() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ordered
+}
+
+// spawnWorkers inicia n workers. Deve ser chamado com p.mu travado.
+func (p *Pool) spawnWorkers(n int) {
+	for i := 0; i < n; i++ {
+		go p.worker(p.nextID)
+		p.nextID++
+		p.workers++
+	}
+}
+
 func (p *Pool) worker(id int) {
 	for {
+		job, q, ok := p.next()
+		if !ok {
+			return
+		}
+
+		err := job.Process(p.ctx)
+
+		p.done(q)
+		atomic.AddInt32(&p.processing, -1)
+		atomic.AddInt64(&p.totalProcessed, 1)
+
+		if err != nil {
+			atomic.AddInt64(&p.totalErrors, 1)
+		}
+
 		select {
+		case p.results <- err:
 		case <-p.ctx.Done():
 			return
-			
-		case job, ok := <-p.jobs:
-			if !ok {
-				return
-			}
-			
-			atomic.AddInt32(&p.processing, 1)
-			
-			err := job.Process(p.ctx)
-			
-			atomic.AddInt32(&p.processing, -1)
-			atomic.AddInt64(&p.totalProcessed, 1)
-			
-			if err != nil {
-				atomic.AddInt64(&p.totalErrors, 1)
-			}
-			
-			select {
-			case p.results <- err:
-			case <-p.ctx.Done():
-				return
-			default:
-			}
+		default:
+		}
+	}
+}
+
+// next bloqueia até haver um job disponível. Retorna false quando o pool foi
+// cancelado ou fechado e não há mais jobs pendentes.
+func (p *Pool) next() (Job, *jobQueue, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// No modo ordenado pode haver jobs enfileirados (p.queued > 0) apenas em
+	// filas ocupadas, que não estão em p.active
+	for len(p.active) == 0 {
+		if (p.closed && p.queued == 0) || p.ctx.Err() != nil {
+			return nil, nil, false
+		}
+		if p.retire > 0 {
+			// Worker ocioso escolhido pelo autoscaler para encerrar
+			p.retire--
+			p.workers--
+			return nil, nil, false
 		}
+		p.cond.Wait()
+	}
+
+	if p.ctx.Err() != nil {
+		return nil, nil, false
+	}
+
+	job, q := p.dequeue()
+	// Incrementado sob o lock para que Close nunca veja a fila vazia e nenhum
+	// job em processamento enquanto um worker acabou de retirar um job
+	atomic.AddInt32(&p.processing, 1)
+	return job, q, true
+}
+
+// done libera a fila após o processamento de um job. No modo ordenado a fila
+// volta ao round-robin somente agora, garantindo um job por vez por chave.
+func (p *Pool) done(q *jobQueue) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !q.busy {
+		return
+	}
+
+	q.busy = false
+	if len(q.jobs) > 0 {
+		p.active = append(p.active, q)
+		p.cond.Signal()
+	} else if p.closed && p.queued == 0 {
+		// Acorda workers aguardando o fim da drenagem
+		p.cond.Broadcast()
+	}
+}
+
+// dequeue retira o próximo job usando round-robin ponderado entre as filas
+// ativas. Deve ser chamado com p.mu travado e len(p.active) > 0.
+func (p *Pool) dequeue() (Job, *jobQueue) {
+	if p.cursor >= len(p.active) {
+		p.cursor = 0
+	}
+
+	q := p.active[p.cursor]
+	if q.credit <= 0 {
+		q.credit = q.weight
+	}
+
+	item := q.jobs[0]
+	q.jobs[0] = queuedJob{}
+	q.jobs = q.jobs[1:]
+	p.waitSum += time.Since(item.enqueuedAt)
+	p.waitCount++
+	q.credit--
+	p.queued--
+
+	if p.ordered {
+		// A fila sai do round-robin até o job terminar (ver done)
+		q.busy = true
+		q.credit = 0
+		p.active = append(p.active[:p.cursor], p.active[p.cursor+1:]...)
+	} else if len(q.jobs) == 0 {
+		q.credit = 0
+		p.active = append(p.active[:p.cursor], p.active[p.cursor+1:]...)
+	} else if q.credit == 0 {
+		p.cursor++
+	}
+
+	metrics.WorkerQueueDepth.WithLabelValues(q.key).Set(float64(len(q.jobs)))
+	return item.job, q
+}
+
+// queueFor retorna a fila da chave, criando-a se necessário.
+// Deve ser chamado com p.mu travado.
+func (p *Pool) queueFor(key string) *jobQueue {
+	q, ok := p.queues[key]
+	if !ok {
+		q = &jobQueue{key: key, weight: 1}
+		p.queues[key] = q
 	}
+	return q
+}
+
+// fairShare é a parte da capacidade garantida para uma fila, proporcional ao
+// seu peso. Deve ser chamado com p.mu travado.
+func (p *Pool) fairShare(q *jobQueue) int {
+	totalWeight := 0
+	for _, other := range p.queues {
+		totalWeight += other.weight
+	}
+
+	share := p.capacity * q.weight / totalWeight
+	if share < 1 {
+		share = 1
+	}
+	return share
+}
+
+func (p *Pool) wakeOnCancel() {
+	<-p.ctx.Done()
+	p.mu.Lock()
+	p.cond.Broadcast()
+	p.mu.Unlock()
 }
 
 func (p *Pool) resultCollector() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-p.ctx.Done():
 			return
-			
+
 		case <-ticker.C:
 			processed := atomic.LoadInt64(&p.totalProcessed)
 			errors := atomic.LoadInt64(&p.totalErrors)
@@ -96,7 +304,7 @@ func (p *Pool) resultCollector() {
 				log.Printf("Worker pool stats: %d processados, %d erros (%.2f%%), %d em processamento",
 					processed, errors, errorRate, atomic.LoadInt32(&p.processing))
 			}
-			
+
 		case err := <-p.results:
 			if err != nil {
 				errorCount := atomic.LoadInt64(&p.totalErrors)
@@ -108,59 +316,172 @@ func (p *Pool) resultCollector() {
 	}
 }
 
+// Submit enfileira o job na fila da sua chave. Quando o pool está cheio, uma
+// fila ainda abaixo da sua fatia justa da capacidade continua aceitando jobs:
+// o job mais antigo da fila mais acima da sua fatia é descartado para abrir
+// espaço, para que uma câmera rápida não impeça uma câmera lenta de enfileirar
+// sem que o total passe da capacidade.
 func (p *Pool) Submit(job Job) error {
-	select {
-	case p.jobs <- job:
-		return nil
-	case <-p.ctx.Done():
+	key := defaultQueueKey
+	if keyed, ok := job.(KeyedJob); ok && keyed.QueueKey() != "" {
+		key = keyed.QueueKey()
+	}
+
+	p.mu.Lock()
+
+	if p.closed || p.ctx.Err() != nil {
+		p.mu.Unlock()
 		return fmt.Errorf("pool fechado")
-	default:
-		return fmt.Errorf("buffer cheio")
 	}
+
+	q := p.queueFor(key)
+	var evicted Job
+	if p.queued >= p.capacity {
+		if len(q.jobs) >= p.fairShare(q) {
+			p.mu.Unlock()
+			return fmt.Errorf("buffer cheio")
+		}
+		if evicted = p.evictOverShare(); evicted == nil {
+			p.mu.Unlock()
+			return fmt.Errorf("buffer cheio")
+		}
+	}
+
+	if len(q.jobs) == 0 && !q.busy {
+		p.active = append(p.active, q)
+	}
+	q.jobs = append(q.jobs, queuedJob{job: job, enqueuedAt: time.Now()})
+	p.queued++
+
+	metrics.WorkerQueueDepth.WithLabelValues(q.key).Set(float64(len(q.jobs)))
+	p.cond.Signal()
+	p.mu.Unlock()
+
+	if discardable, ok := evicted.(DiscardableJob); ok {
+		discardable.Discard()
+	}
+	return nil
 }
 
-func (p *Pool) SubmitNonBlocking(job Job) bool {
-	select {
-	case p.jobs <- job:
-		return true
-	default:
-		return false
+// evictOverShare remove o job mais antigo da fila que mais excede a sua fatia
+// justa e o retorna, ou nil se nenhuma fila excede a sua fatia. Deve ser
+// chamado com p.mu travado.
+func (p *Pool) evictOverShare() Job {
+	var victim *jobQueue
+	excess := 0
+	for _, q := range p.queues {
+		if over := len(q.jobs) - p.fairShare(q); over > excess {
+			victim, excess = q, over
+		}
+	}
+	if victim == nil {
+		return nil
 	}
+
+	// A fila excede a fatia (>= 1), então continua com jobs e no round-robin
+	item := victim.jobs[0]
+	victim.jobs[0] = queuedJob{}
+	victim.jobs = victim.jobs[1:]
+	p.queued--
+
+	metrics.WorkerQueueDepth.WithLabelValues(victim.key).Set(float64(len(victim.jobs)))
+	metrics.FramesDropped.WithLabelValues(victim.key, "worker_pool_evicted").Inc()
+	return item.job
+}
+
+func (p *Pool) SubmitNonBlocking(job Job) bool {
+	return p.Submit(job) == nil
 }
 
 func (p *Pool) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.Shutdown(ctx)
+}
+
+// Shutdown para de aceitar jobs e aguarda a fila esvaziar até o prazo de ctx.
+// Os jobs ainda enfileirados no prazo são descartados (DiscardableJob) e os
+// em processamento recebem o cancelamento do contexto do pool. Retorna
+// quantos jobs enfileirados foram abandonados.
+func (p *Pool) Shutdown(ctx context.Context) int {
 	log.Println("Fechando worker pool...")
-	close(p.jobs)
-	
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
 	defer ticker.Stop()
-	
+
 	for {
+		if p.idle() {
+			log.Println("Worker pool finalizado")
+			p.cancel()
+			return 0
+		}
+
 		select {
-		case <-timeout:
-			log.Printf("Timeout: %d jobs ainda processando", atomic.LoadInt32(&p.processing))
+		case <-ctx.Done():
+			processing := atomic.LoadInt32(&p.processing)
+			abandoned := p.abandonQueued()
+			log.Printf("Timeout: %d jobs abandonados na fila, %d ainda processando", abandoned, processing)
 			p.cancel()
-			return
-			
+			return abandoned
+
 		case <-ticker.C:
-			if atomic.LoadInt32(&p.processing) == 0 {
-				log.Println("Worker pool finalizado")
-				p.cancel()
-				return
-			}
 		}
 	}
 }
 
+// abandonQueued remove todos os jobs enfileirados, descartando-os.
+func (p *Pool) abandonQueued() int {
+	p.mu.Lock()
+	var jobs []Job
+	for _, q := range p.queues {
+		for _, item := range q.jobs {
+			jobs = append(jobs, item.job)
+		}
+		q.jobs = nil
+		q.credit = 0
+		metrics.WorkerQueueDepth.WithLabelValues(q.key).Set(0)
+	}
+	p.active = nil
+	p.queued = 0
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	for _, job := range jobs {
+		if discardable, ok := job.(DiscardableJob); ok {
+			discardable.Discard()
+		}
+	}
+	return len(jobs)
+}
+
+func (p *Pool) idle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queued == 0 && atomic.LoadInt32(&p.processing) == 0
+}
+
 func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	queued := p.queued
+	workers := p.workers
+	queues := make(map[string]int, len(p.queues))
+	for key, q := range p.queues {
+		queues[key] = len(q.jobs)
+	}
+	p.mu.Unlock()
+
 	return PoolStats{
-		Workers:        p.workers,
-		QueueSize:      len(p.jobs),
+		Workers:        workers,
+		QueueSize:      queued,
 		Processing:     int(atomic.LoadInt32(&p.processing)),
-		Capacity:       cap(p.jobs),
+		Capacity:       p.capacity,
 		TotalProcessed: atomic.LoadInt64(&p.totalProcessed),
 		TotalErrors:    atomic.LoadInt64(&p.totalErrors),
+		Queues:         queues,
 	}
 }
 
@@ -171,6 +492,7 @@ type PoolStats struct {
 	Capacity       int
 	TotalProcessed int64
 	TotalErrors    int64
+	Queues         map[string]int
 }
 
 func (ps PoolStats) String() string {
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"errors"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -41,7 +42,7 @@ func TestNewPool(t *testing.T) {
 	
 	assert.NotNil(t, pool)
 	assert.Equal(t, 5, pool.workers)
-	assert.Equal(t, 10, cap(pool.jobs))
+	assert.Equal(t, 10, pool.capacity)
 	
 	pool.Close()
 }
@@ -195,3 +196,401 @@ func BenchmarkPoolSubmit(b *testing.B) {
 	
 	pool.Close()
 }
+
+type keyedTestJob struct {
+	TestJob
+	key   string
+	order *[]string
+	mu    *sync.Mutex
+}
+
+func (j *keyedTestJob) QueueKey() string {
+	return j.key
+}
+
+func (j *keyedTestJob) Process(ctx context.Context) error {
+	j.mu.Lock()
+	*j.order = append(*j.order, j.key)
+	j.mu.Unlock()
+	return j.TestJob.Process(ctx)
+}
+
+func TestPoolWeightedRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 1, 100)
+	defer pool.Close()
+	
+	pool.SetWeight("fast", 1)
+	pool.SetWeight("priority", 2)
+	
+	var mu sync.Mutex
+	var order []string
+	
+	// Segura o único worker enquanto as filas são preenchidas
+	blocker := &TestJob{id: "blocker", delay: 100 * time.Millisecond}
+	assert.NoError(t, pool.Submit(blocker))
+	time.Sleep(20 * time.Millisecond)
+	
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, pool.Submit(&keyedTestJob{key: "fast", order: &order, mu: &mu}))
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, pool.Submit(&keyedTestJob{key: "slow", order: &order, mu: &mu}))
+	}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, pool.Submit(&keyedTestJob{key: "priority", order: &order, mu: &mu}))
+	}
+	
+	assert.Eventually(t, func() bool {
+		return pool.Stats().TotalProcessed == 13
+	}, 2*time.Second, 10*time.Millisecond)
+	
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"fast", "slow", "priority", "priority",
+		"fast", "slow", "priority", "priority",
+		"fast", "fast", "fast", "fast",
+	}, order)
+}
+
+func TestPoolFairShareWhenFull(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 1, 4)
+	defer pool.Close()
+	
+	var mu sync.Mutex
+	var order []string
+	
+	blocker := &TestJob{id: "blocker", delay: 200 * time.Millisecond}
+	assert.NoError(t, pool.Submit(blocker))
+	time.Sleep(20 * time.Millisecond)
+	
+	pool.SetWeight("slow", 1)
+	
+	// A câmera rápida ocupa toda a capacidade
+	submitted := 0
+	for i := 0; i < 10; i++ {
+		if pool.Submit(&keyedTestJob{key: "fast", order: &order, mu: &mu}) == nil {
+			submitted++
+		}
+	}
+	assert.Equal(t, 4, submitted)
+	
+	// A câmera lenta ainda consegue enfileirar dentro da sua fatia; o frame
+	// mais antigo da rápida sai para o total não passar da capacidade
+	assert.NoError(t, pool.Submit(&keyedTestJob{key: "slow", order: &order, mu: &mu}))
+	
+	stats := pool.Stats()
+	assert.Equal(t, 3, stats.Queues["fast"])
+	assert.Equal(t, 1, stats.Queues["slow"])
+	assert.Equal(t, 4, stats.QueueSize)
+	
+	// Acima da própria fatia a lenta é recusada como as demais
+	assert.Error(t, pool.Submit(&keyedTestJob{key: "slow", order: &order, mu: &mu}))
+}
+
+func TestPoolSubmitAfterClose(t *testing.T) {
+	pool := NewPool(context.Background(), 1, 10)
+	pool.Close()
+	
+	err := pool.Submit(&TestJob{id: "late"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "pool fechado")
+}
+
+func TestPoolAutoscaleUp(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 1, 200)
+	defer pool.Close()
+	
+	pool.EnableAutoscale(AutoscaleConfig{
+		Name:        "test",
+		MinWorkers:  1,
+		MaxWorkers:  6,
+		ScaleUpWait: 10 * time.Millisecond,
+		Interval:    50 * time.Millisecond,
+	})
+	
+	for i := 0; i < 150; i++ {
+		_ = pool.Submit(&TestJob{id: "slow", delay: 20 * time.Millisecond})
+	}
+	
+	assert.Eventually(t, func() bool {
+		return pool.Stats().Workers > 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, pool.Stats().Workers, 6)
+}
+
+func TestPoolAutoscaleBlockedByGuard(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 1, 200)
+	defer pool.Close()
+	
+	pool.EnableAutoscale(AutoscaleConfig{
+		Name:        "test",
+		MinWorkers:  1,
+		MaxWorkers:  6,
+		ScaleUpWait: 10 * time.Millisecond,
+		Interval:    50 * time.Millisecond,
+		CanScaleUp:  func() bool { return false },
+	})
+	
+	for i := 0; i < 50; i++ {
+		_ = pool.Submit(&TestJob{id: "slow", delay: 20 * time.Millisecond})
+	}
+	
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 1, pool.Stats().Workers)
+}
+
+func TestPoolAutoscaleDownWhenIdle(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 4, 20)
+	defer pool.Close()
+	
+	pool.EnableAutoscale(AutoscaleConfig{
+		Name:          "test",
+		MinWorkers:    2,
+		MaxWorkers:    4,
+		ScaleDownIdle: 50 * time.Millisecond,
+		Interval:      20 * time.Millisecond,
+	})
+	
+	assert.Eventually(t, func() bool {
+		return pool.Stats().Workers == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	
+	// Continua processando com os workers restantes
+	job := &TestJob{id: "after-scale-down"}
+	assert.NoError(t, pool.Submit(job))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&job.processed) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+type sequenceJob struct {
+	key      string
+	seq      int
+	inFlight *sync.Map
+	record   func(key string, seq int, overlapped bool)
+}
+
+func (j *sequenceJob) GetID() string {
+	return j.key
+}
+
+func (j *sequenceJob) QueueKey() string {
+	return j.key
+}
+
+func (j *sequenceJob) Process(ctx context.Context) error {
+	_, overlapped := j.inFlight.LoadOrStore(j.key, true)
+	// Atrasos variáveis fazem jobs posteriores terminarem antes sem ordenação
+	time.Sleep(time.Duration(j.seq%3) * time.Millisecond)
+	j.record(j.key, j.seq, overlapped)
+	if !overlapped {
+		j.inFlight.Delete(j.key)
+	}
+	return nil
+}
+
+func TestPoolOrderedPerCamera(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 16, 10000)
+	defer pool.Close()
+	pool.SetOrdered(true)
+	
+	const cameras = 8
+	const framesPerCamera = 200
+	
+	var mu sync.Mutex
+	var inFlight sync.Map
+	delivered := make(map[string][]int)
+	overlaps := 0
+	
+	record := func(key string, seq int, overlapped bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered[key] = append(delivered[key], seq)
+		if overlapped {
+			overlaps++
+		}
+	}
+	
+	var wg sync.WaitGroup
+	for c := 0; c < cameras; c++ {
+		wg.Add(1)
+		go func(cameraID string) {
+			defer wg.Done()
+			for seq := 0; seq < framesPerCamera; seq++ {
+				job := &sequenceJob{key: cameraID, seq: seq, inFlight: &inFlight, record: record}
+				assert.NoError(t, pool.Submit(job))
+			}
+		}(string(rune('a' + c)))
+	}
+	wg.Wait()
+	
+	assert.Eventually(t, func() bool {
+		return pool.Stats().TotalProcessed == cameras*framesPerCamera
+	}, 10*time.Second, 10*time.Millisecond)
+	
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Zero(t, overlaps, "jobs da mesma câmera processados em paralelo")
+	assert.Len(t, delivered, cameras)
+	for cameraID, seqs := range delivered {
+		assert.Len(t, seqs, framesPerCamera)
+		for i, seq := range seqs {
+			if !assert.Equal(t, i, seq, "frame fora de ordem na câmera %s", cameraID) {
+				break
+			}
+		}
+	}
+}
+
+func TestPoolOrderedCamerasRunInParallel(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(ctx, 4, 100)
+	defer pool.Close()
+	pool.SetOrdered(true)
+	
+	var mu sync.Mutex
+	var order []string
+	
+	start := time.Now()
+	for c := 0; c < 4; c++ {
+		for i := 0; i < 5; i++ {
+			job := &keyedTestJob{
+				TestJob: TestJob{id: "slow", delay: 20 * time.Millisecond},
+				key:     string(rune('a' + c)),
+				order:   &order,
+				mu:      &mu,
+			}
+			assert.NoError(t, pool.Submit(job))
+		}
+	}
+	
+	assert.Eventually(t, func() bool {
+		return pool.Stats().TotalProcessed == 20
+	}, 2*time.Second, 5*time.Millisecond)
+	
+	// 5 jobs de 20ms em sequência por câmera, 4 câmeras em paralelo: ~100ms
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestPoolOrderedDrainsOnClose(t *testing.T) {
+	pool := NewPool(context.Background(), 2, 100)
+	pool.SetOrdered(true)
+	
+	var mu sync.Mutex
+	var order []string
+	
+	jobs := make([]*keyedTestJob, 10)
+	for i := range jobs {
+		jobs[i] = &keyedTestJob{
+			TestJob: TestJob{id: "drain", delay: 5 * time.Millisecond},
+			key:     "cam1",
+			order:   &order,
+			mu:      &mu,
+		}
+		assert.NoError(t, pool.Submit(jobs[i]))
+	}
+	
+	pool.Close()
+	
+	for _, job := range jobs {
+		assert.Equal(t, int32(1), atomic.LoadInt32(&job.processed))
+	}
+}
+
+type discardableTestJob struct {
+	TestJob
+	discarded int32
+}
+
+func (j *discardableTestJob) Discard() {
+	atomic.AddInt32(&j.discarded, 1)
+}
+
+func TestPoolShutdownDrainsQueue(t *testing.T) {
+	pool := NewPool(context.Background(), 2, 100)
+
+	jobs := make([]*discardableTestJob, 10)
+	for i := range jobs {
+		jobs[i] = &discardableTestJob{TestJob: TestJob{id: "drain", delay: 2 * time.Millisecond}}
+		assert.NoError(t, pool.Submit(jobs[i]))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Equal(t, 0, pool.Shutdown(ctx))
+
+	for _, job := range jobs {
+		assert.Equal(t, int32(1), atomic.LoadInt32(&job.processed))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&job.discarded))
+	}
+	assert.Error(t, pool.Submit(&TestJob{id: "late"}))
+}
+
+func TestPoolShutdownAbandonsQueuedOnDeadline(t *testing.T) {
+	pool := NewPool(context.Background(), 1, 100)
+
+	jobs := make([]*discardableTestJob, 10)
+	for i := range jobs {
+		jobs[i] = &discardableTestJob{TestJob: TestJob{id: "slow", delay: 50 * time.Millisecond}}
+		assert.NoError(t, pool.Submit(jobs[i]))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	abandoned := pool.Shutdown(ctx)
+	assert.Greater(t, abandoned, 0)
+
+	// Todo job foi processado ou descartado, nunca os dois
+	assert.Eventually(t, func() bool {
+		done := 0
+		for _, job := range jobs {
+			done += int(atomic.LoadInt32(&job.processed) + atomic.LoadInt32(&job.discarded))
+		}
+		return done == len(jobs)
+	}, time.Second, 5*time.Millisecond)
+
+	discarded := 0
+	for _, job := range jobs {
+		discarded += int(atomic.LoadInt32(&job.discarded))
+		assert.LessOrEqual(t, atomic.LoadInt32(&job.processed)+atomic.LoadInt32(&job.discarded), int32(1))
+	}
+	assert.Equal(t, abandoned, discarded)
+}
+
+type keyedDiscardableJob struct {
+	discardableTestJob
+	key string
+}
+
+func (j *keyedDiscardableJob) QueueKey() string {
+	return j.key
+}
+
+func TestPoolFairShareEvictsOldest(t *testing.T) {
+	pool := NewPool(context.Background(), 1, 4)
+	defer pool.Close()
+
+	assert.NoError(t, pool.Submit(&TestJob{id: "blocker", delay: 200 * time.Millisecond}))
+	time.Sleep(20 * time.Millisecond)
+	pool.SetWeight("slow", 1)
+
+	fast := make([]*keyedDiscardableJob, 4)
+	for i := range fast {
+		fast[i] = &keyedDiscardableJob{key: "fast"}
+		assert.NoError(t, pool.Submit(fast[i]))
+	}
+
+	assert.NoError(t, pool.Submit(&keyedDiscardableJob{key: "slow"}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fast[0].discarded), "o job mais antigo da fila rápida é descartado")
+	for _, job := range fast[1:] {
+		assert.Equal(t, int32(0), atomic.LoadInt32(&job.discarded))
+	}
+	assert.Equal(t, 4, pool.Stats().QueueSize)
+}